@@ -35,5 +35,5 @@ func realMain() error {
 	logs.Init(rootCmd, false)
 	defer logs.FlushLogs()
 
-	return rootCmd.Execute()
+	return rootCmd.ExecuteContext(cmds.SetupSignalContext())
 }
@@ -0,0 +1,201 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// gendocs extracts the documentation and the kubebuilder markers of the
+// installer API types from their sources, since they are not available at
+// runtime. It writes them as the type documentation of pkg/installer.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type fieldDoc struct {
+	name     string
+	doc      string
+	required bool
+	enum     []string
+	def      string
+}
+
+type typeDoc struct {
+	name   string
+	doc    string
+	enum   []string
+	def    string
+	fields []fieldDoc
+	// isStruct is set even for structs without documented fields
+	isStruct bool
+}
+
+func main() {
+	dir := flag.String("dir", "vendor/kubeops.dev/installer/apis/installer/v1alpha1", "Directory of the installer API package")
+	out := flag.String("o", "pkg/installer/zz_generated.docs.go", "Output file")
+	header := flag.String("header", "hack/license/go.txt", "License header of the output file")
+	flag.Parse()
+
+	docs, err := parseDocs(*dir)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	hdr, err := os.ReadFile(*header)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	src, err := render(string(hdr), docs)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func parseDocs(dir string) ([]typeDoc, error) {
+	fset := token.NewFileSet()
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	var docs []typeDoc
+	for _, file := range files {
+		if strings.HasPrefix(filepath.Base(file), "zz_generated") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				comments := ts.Doc
+				if comments == nil && len(gen.Specs) == 1 {
+					comments = gen.Doc
+				}
+				td := typeDoc{name: ts.Name.Name}
+				td.doc, td.enum, td.def, _ = parseComments(comments)
+				switch t := ts.Type.(type) {
+				case *ast.StructType:
+					td.isStruct = true
+					for _, field := range t.Fields.List {
+						// the embedded fields are documented by their own type
+						if len(field.Names) == 0 {
+							continue
+						}
+						fd := fieldDoc{name: field.Names[0].Name}
+						fd.doc, fd.enum, fd.def, fd.required = parseComments(field.Doc)
+						td.fields = append(td.fields, fd)
+					}
+				case *ast.Ident:
+					if td.enum == nil && td.def == "" && td.doc == "" {
+						continue
+					}
+				default:
+					continue
+				}
+				docs = append(docs, td)
+			}
+		}
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].name < docs[j].name })
+	return docs, nil
+}
+
+// parseComments splits a comment into its text and its markers.
+func parseComments(cg *ast.CommentGroup) (doc string, enum []string, def string, required bool) {
+	if cg == nil {
+		return
+	}
+	var lines []string
+	for _, line := range strings.Split(cg.Text(), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "+") {
+			lines = append(lines, line)
+			continue
+		}
+		marker := strings.TrimPrefix(line, "+")
+		switch {
+		case marker == "required" || marker == "kubebuilder:validation:Required":
+			required = true
+		case strings.HasPrefix(marker, "kubebuilder:validation:Enum="):
+			enum = strings.Split(strings.TrimPrefix(marker, "kubebuilder:validation:Enum="), ";")
+		case strings.HasPrefix(marker, "kubebuilder:default:="):
+			def = strings.TrimPrefix(marker, "kubebuilder:default:=")
+		case strings.HasPrefix(marker, "kubebuilder:default="):
+			def = strings.TrimPrefix(marker, "kubebuilder:default=")
+		}
+	}
+	doc = strings.TrimSpace(strings.Join(lines, "\n"))
+	return
+}
+
+func render(header string, docs []typeDoc) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s\n\n// Code generated by hack/gendocs. DO NOT EDIT.\n\npackage installer\n\n", strings.TrimSpace(header))
+	fmt.Fprintln(&buf, "var typeDocs = map[string]typeDoc{")
+	for _, td := range docs {
+		fmt.Fprintf(&buf, "%q: {\n", td.name)
+		if td.doc != "" {
+			fmt.Fprintf(&buf, "Doc: %q,\n", td.doc)
+		}
+		if td.enum != nil {
+			fmt.Fprintf(&buf, "Enum: %#v,\n", td.enum)
+		}
+		if td.def != "" {
+			fmt.Fprintf(&buf, "Default: %q,\n", td.def)
+		}
+		if td.isStruct {
+			fmt.Fprintln(&buf, "Fields: map[string]fieldDoc{")
+			for _, fd := range td.fields {
+				fmt.Fprintf(&buf, "%q: {", fd.name)
+				var attrs []string
+				if fd.doc != "" {
+					attrs = append(attrs, fmt.Sprintf("Doc: %q", fd.doc))
+				}
+				if fd.required {
+					attrs = append(attrs, "Required: true")
+				}
+				if fd.enum != nil {
+					attrs = append(attrs, fmt.Sprintf("Enum: %#v", fd.enum))
+				}
+				if fd.def != "" {
+					attrs = append(attrs, fmt.Sprintf("Default: %q", fd.def))
+				}
+				fmt.Fprintf(&buf, "%s},\n", strings.Join(attrs, ", "))
+			}
+			fmt.Fprintln(&buf, "},")
+		}
+		fmt.Fprintln(&buf, "},")
+	}
+	fmt.Fprintln(&buf, "}")
+	return format.Source(buf.Bytes())
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package auth
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
@@ -35,7 +36,7 @@ func newCmdLogin() *cobra.Command {
 		Short:             "Establish a authenticated session with the api endpoint",
 		DisableAutoGenTag: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			err := login(cred)
+			err := login(cmd.Context(), cred)
 			if err != nil {
 				return err
 			}
@@ -52,30 +53,30 @@ func newCmdLogin() *cobra.Command {
 	return cmd
 }
 
-func login(cred v1alpha1.BasicAuth) error {
-	ctx, err := config.GetContext()
+func login(ctx context.Context, cred v1alpha1.BasicAuth) error {
+	cfg, err := config.GetContext()
 	if err != nil {
 		return err
 	}
 
 	if AccessToken != "" {
-		ctx.Token = AccessToken
-		return config.SetContext(*ctx)
+		cfg.Token = AccessToken
+		return config.SetContext(*cfg)
 	}
 
 	if cred.Username == "" || cred.Password == "" {
 		return fmt.Errorf("missing credentials. Please provide both username and password")
 	}
-	client := ace.NewClient(ctx.Endpoint)
+	client := ace.NewClientWithHTTP(config.NewHTTPClient(ctx), cfg.Endpoint)
 	cookies, err := client.Signin(ace.SignInParams{UserName: cred.Username, Password: cred.Password})
 	if err != nil {
 		return err
 	}
-	ctx.Cookies = make([]http.Cookie, 0)
+	cfg.Cookies = make([]http.Cookie, 0)
 	for i := range cookies {
 		if cookies[i].Name == csrfCookie || cookies[i].Name == sessionCookie {
-			ctx.Cookies = append(ctx.Cookies, cookies[i])
+			cfg.Cookies = append(cfg.Cookies, cookies[i])
 		}
 	}
-	return config.SetContext(*ctx)
+	return config.SetContext(*cfg)
 }
@@ -17,6 +17,7 @@ limitations under the License.
 package auth
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
@@ -32,7 +33,7 @@ func newCmdLogout() *cobra.Command {
 		Short:             "End current authenticated session with the api endpoint",
 		DisableAutoGenTag: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			err := logout()
+			err := logout(cmd.Context())
 			if err != nil {
 				return err
 			}
@@ -44,17 +45,17 @@ func newCmdLogout() *cobra.Command {
 	return cmd
 }
 
-func logout() error {
-	ctx, err := config.GetContext()
+func logout(ctx context.Context) error {
+	cfg, err := config.GetContext()
 	if err != nil {
 		return err
 	}
-	client := ace.NewClient(ctx.Endpoint).WithCookies(ctx.Cookies)
+	client := ace.NewClientWithHTTP(config.NewHTTPClient(ctx), cfg.Endpoint).WithCookies(cfg.Cookies)
 
 	err = client.Signout()
 	if err != nil {
 		return err
 	}
-	ctx.Cookies = []http.Cookie{}
-	return config.SetContext(*ctx)
+	cfg.Cookies = []http.Cookie{}
+	return config.SetContext(*cfg)
 }
@@ -0,0 +1,517 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"filippo.io/age"
+	"github.com/klauspost/compress/zstd"
+	"github.com/rs/xid"
+	"gocloud.dev/blob"
+	"gocloud.dev/blob/fileblob"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	backupFormatDir    = "dir"
+	backupFormatTarZst = "tar.zst"
+
+	// archiveMetaRecord is the PAX record of an archive entry holding the
+	// attributes of the object.
+	archiveMetaRecord = "CLOUDSWAP.meta"
+	encryptedSuffix   = ".age"
+)
+
+// localBackup keeps a copy of the objects written to the destination.
+type localBackup interface {
+	// newWriter starts writing an object of the given size. The write is
+	// aborted if ctx is cancelled.
+	newWriter(ctx context.Context, key string, size int64, wopts *blob.WriterOptions) (localWriter, error)
+	close() error
+}
+
+// localWriter writes an object to the local backup.
+type localWriter interface {
+	io.Writer
+	// commit finishes the object and returns the manifest record that
+	// locates it in the backup.
+	commit() (manifestRecord, error)
+	// abort discards the object.
+	abort()
+}
+
+// openLocalBackup opens the local backup directory in the given format. The
+// archives are encrypted if there are recipients.
+func openLocalBackup(dir, format string, concurrency int, recipients []age.Recipient) (localBackup, error) {
+	switch format {
+	case backupFormatDir:
+		bucket, err := fileblob.OpenBucket(dir, &fileblob.Options{CreateDir: true})
+		if err != nil {
+			return nil, err
+		}
+		return &dirBackup{bucket: bucket}, nil
+	case backupFormatTarZst:
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, err
+		}
+		return &archiveBackup{
+			dir:        dir,
+			runID:      xid.New().String(),
+			recipients: recipients,
+			idle:       make(chan *archivePart, concurrency),
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid local backup format %q. Supported formats are %s and %s", format, backupFormatDir, backupFormatTarZst)
+	}
+}
+
+// dirBackup mirrors the destination keys as files of the backup directory.
+type dirBackup struct {
+	bucket *blob.Bucket
+}
+
+func (b *dirBackup) newWriter(ctx context.Context, key string, _ int64, wopts *blob.WriterOptions) (localWriter, error) {
+	w, err := b.bucket.NewWriter(ctx, key, wopts)
+	if err != nil {
+		return nil, err
+	}
+	return &dirWriter{Writer: w, key: key}, nil
+}
+
+func (b *dirBackup) close() error {
+	return b.bucket.Close()
+}
+
+type dirWriter struct {
+	*blob.Writer
+	key string
+}
+
+func (w *dirWriter) commit() (manifestRecord, error) {
+	return manifestRecord{Key: w.key}, w.Close()
+}
+
+func (w *dirWriter) abort() {
+	// the context of the writer is cancelled at this point, so closing it
+	// leaves no partial file behind
+	_ = w.Close()
+}
+
+// archiveBackup writes the objects as entries of zstd compressed tar
+// archives, optionally encrypted with age. Since a tar archive is written
+// sequentially, every concurrent writer gets an archive part of its own.
+// The parts of a run are named cloud-swap-<run>-<n>.tar.zst[.age].
+type archiveBackup struct {
+	dir        string
+	runID      string
+	recipients []age.Recipient
+
+	// idle holds the parts not used by any writer
+	idle  chan *archivePart
+	mu    sync.Mutex
+	parts int
+}
+
+type archivePart struct {
+	name    string
+	file    *os.File
+	enc     io.WriteCloser
+	zw      *zstd.Encoder
+	tw      *tar.Writer
+	entries int
+}
+
+func (b *archiveBackup) newWriter(_ context.Context, key string, size int64, wopts *blob.WriterOptions) (localWriter, error) {
+	var p *archivePart
+	select {
+	case p = <-b.idle:
+	default:
+		var err error
+		if p, err = b.newPart(); err != nil {
+			return nil, err
+		}
+	}
+
+	meta, err := json.Marshal(metaFromWriterOptions(wopts))
+	if err != nil {
+		b.discard(p)
+		return nil, err
+	}
+	err = p.tw.WriteHeader(&tar.Header{
+		Typeflag:   tar.TypeReg,
+		Name:       key,
+		Size:       size,
+		Mode:       0o600,
+		ModTime:    time.Now(),
+		Format:     tar.FormatPAX,
+		PAXRecords: map[string]string{archiveMetaRecord: string(meta)},
+	})
+	if err != nil {
+		b.discard(p)
+		return nil, err
+	}
+	return &archiveWriter{backup: b, part: p, key: key}, nil
+}
+
+func (b *archiveBackup) newPart() (_ *archivePart, err error) {
+	b.mu.Lock()
+	b.parts += 1
+	n := b.parts
+	b.mu.Unlock()
+
+	name := fmt.Sprintf("cloud-swap-%s-%d.%s", b.runID, n, backupFormatTarZst)
+	if len(b.recipients) > 0 {
+		name += encryptedSuffix
+	}
+	file, err := os.OpenFile(filepath.Join(b.dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	p := &archivePart{name: name, file: file}
+	defer func() {
+		if err != nil {
+			_ = p.close(false)
+		}
+	}()
+
+	var w io.Writer = file
+	if len(b.recipients) > 0 {
+		if p.enc, err = age.Encrypt(file, b.recipients...); err != nil {
+			return nil, fmt.Errorf("failed to encrypt %s. Reason: %w", name, err)
+		}
+		w = p.enc
+	}
+	// the parts are already written in parallel
+	if p.zw, err = zstd.NewWriter(w, zstd.WithEncoderConcurrency(1)); err != nil {
+		return nil, err
+	}
+	p.tw = tar.NewWriter(p.zw)
+	return p, nil
+}
+
+// release makes a part available to the next writer.
+func (b *archiveBackup) release(p *archivePart) {
+	select {
+	case b.idle <- p:
+	default:
+		_ = p.close(true)
+	}
+}
+
+// discard closes a part whose last entry is unfinished. The entries before
+// it stay readable.
+func (b *archiveBackup) discard(p *archivePart) {
+	_ = p.close(false)
+}
+
+func (b *archiveBackup) close() error {
+	var errs []error
+	for {
+		select {
+		case p := <-b.idle:
+			errs = append(errs, p.close(true))
+		default:
+			return errors.Join(errs...)
+		}
+	}
+}
+
+// close flushes the part to disk. The tar trailer is only written if the
+// last entry is complete.
+func (p *archivePart) close(complete bool) error {
+	var errs []error
+	if complete && p.tw != nil {
+		errs = append(errs, p.tw.Close())
+	}
+	if p.zw != nil {
+		errs = append(errs, p.zw.Close())
+	}
+	if p.enc != nil {
+		errs = append(errs, p.enc.Close())
+	}
+	errs = append(errs, p.file.Close())
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to write %s. Reason: %w", p.name, err)
+	}
+	return nil
+}
+
+type archiveWriter struct {
+	backup *archiveBackup
+	part   *archivePart
+	key    string
+}
+
+func (w *archiveWriter) Write(data []byte) (int, error) {
+	return w.part.tw.Write(data)
+}
+
+func (w *archiveWriter) commit() (manifestRecord, error) {
+	p := w.part
+	// Flush fails if the object is shorter than its entry
+	if err := p.tw.Flush(); err != nil {
+		w.backup.discard(p)
+		return manifestRecord{}, err
+	}
+	rec := manifestRecord{Archive: p.name, Entry: p.entries}
+	// the keys of an encrypted backup are only found in its archives
+	if len(w.backup.recipients) == 0 {
+		rec.Key = w.key
+	}
+	p.entries += 1
+	w.backup.release(p)
+	return rec, nil
+}
+
+func (w *archiveWriter) abort() {
+	w.backup.discard(w.part)
+}
+
+// metaFromWriterOptions returns the attributes an object is written with.
+func metaFromWriterOptions(wopts *blob.WriterOptions) objectMeta {
+	return objectMeta{
+		ContentType:        wopts.ContentType,
+		ContentEncoding:    wopts.ContentEncoding,
+		CacheControl:       wopts.CacheControl,
+		ContentDisposition: wopts.ContentDisposition,
+		ContentLanguage:    wopts.ContentLanguage,
+		Metadata:           wopts.Metadata,
+	}
+}
+
+// archiveReader reads the entries of an archive part of a local backup.
+type archiveReader struct {
+	name  string
+	file  *os.File
+	zr    *zstd.Decoder
+	tr    *tar.Reader
+	entry int
+}
+
+// openArchive opens an archive part, decrypting it with the identities if it
+// is encrypted.
+func openArchive(dir, name string, identities []age.Identity) (_ *archiveReader, err error) {
+	file, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = file.Close()
+		}
+	}()
+
+	var r io.Reader = file
+	if strings.HasSuffix(name, encryptedSuffix) {
+		if len(identities) == 0 {
+			return nil, fmt.Errorf("%s is encrypted. Give the key with --identity-file or --passphrase-file", name)
+		}
+		if r, err = age.Decrypt(file, identities...); err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s. Reason: %w", name, err)
+		}
+	}
+	zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return &archiveReader{name: name, file: file, zr: zr, tr: tar.NewReader(zr), entry: -1}, nil
+}
+
+// next advances to the next entry and returns its header and index. The
+// object is read from the archive reader itself.
+func (a *archiveReader) next() (*tar.Header, int, error) {
+	hdr, err := a.tr.Next()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read %s. Reason: %w", a.name, err)
+	}
+	a.entry += 1
+	return hdr, a.entry, nil
+}
+
+// seek advances to the entry with the given index.
+func (a *archiveReader) seek(index int) (*tar.Header, error) {
+	for {
+		hdr, i, err := a.next()
+		if err != nil {
+			return nil, err
+		}
+		if i == index {
+			return hdr, nil
+		}
+	}
+}
+
+func (a *archiveReader) Read(data []byte) (int, error) {
+	return a.tr.Read(data)
+}
+
+func (a *archiveReader) close() error {
+	a.zr.Close()
+	return a.file.Close()
+}
+
+// entryMeta returns the attributes of the object of an archive entry.
+func entryMeta(hdr *tar.Header) (*objectMeta, error) {
+	data, ok := hdr.PAXRecords[archiveMetaRecord]
+	if !ok {
+		return nil, nil
+	}
+	var meta objectMeta
+	if err := json.Unmarshal([]byte(data), &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse the attributes of %s. Reason: %w", hdr.Name, err)
+	}
+	return &meta, nil
+}
+
+// backupArchives reads the archive parts of a local backup.
+type backupArchives struct {
+	dir         string
+	identities  []age.Identity
+	concurrency int
+}
+
+// groupArchives groups the archived records by archive part, sorted by entry
+// index.
+func groupArchives(records []manifestRecord) map[string][]manifestRecord {
+	groups := make(map[string][]manifestRecord)
+	for _, rec := range records {
+		if rec.Archive != "" {
+			groups[rec.Archive] = append(groups[rec.Archive], rec)
+		}
+	}
+	for _, recs := range groups {
+		sort.Slice(recs, func(i, j int) bool {
+			return recs[i].Entry < recs[j].Entry
+		})
+	}
+	return groups
+}
+
+// check reads the archived objects of the records, filling in the keys of
+// the encrypted entries. It returns why the objects that are missing or
+// corrupted can't be restored, by record index.
+func (a *backupArchives) check(ctx context.Context, records []manifestRecord) (map[int]string, error) {
+	entries := make(map[string]map[int]int)
+	for i, rec := range records {
+		if rec.Archive == "" {
+			continue
+		}
+		if entries[rec.Archive] == nil {
+			entries[rec.Archive] = make(map[int]int)
+		}
+		entries[rec.Archive][rec.Entry] = i
+	}
+
+	var mu sync.Mutex
+	reasons := make(map[int]string)
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(a.concurrency)
+	for name, wanted := range entries {
+		g.Go(func() error {
+			return a.checkArchive(ctx, name, records, wanted, func(index int, hdr *tar.Header, reason string) {
+				mu.Lock()
+				defer mu.Unlock()
+				rec := &records[index]
+				switch {
+				case hdr != nil && rec.Key != "" && rec.Key != hdr.Name:
+					reason = fmt.Sprintf("entry %d of %s holds %s", rec.Entry, rec.Archive, hdr.Name)
+				case hdr != nil:
+					rec.Key = hdr.Name
+				}
+				if reason != "" {
+					reasons[index] = reason
+				}
+			})
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return reasons, nil
+}
+
+// checkArchive reads the wanted entries of an archive part, given as entry
+// index to record index, and reports every one of them with the reason it
+// can't be restored, if any. The header is nil if the entry couldn't be read.
+func (a *backupArchives) checkArchive(ctx context.Context, name string, records []manifestRecord, wanted map[int]int, report func(index int, hdr *tar.Header, reason string)) error {
+	r, err := openArchive(a.dir, name, a.identities)
+	if errors.Is(err, os.ErrNotExist) {
+		for _, i := range wanted {
+			report(i, nil, fmt.Sprintf("archive %s is missing", name))
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer r.close()
+
+	// the entries left when the archive ends early are missing
+	reason := fmt.Sprintf("missing from %s", name)
+	for len(wanted) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hdr, index, err := r.next()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				reason = err.Error()
+			}
+			break
+		}
+		i, ok := wanted[index]
+		if !ok {
+			continue
+		}
+		delete(wanted, index)
+
+		rec := records[i]
+		hash := md5.New()
+		n, err := io.Copy(hash, r)
+		switch {
+		case err != nil:
+			report(i, hdr, fmt.Sprintf("failed to read %s. Reason: %v", name, err))
+		case n != rec.Size:
+			report(i, hdr, fmt.Sprintf("size is %d, expected %d", n, rec.Size))
+		case len(rec.MD5) > 0 && !bytes.Equal(hash.Sum(nil), rec.MD5):
+			report(i, hdr, "content differs")
+		default:
+			report(i, hdr, "")
+		}
+		if err != nil {
+			break
+		}
+	}
+	for _, i := range wanted {
+		report(i, nil, reason)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"gocloud.dev/blob"
+)
+
+func TestArchiveBackupRestore(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	identityFile := filepath.Join(t.TempDir(), "identity.txt")
+	if err := os.WriteFile(identityFile, []byte(identity.String()+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	objects := map[string]string{
+		"a.txt":        "a",
+		"dir/b.json":   `{"b":true}`,
+		"dir/empty":    "",
+		"large/c.data": strings.Repeat("c", 1<<20),
+	}
+	tests := []struct {
+		name       string
+		encryption encryptionOptions
+		decryption decryptionOptions
+		suffix     string
+	}{
+		{name: "plain", suffix: ".tar.zst"},
+		{
+			name:       "encrypted",
+			encryption: encryptionOptions{enabled: true, recipients: []string{identity.Recipient().String()}},
+			decryption: decryptionOptions{identityFiles: []string{identityFile}},
+			suffix:     ".tar.zst.age",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, dst := newTestBucket(t), newTestBucket(t)
+			src.write(t, objects)
+			err := src.bucket.WriteAll(context.Background(), "dir/b.json", []byte(objects["dir/b.json"]), &blob.WriterOptions{ContentType: "application/json"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			backup := t.TempDir()
+			opts := testSwapOptions(t, src, dst)
+			opts.disableLocalBackup = false
+			opts.localBackupDir = backup
+			opts.localBackupFormat = backupFormatTarZst
+			opts.encryption = tt.encryption
+			runTestCopy(t, opts)
+
+			archives, err := filepath.Glob(filepath.Join(backup, "cloud-swap-*"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(archives) == 0 {
+				t.Fatal("no archive written to the backup")
+			}
+			for _, name := range archives {
+				if !strings.HasSuffix(name, tt.suffix) {
+					t.Errorf("archive %s, want the suffix %s", filepath.Base(name), tt.suffix)
+				}
+			}
+			manifest, err := os.ReadFile(filepath.Join(backup, backupManifestFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if leaked := strings.Contains(string(manifest), "dir/b.json"); leaked == tt.encryption.enabled {
+				t.Errorf("manifest lists the keys = %v, want %v", leaked, !tt.encryption.enabled)
+			}
+
+			restored := newTestBucket(t)
+			ropts := testRestoreOptions(t, backup, restored)
+			ropts.decryption = tt.decryption
+			if err := restoreBackup(context.Background(), ropts); err != nil {
+				t.Fatal(err)
+			}
+			if got := restored.contents(t); !reflect.DeepEqual(got, objects) {
+				t.Errorf("restored %d objects, want %d", len(got), len(objects))
+			}
+			attrs, err := restored.bucket.Attributes(context.Background(), "dir/b.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if attrs.ContentType != "application/json" {
+				t.Errorf("content type = %q, want the one of the source object", attrs.ContentType)
+			}
+		})
+	}
+}
+
+func TestRestoreEncryptedBackupWithWrongIdentity(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	identityFile := filepath.Join(t.TempDir(), "identity.txt")
+	if err := os.WriteFile(identityFile, []byte(other.String()+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	src, dst := newTestBucket(t), newTestBucket(t)
+	src.write(t, map[string]string{"a.txt": "a"})
+	backup := t.TempDir()
+	opts := testSwapOptions(t, src, dst)
+	opts.disableLocalBackup = false
+	opts.localBackupDir = backup
+	opts.localBackupFormat = backupFormatTarZst
+	opts.encryption = encryptionOptions{enabled: true, recipients: []string{identity.Recipient().String()}}
+	runTestCopy(t, opts)
+
+	restored := newTestBucket(t)
+	ropts := testRestoreOptions(t, backup, restored)
+	ropts.decryption = decryptionOptions{identityFiles: []string{identityFile}}
+	if err := restoreBackup(context.Background(), ropts); err == nil || !strings.Contains(err.Error(), "failed to decrypt") {
+		t.Errorf("error = %v, want a decryption failure", err)
+	}
+	if got := restored.contents(t); len(got) != 0 {
+		t.Errorf("restored %v, want nothing", got)
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"golang.org/x/time/rate"
+)
+
+// bandwidthBurst is the number of bytes that can be read at once under a
+// bandwidth limit.
+const bandwidthBurst = 64 * 1024
+
+// newBandwidthLimiter returns the limiter of a bandwidth limit given in bytes
+// per second, as in 10MiB or 10MiB/s. It returns nil if there is no limit.
+func newBandwidthLimiter(limit string) (*rate.Limiter, error) {
+	if limit == "" {
+		return nil, nil
+	}
+	bps, err := humanize.ParseBytes(strings.TrimSuffix(limit, "/s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid bandwidth limit %q. Reason: %w", limit, err)
+	}
+	if bps == 0 {
+		return nil, nil
+	}
+	return rate.NewLimiter(rate.Limit(bps), bandwidthBurst), nil
+}
+
+// meteredReader counts the bytes read from r and keeps the rate they are
+// read at under the bandwidth limit, which is shared by all the workers.
+type meteredReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+	count   func(n int)
+}
+
+func (r *meteredReader) Read(data []byte) (int, error) {
+	if r.limiter != nil && len(data) > bandwidthBurst {
+		data = data[:bandwidthBurst]
+	}
+	n, err := r.r.Read(data)
+	if n > 0 {
+		r.count(n)
+		if r.limiter != nil {
+			if werr := r.limiter.WaitN(r.ctx, n); werr != nil && err == nil {
+				err = werr
+			}
+		}
+	}
+	return n, err
+}
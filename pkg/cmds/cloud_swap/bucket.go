@@ -0,0 +1,219 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/spf13/cobra"
+	"gocloud.dev/blob"
+	"gocloud.dev/blob/s3blob"
+)
+
+const (
+	addressingPath        = "path"
+	addressingVirtualHost = "virtual-host"
+
+	defaultRegion = "us-east-1"
+)
+
+// bucketOptions describe one side of the copy. The bucket is either given as
+// a gocloud.dev URL or as a bucket of an S3 compatible endpoint.
+type bucketOptions struct {
+	side        string
+	url         string
+	credentials credentialOptions
+	endpoint    endpointOptions
+}
+
+// endpointOptions describe a bucket of any S3 compatible storage, i.e. MinIO.
+type endpointOptions struct {
+	endpoint           string
+	bucket             string
+	region             string
+	addressing         string
+	caFile             string
+	insecureSkipVerify bool
+}
+
+func addBucketFlags(cmd *cobra.Command, side string, opts *bucketOptions) {
+	opts.side = side
+	name := sideName(side)
+	fs := cmd.Flags()
+	fs.StringVar(&opts.url, side+"-bucket-url", "", fmt.Sprintf("Complete %s bucket url with scheme, region, endpoints", name))
+	addCredentialFlags(fs, side, &opts.credentials)
+
+	e := &opts.endpoint
+	fs.StringVar(&e.endpoint, side+"-endpoint", "", fmt.Sprintf("URL of the S3 compatible storage of the %s bucket, with http or https scheme (i.e. https://minio.example.com:9000)", name))
+	fs.StringVar(&e.bucket, side+"-bucket", "", fmt.Sprintf("Name of the %s bucket in the S3 compatible storage", name))
+	fs.StringVar(&e.region, side+"-region", defaultRegion, fmt.Sprintf("Region of the %s bucket in the S3 compatible storage", name))
+	fs.StringVar(&e.addressing, side+"-addressing", addressingPath, fmt.Sprintf("Addressing style of the %s S3 compatible storage (any of path,virtual-host)", name))
+	fs.StringVar(&e.caFile, side+"-ca-file", "", fmt.Sprintf("CA certificate used to verify the %s S3 compatible storage", name))
+	fs.BoolVar(&e.insecureSkipVerify, side+"-insecure-skip-verify", false, fmt.Sprintf("Skip TLS verification of the %s S3 compatible storage", name))
+
+	cmd.MarkFlagsMutuallyExclusive(side+"-bucket-url", side+"-endpoint")
+	cmd.MarkFlagsRequiredTogether(side+"-endpoint", side+"-bucket")
+}
+
+// addS3ProxyFlags registers the flags of the S3 proxy source used before the
+// endpoint model. They are kept as deprecated aliases of the --src-* flags.
+func addS3ProxyFlags(cmd *cobra.Command, opts *bucketOptions) {
+	fs := cmd.Flags()
+	fs.StringVar(&opts.endpoint.endpoint, "s3proxy.endpoint", "", "S3proxy storage endpoint")
+	fs.StringVar(&opts.endpoint.bucket, "s3proxy.bucket", "", "MinIO storage bucket name")
+	fs.StringVar(&opts.credentials.accessKeyID, "s3proxy.access-id", "", "ACCESS_KEY_ID for MinIO storage")
+	fs.StringVar(&opts.credentials.secretAccessKey, "s3proxy.secret-key", "", "SECRET_ACCESS_KEY for MinIO storage")
+	_ = fs.MarkDeprecated("s3proxy.endpoint", "use --src-endpoint instead")
+	_ = fs.MarkDeprecated("s3proxy.bucket", "use --src-bucket instead")
+	_ = fs.MarkDeprecated("s3proxy.access-id", "use --src-credentials-file instead")
+	_ = fs.MarkDeprecated("s3proxy.secret-key", "use --src-credentials-file instead")
+
+	cmd.MarkFlagsMutuallyExclusive("s3proxy.endpoint", "src-bucket-url")
+	cmd.MarkFlagsMutuallyExclusive("s3proxy.endpoint", "src-endpoint")
+	cmd.MarkFlagsRequiredTogether("s3proxy.endpoint", "s3proxy.bucket", "s3proxy.access-id", "s3proxy.secret-key")
+}
+
+// String identifies the bucket, i.e. in the checkpoint file name.
+func (b bucketOptions) String() string {
+	if b.endpoint.endpoint != "" {
+		return strings.TrimSuffix(b.endpoint.endpoint, "/") + "/" + b.endpoint.bucket
+	}
+	return b.url
+}
+
+func (b *bucketOptions) validate() error {
+	e := &b.endpoint
+	if e.endpoint == "" {
+		if e.caFile != "" || e.insecureSkipVerify {
+			return fmt.Errorf("--%s-ca-file and --%s-insecure-skip-verify require --%s-endpoint", b.side, b.side, b.side)
+		}
+		return nil
+	}
+
+	// the deprecated S3 proxy endpoint is given without scheme, HTTPS is
+	// assumed for it
+	if !strings.Contains(e.endpoint, "://") && b.credentials.accessKeyID != "" {
+		e.endpoint = "https://" + e.endpoint
+	}
+	u, err := url.Parse(e.endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid %s endpoint %q. Reason: %w", sideName(b.side), e.endpoint, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid %s endpoint %q. The scheme must be http or https", sideName(b.side), e.endpoint)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid %s endpoint %q. Host is missing", sideName(b.side), e.endpoint)
+	}
+	if u.Scheme == "http" && (e.caFile != "" || e.insecureSkipVerify) {
+		return fmt.Errorf("--%s-ca-file and --%s-insecure-skip-verify require an https endpoint", b.side, b.side)
+	}
+	if e.addressing != addressingPath && e.addressing != addressingVirtualHost {
+		return fmt.Errorf("invalid addressing style %q. Supported styles are %s and %s", e.addressing, addressingPath, addressingVirtualHost)
+	}
+	return nil
+}
+
+// openBucket opens one side of the copy.
+func openBucket(ctx context.Context, b bucketOptions) (*blob.Bucket, error) {
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+	if b.endpoint.endpoint == "" {
+		return openURLBucket(ctx, b.url, b.credentials)
+	}
+
+	e := b.endpoint
+	client, err := e.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	cfg := aws.Config{
+		Endpoint:         aws.String(e.endpoint),
+		Region:           aws.String(e.region),
+		S3ForcePathStyle: aws.Bool(e.addressing == addressingPath),
+		HTTPClient:       client,
+	}
+	sess, err := newAWSSession(b.credentials, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return s3blob.OpenBucket(ctx, sess, e.bucket, nil)
+}
+
+// httpClient returns a client that trusts the configured CA in addition to
+// the system ones.
+func (e endpointOptions) httpClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: e.insecureSkipVerify,
+	}
+	if e.caFile != "" {
+		pem, err := os.ReadFile(e.caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", e.caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
+}
+
+// newAWSSession creates a session with the given credentials. Without any,
+// the credentials are looked up in the environment.
+func newAWSSession(creds credentialOptions, cfg aws.Config) (*session.Session, error) {
+	opts := session.Options{
+		Config:            cfg,
+		SharedConfigState: session.SharedConfigEnable,
+		Profile:           creds.profile,
+	}
+	switch {
+	case creds.accessKeyID != "":
+		opts.Config.Credentials = credentials.NewStaticCredentials(creds.accessKeyID, creds.secretAccessKey, "")
+	case creds.file != "":
+		opts.SharedConfigFiles = []string{creds.file}
+		opts.Config.Credentials = credentials.NewSharedCredentials(creds.file, creds.profile)
+	case creds.profile != "":
+		// pin the credentials to the profile, so that the AWS_* variables of
+		// the environment are not used instead
+		opts.Config.Credentials = credentials.NewSharedCredentials("", creds.profile)
+	}
+
+	sess, err := session.NewSessionWithOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session. Reason: %w", err)
+	}
+	return sess, nil
+}
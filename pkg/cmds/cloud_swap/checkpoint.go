@@ -0,0 +1,199 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.bytebuilders.dev/ace/pkg/config"
+)
+
+// checkpointEntry records an object that has been copied to the destination.
+type checkpointEntry struct {
+	Key      string    `json:"key"`
+	Size     int64     `json:"size"`
+	MD5      []byte    `json:"md5,omitempty"`
+	ETag     string    `json:"etag,omitempty"`
+	CopiedAt time.Time `json:"copiedAt"`
+	// Deleted is set once the copy has been deleted from the destination
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// checkpoint is an append-only manifest of the copied objects. It is used to
+// resume an interrupted copy and to find the changed objects on repeated runs.
+type checkpoint struct {
+	mu      sync.Mutex
+	file    *os.File
+	entries map[string]checkpointEntry
+}
+
+// defaultCheckpointFile returns the manifest location for a source and
+// destination pair under the CLI state directory.
+func defaultCheckpointFile(src, dst string) (string, error) {
+	return defaultStateFile("checkpoints", src, dst)
+}
+
+// defaultStateFile returns the location of a file of the given kind, unique
+// to a source and destination pair, under the CLI state directory.
+func defaultStateFile(kind, src, dst string) (string, error) {
+	stateDir, err := config.GetStateDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(src + "\n" + dst))
+	return filepath.Join(stateDir, "cloud-swap", kind, hex.EncodeToString(sum[:8])+".jsonl"), nil
+}
+
+// checkpointPath returns the location of the checkpoint of a copy.
+func (opts *swapOptions) checkpointPath() (string, error) {
+	if opts.checkpointFile != "" {
+		return opts.checkpointFile, nil
+	}
+	return defaultCheckpointFile(opts.src.String(), opts.dst.String()+opts.filter.String())
+}
+
+// openCheckpoint opens the manifest at path. The entries of the previous runs
+// are loaded if load is true, otherwise the manifest is started anew.
+func openCheckpoint(path string, load bool) (*checkpoint, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+
+	c := &checkpoint{
+		entries: make(map[string]checkpointEntry),
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if load {
+		if err := c.load(path); err != nil {
+			return nil, err
+		}
+	} else {
+		flags |= os.O_TRUNC
+	}
+
+	file, err := os.OpenFile(path, flags, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	c.file = file
+	return c, nil
+}
+
+// readCheckpoint loads the checkpoint at path without opening it for writing,
+// as for a dry run.
+func readCheckpoint(path string) (*checkpoint, error) {
+	c := &checkpoint{
+		entries: make(map[string]checkpointEntry),
+	}
+	if err := c.load(path); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *checkpoint) load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var entry checkpointEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			// the last line may be incomplete if the previous run was killed
+			continue
+		}
+		if entry.Deleted {
+			delete(c.entries, entry.Key)
+			continue
+		}
+		c.entries[entry.Key] = entry
+	}
+	return scanner.Err()
+}
+
+func (c *checkpoint) lookup(key string) (checkpointEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	return entry, ok
+}
+
+// keys returns the source keys of the objects recorded.
+func (c *checkpoint) keys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (c *checkpoint) record(obj objectInfo) error {
+	return c.write(checkpointEntry{
+		Key:      obj.Key,
+		Size:     obj.Size,
+		MD5:      obj.MD5,
+		ETag:     obj.ETag,
+		CopiedAt: time.Now(),
+	})
+}
+
+// forget records that the copy of an object has been deleted from the
+// destination.
+func (c *checkpoint) forget(key string) error {
+	return c.write(checkpointEntry{Key: key, Deleted: true})
+}
+
+func (c *checkpoint) write(entry checkpointEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry.Deleted {
+		delete(c.entries, entry.Key)
+	} else {
+		c.entries[entry.Key] = entry
+	}
+	_, err = c.file.Write(append(data, '\n'))
+	return err
+}
+
+func (c *checkpoint) close() error {
+	return c.file.Close()
+}
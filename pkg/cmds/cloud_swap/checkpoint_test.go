@@ -0,0 +1,143 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestCheckpointLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoints", "checkpoint.jsonl")
+	c, err := openCheckpoint(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range []objectInfo{
+		{Key: "a.txt", Size: 1, MD5: []byte{1}},
+		{Key: "b.txt", Size: 2, ETag: `"b"`},
+		{Key: "c.txt", Size: 3},
+		{Key: "a.txt", Size: 4, MD5: []byte{4}},
+	} {
+		if err := c.record(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.forget("c.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.close(); err != nil {
+		t.Fatal(err)
+	}
+	// a killed run leaves the last line incomplete
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(`{"key":"d.txt","si`); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = readCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := c.keys()
+	sort.Strings(keys)
+	if want := []string{"a.txt", "b.txt"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+	if entry, _ := c.lookup("a.txt"); entry.Size != 4 || !reflect.DeepEqual(entry.MD5, []byte{4}) {
+		t.Errorf("a.txt = %+v, want the last entry recorded", entry)
+	}
+
+	c, err = openCheckpoint(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.close()
+	if keys := c.keys(); len(keys) != 0 {
+		t.Errorf("keys = %v, want none when the manifest is started anew", keys)
+	}
+}
+
+func TestReadCheckpointMissing(t *testing.T) {
+	c, err := readCheckpoint(filepath.Join(t.TempDir(), "missing.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := c.keys(); len(keys) != 0 {
+		t.Errorf("keys = %v, want none", keys)
+	}
+}
+
+func TestResumeSkipsRecordedObjects(t *testing.T) {
+	src, dst := newTestBucket(t), newTestBucket(t)
+	src.write(t, map[string]string{
+		"a.txt": "a",
+		"b.txt": "b",
+	})
+	opts := testSwapOptions(t, src, dst)
+	runTestCopy(t, opts)
+
+	src.write(t, map[string]string{
+		"c.txt": "c",
+	})
+	opts.resume = true
+	stats, counted := runTestCopy(t, opts)
+	if stats.copied != 1 || stats.skipped != 2 {
+		t.Errorf("stats = %+v, want 1 copied and 2 skipped", stats)
+	}
+	if n := counted.opened.Load(); n != 1 {
+		t.Errorf("read %d objects from the source, want 1", n)
+	}
+	want := map[string]string{"a.txt": "a", "b.txt": "b", "c.txt": "c"}
+	if got := dst.contents(t); !reflect.DeepEqual(got, want) {
+		t.Errorf("destination = %v, want %v", got, want)
+	}
+}
+
+func TestIncrementalCopiesChangedObjects(t *testing.T) {
+	src, dst := newTestBucket(t), newTestBucket(t)
+	src.write(t, map[string]string{
+		"a.txt": "a",
+		"b.txt": "b",
+	})
+	opts := testSwapOptions(t, src, dst)
+	runTestCopy(t, opts)
+
+	src.write(t, map[string]string{
+		"b.txt": "changed",
+	})
+	opts.incremental = true
+	stats, counted := runTestCopy(t, opts)
+	if stats.copied != 1 || stats.skipped != 1 {
+		t.Errorf("stats = %+v, want 1 copied and 1 skipped", stats)
+	}
+	if n := counted.opened.Load(); n != 1 {
+		t.Errorf("read %d objects from the source, want 1", n)
+	}
+	if got := dst.contents(t)["b.txt"]; got != "changed" {
+		t.Errorf("b.txt = %q, want the changed content", got)
+	}
+}
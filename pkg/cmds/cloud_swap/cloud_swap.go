@@ -19,39 +19,72 @@ package cloud_swap
 import (
 	"context"
 	"fmt"
-	"log"
-	"strings"
+	"io"
+	"os"
 
-	"github.com/minio/minio-go/v7"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/cobra"
-	"gocloud.dev/blob"
 	_ "gocloud.dev/blob/azureblob"
-	"gocloud.dev/blob/fileblob"
 	_ "gocloud.dev/blob/fileblob"
 	_ "gocloud.dev/blob/gcsblob"
 	_ "gocloud.dev/blob/s3blob"
-	"gomodules.xyz/blobfs"
+	"golang.org/x/time/rate"
 )
 
-var (
-	srcBucketURL       string
-	dstBucketURL       string
+type swapOptions struct {
+	src                bucketOptions
+	dst                bucketOptions
+	destination        destinationOptions
 	localBackupDir     string
 	disableLocalBackup bool
+	localBackupFormat  string
+	encryption         encryptionOptions
+	partSize           string
+	concurrency        int
+	retries            int
+	maxErrors          int
+	errorReport        string
+	onlyKeysFrom       string
+	bandwidthLimit     string
+	metricsAddr        string
+	resume             bool
+	incremental        bool
+	checkpointFile     string
+	verify             bool
+	verifyOutput       string
+	dryRun             bool
+	throughput         string
+	filter             filterOptions
+	mode               string
+	delete             bool
+	yes                bool
+	metadata           metadataOptions
+	file               string
+	reportFile         string
+	allVersions        bool
+	serverSideCopy     bool
+	versionManifest    string
+	// restore is set when the source is a local backup being restored, so
+	// that its manifest is not uploaded
+	restore bool
 
-	ms struct {
-		bucket    string
-		endpoint  string
-		accessID  string
-		secretKey string
-	}
+	// out receives the messages of the copy, os.Stdout if not set. The pairs
+	// of a migration set it along with their metrics registerer and the
+	// bandwidth limiter they share.
+	out        io.Writer
+	registerer prometheus.Registerer
+	limiter    *rate.Limiter
+}
 
-	mc                    *minio.Client
-	srcBucket             *blob.Bucket
-	srcFS, dstFS, localFS blobfs.Interface
-)
+func (opts *swapOptions) output() io.Writer {
+	if opts.out == nil {
+		return os.Stdout
+	}
+	return opts.out
+}
 
 func NewCmdCloudSwap() *cobra.Command {
+	opts := &swapOptions{}
 	cmd := &cobra.Command{
 		Use:   "cloud-swap",
 		Short: "Copy data from one cloud storage to another",
@@ -64,51 +97,226 @@ export AWS_SECRET_ACCESS_KEY=<s3-bucket-secret-access-key>
 # Now copy the files
 ace cloud-swap --src-bucket-url="gs://<google-bucket-name>" \
     --dst-bucket-url="s3://<s3-compatible-bucket>?region=<us-east-1>&endpoint=<bucket-endpoint>"
+
+# Copy between two AWS accounts, giving each bucket its own credentials
+ace cloud-swap --src-bucket-url="s3://<old-bucket>?region=<us-east-1>" --src-profile=<old-account> \
+    --dst-bucket-url="s3://<new-bucket>?region=<us-east-1>" --dst-credentials-file=<aws-credentials-path>
+
+# Copy between two MinIO servers
+ace cloud-swap --src-endpoint=https://minio-old.example.com:9000 --src-bucket=ace \
+    --src-credentials-file=<old-minio-credentials-path> --src-ca-file=<old-minio-ca-path> \
+    --dst-endpoint=http://minio-new.example.com:9000 --dst-bucket=ace \
+    --dst-credentials-file=<new-minio-credentials-path>
+
+# Keep the local backup as encrypted archives, readable with the age identity of the recipient
+ace cloud-swap --src-bucket-url="gs://<google-bucket-name>" \
+    --dst-bucket-url="s3://<s3-compatible-bucket>?region=<us-east-1>&endpoint=<bucket-endpoint>" \
+    --local-backup-format=tar.zst --local-backup-encrypt --local-backup-recipient=<age1...>
+
+# Mirror the source, removing the objects that were deleted from it since the previous run
+ace cloud-swap --mode=sync --delete --src-bucket-url="gs://<google-bucket-name>" \
+    --dst-bucket-url="s3://<s3-compatible-bucket>?region=<us-east-1>&endpoint=<bucket-endpoint>"
+
+# Copy the buckets listed in a migration file, two pairs at a time
+cat > migration.yaml <<EOF
+parallelism: 2
+pairs:
+- name: registry
+  source:
+    url: gs://<registry-bucket>
+  destination:
+    endpoint: https://minio.example.com:9000
+    bucket: registry
+    credentialsFile: <minio-credentials-path>
+  concurrency: 8
+  verify: true
+- name: backups
+  source:
+    url: s3://<backup-bucket>?region=<us-east-1>
+    profile: <old-account>
+  destination:
+    endpoint: https://minio.example.com:9000
+    bucket: backups
+    credentialsFile: <minio-credentials-path>
+  filter:
+    include: ["**/*.tar.gz"]
+  localBackup:
+    disabled: true
+EOF
+ace cloud-swap -f migration.yaml --report-file=report.json
+
+# Copy the history of a versioned bucket to a bucket with versioning enabled
+ace cloud-swap --all-versions --src-bucket-url="s3://<versioned-bucket>?region=<us-east-1>" \
+    --dst-endpoint=https://minio.example.com:9000 --dst-bucket=<versioned-bucket> \
+    --dst-credentials-file=<minio-credentials-path>
+
+# Store the copies in the infrequent access class, encrypted with a KMS key
+ace cloud-swap --src-bucket-url="gs://<google-bucket-name>" \
+    --dst-bucket-url="s3://<s3-bucket>?region=<us-east-1>" \
+    --dst-storage-class=STANDARD_IA --dst-sse=kms --dst-sse-kms-key-id=<kms-key-arn>
+
+# Keep copying when up to 100 files fail, then copy the failed files again
+ace cloud-swap --max-errors=100 --error-report=errors.jsonl --src-bucket-url="gs://<google-bucket-name>" \
+    --dst-bucket-url="s3://<s3-compatible-bucket>?region=<us-east-1>&endpoint=<bucket-endpoint>"
+ace cloud-swap --only-keys-from=errors.jsonl --src-bucket-url="gs://<google-bucket-name>" \
+    --dst-bucket-url="s3://<s3-compatible-bucket>?region=<us-east-1>&endpoint=<bucket-endpoint>"
 `,
 		DisableAutoGenTag: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return copyDataToDestination(cmd.Context())
+			// the flags are valid at this point, so the usage does not help
+			cmd.SilenceUsage = true
+			if opts.file != "" {
+				return runMigration(cmd.Context(), opts)
+			}
+			if opts.dryRun {
+				return planCopy(cmd.Context(), opts)
+			}
+			return copyDataToDestination(cmd.Context(), opts)
 		},
 	}
 
-	cmd.Flags().StringVar(&ms.endpoint, "s3proxy.endpoint", "", "S3proxy storage endpoint")
-	cmd.Flags().StringVar(&ms.bucket, "s3proxy.bucket", "", "MinIO storage bucket name")
-	cmd.Flags().StringVar(&ms.accessID, "s3proxy.access-id", "", "ACCESS_KEY_ID for MinIO storage")
-	cmd.Flags().StringVar(&ms.secretKey, "s3proxy.secret-key", "", "SECRET_ACCESS_KEY for MinIO storage")
-
-	cmd.Flags().StringVar(&srcBucketURL, "src-bucket-url", "", "Complete source-bucket url with scheme, region, endpoints")
-	cmd.Flags().StringVar(&dstBucketURL, "dst-bucket-url", "", "Complete destination-bucket url with scheme, region, endpoints")
-	cmd.Flags().StringVar(&localBackupDir, "local-backup-dir", localDefaultDir(), "Temporary local backup")
-	cmd.Flags().BoolVar(&disableLocalBackup, "disable-local-backup", false, "Disable local backup")
-	if err := cmd.MarkFlagRequired("dst-bucket-url"); err != nil {
-		log.Fatal(err)
+	addBucketFlags(cmd, "src", &opts.src)
+	addBucketFlags(cmd, "dst", &opts.dst)
+	addS3ProxyFlags(cmd, &opts.src)
+	addDestinationFlags(cmd.Flags(), &opts.destination)
+	cmd.Flags().StringVarP(&opts.file, "file", "f", "", "Migration file listing several source and destination pairs to copy. The other flags apply to every pair, unless the pair sets its own value.")
+	cmd.Flags().StringVar(&opts.reportFile, "report-file", "", "Path to write the consolidated report of the migration file to, as JSON")
+	cmd.MarkFlagsOneRequired("src-bucket-url", "src-endpoint", "s3proxy.endpoint", "file")
+	cmd.MarkFlagsOneRequired("dst-bucket-url", "dst-endpoint", "file")
+	cmd.Flags().StringVar(&opts.localBackupDir, "local-backup-dir", localDefaultDir(), "Temporary local backup")
+	cmd.Flags().BoolVar(&opts.disableLocalBackup, "disable-local-backup", false, "Disable local backup")
+	cmd.Flags().StringVar(&opts.localBackupFormat, "local-backup-format", backupFormatDir, "Format of the local backup (any of dir,tar.zst). The tar.zst backup is written as zstd compressed tar archives, one per concurrent worker.")
+	addEncryptionFlags(cmd.Flags(), &opts.encryption)
+	cmd.Flags().StringVar(&opts.partSize, "part-size", defaultPartSize, "Size of the parts used to upload large objects (i.e. 16MiB). Objects larger than this are uploaded in multiple parts.")
+	cmd.Flags().BoolVar(&opts.serverSideCopy, "server-side-copy", true, "Copy the files within the provider when both buckets are on the same S3 compatible endpoint or in GCS, instead of streaming them through this machine. It needs --disable-local-backup, and falls back to streaming if the provider refuses the copy. --bandwidth-limit doesn't apply to it.")
+	cmd.Flags().IntVar(&opts.concurrency, "concurrency", 4, "Number of objects copied in parallel. Each worker buffers up to two parts in memory, so the copy uses up to concurrency x 2 x part-size of memory (i.e. 128MiB with 4 workers and 16MiB parts).")
+	cmd.Flags().IntVar(&opts.retries, "retries", 3, "Number of times a failed object copy is retried before giving up. Only transient failures, like throttling or server errors, are retried.")
+	cmd.Flags().IntVar(&opts.maxErrors, "max-errors", 0, "Number of objects that may fail to copy before the copy is aborted, or -1 for no limit. The failed objects are written to the error report.")
+	cmd.Flags().StringVar(&opts.errorReport, "error-report", "", "Path of the error report listing the key, operation and error of the objects that failed to copy. Default is a file under the CLI state directory, unique to the source and destination.")
+	cmd.Flags().StringVar(&opts.bandwidthLimit, "bandwidth-limit", "", "Maximum rate the objects are read from the source at, in bytes per second, shared by all the workers (i.e. 10MiB). No limit by default.")
+	cmd.Flags().StringVar(&opts.metricsAddr, "metrics-addr", "", "Address to expose Prometheus metrics of the copy at, under /metrics (i.e. :9090)")
+	cmd.Flags().StringVar(&opts.onlyKeysFrom, "only-keys-from", "", "Copy only the source keys listed in this file, one per line. An error report can be given to copy the objects that failed in a previous run.")
+	cmd.Flags().BoolVar(&opts.resume, "resume", false, "Resume an interrupted copy. Objects recorded in the checkpoint manifest or already present at the destination are skipped.")
+	cmd.Flags().BoolVar(&opts.incremental, "incremental", false, "Copy only the objects that are new or have changed since the previous run")
+	cmd.Flags().StringVar(&opts.checkpointFile, "checkpoint-file", "", "Path of the checkpoint manifest. Default is a file under the CLI state directory, unique to the source and destination.")
+	cmd.Flags().BoolVar(&opts.allVersions, "all-versions", false, "Copy every version of the objects of an S3 compatible or GCS source, oldest first, to a destination with versioning enabled. Delete markers delete the object at the destination.")
+	cmd.Flags().StringVar(&opts.versionManifest, "version-manifest", "", "Path of the manifest mapping the source versions of every object to the destination versions, written with --all-versions. Default is a file under the CLI state directory, unique to the source and destination.")
+	cmd.Flags().BoolVar(&opts.verify, "verify", false, "Verify that the destination matches the source after copying")
+	cmd.Flags().StringVar(&opts.verifyOutput, "verify-output", "table", "Output format of the verification report (any of table,json)")
+	addFilterFlags(cmd.Flags(), &opts.filter)
+	addMetadataFlags(cmd.Flags(), &opts.metadata)
+	cmd.Flags().StringVar(&opts.mode, "mode", modeCopy, "Copy mode (any of copy,sync). In sync mode, only new or changed objects are copied and the destination objects missing at the source are reported.")
+	cmd.Flags().BoolVar(&opts.delete, "delete", false, "Delete the destination objects missing at the source. Requires --mode=sync. With --rename, only the objects copied by the previous runs are deleted.")
+	cmd.Flags().BoolVarP(&opts.yes, "yes", "y", false, "Delete without asking for confirmation")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Print the copy plan without writing anything. The destination listing is held in memory while planning.")
+	cmd.Flags().StringVar(&opts.throughput, "throughput", defaultThroughput, "Expected transfer rate per second used to estimate the copy time in dry run (i.e. 50MiB)")
+
+	cmd.MarkFlagsMutuallyExclusive("resume", "incremental")
+	cmd.MarkFlagsMutuallyExclusive("mode", "resume")
+	cmd.MarkFlagsMutuallyExclusive("mode", "incremental")
+	cmd.MarkFlagsMutuallyExclusive("local-backup-recipient", "local-backup-passphrase-file")
+	cmd.MarkFlagsMutuallyExclusive("dst-sse", "dst-sse-c-key-file")
+	// the buckets and the state files are set for each pair of the migration
+	for _, name := range []string{"src-bucket-url", "src-endpoint", "s3proxy.endpoint", "dst-bucket-url", "dst-endpoint", "checkpoint-file", "error-report", "version-manifest", "only-keys-from"} {
+		cmd.MarkFlagsMutuallyExclusive("file", name)
 	}
 
-	cmd.MarkFlagsMutuallyExclusive("s3proxy.endpoint", "src-bucket-url")
-	cmd.MarkFlagsRequiredTogether("s3proxy.endpoint", "minio.bucket", "minio.access-id", "minio.secret-key")
+	cmd.AddCommand(newCmdVerify())
+	cmd.AddCommand(newCmdRestore())
 
 	return cmd
 }
 
-func copyDataToDestination(ctx context.Context) error {
-	err := initiateBuckets(ctx)
+func copyDataToDestination(ctx context.Context, opts *swapOptions) error {
+	if opts.metricsAddr != "" {
+		registry := prometheus.NewRegistry()
+		opts.registerer = registry
+		metricsCtx, stopMetrics := context.WithCancel(ctx)
+		defer stopMetrics()
+		if err := serveMetrics(metricsCtx, opts.metricsAddr, registry); err != nil {
+			return err
+		}
+	}
+	_, err := runCopy(ctx, opts)
+	return err
+}
+
+// runResult is the outcome of a copy run, filled as far as the copy got.
+type runResult struct {
+	stats       copyStats
+	errorReport string
+	// verification is set if the destination was verified
+	verification *verifyReport
+}
+
+// runCopy copies the source to the destination, then deletes the stale
+// destination objects in sync mode and verifies the destination if asked.
+func runCopy(ctx context.Context, opts *swapOptions) (*runResult, error) {
+	result := &runResult{}
+	s, err := newSwapper(ctx, opts)
 	if err != nil {
-		return err
+		return result, err
 	}
+	defer s.close()
 
-	fmt.Printf("Copying files to destination storage ...\n\n")
+	if err := s.openErrorReport(opts); err != nil {
+		return result, err
+	}
+	result.errorReport = s.report.path
 
-	if mc != nil {
-		if err = copyFromMinio(ctx); err != nil {
-			return err
+	fmt.Fprintf(s.out, "Copying files to destination storage ...\n\n")
+
+	stats, err := s.copyAll(ctx)
+	result.stats = stats
+	if err != nil {
+		if ctx.Err() != nil {
+			return result, fmt.Errorf("file copying interrupted by user. Run again with --resume to continue from where it stopped")
 		}
-	} else {
-		if err = copyFromSrc(ctx); err != nil {
-			return err
+		if stats.failed > 0 {
+			fmt.Fprintf(s.out, "\nThe failed files are listed in %s\n", s.report.path)
 		}
+		return result, err
+	}
+	if stats.failed > 0 {
+		fmt.Fprintf(s.out, "\n%d files copied, %d files skipped, %d files failed\n", stats.copied, stats.skipped, stats.failed)
+		return result, fmt.Errorf("%d files failed to copy. They are listed in %s. Run again with --only-keys-from=%s to retry them", stats.failed, s.report.path, s.report.path)
 	}
 
-	fmt.Println("\nFile copying completed successfully!")
-	fmt.Printf("A local copy can be found in `%s` directory\n", strings.TrimPrefix(localBackupDir, fmt.Sprintf("%s://", fileblob.Scheme)))
-	return nil
+	local := s.local != nil
+	if err := s.finishBackup(); err != nil {
+		return result, err
+	}
+
+	fmt.Fprintln(s.out, "\nFile copying completed successfully!")
+	fmt.Fprintf(s.out, "%d files copied, %d files skipped\n", stats.copied, stats.skipped)
+	if local {
+		fmt.Fprintf(s.out, "A local copy can be found in `%s` directory\n", opts.localBackupDir)
+	}
+	if s.versions != nil {
+		fmt.Fprintf(s.out, "%d versions replayed. The version manifest is written to %s\n", s.versions.recorded, s.versions.path)
+	}
+
+	if s.sync {
+		if err := s.deleteStale(ctx, opts); err != nil {
+			return result, err
+		}
+	}
+
+	if opts.verify {
+		v := &verifier{
+			src:         s.src,
+			dst:         &bucketSource{bucket: s.dst},
+			mapper:      s.mapper,
+			concurrency: s.concurrency,
+			keysOnly:    opts.onlyKeysFrom != "",
+			out:         s.out,
+			log:         s.out,
+		}
+		if opts.out == nil {
+			v.log = os.Stderr
+		}
+		result.verification, err = v.verify(ctx, opts.verifyOutput)
+		return result, err
+	}
+	return result, nil
 }
@@ -0,0 +1,439 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+)
+
+// progressWindow bounds how many objects, per worker, can be listed ahead of
+// the oldest object that has not been reported yet. It keeps the memory used
+// for ordering the progress output bounded.
+const progressWindow = 16
+
+type copyTask struct {
+	index int
+	obj   objectInfo
+}
+
+type copyResult struct {
+	copyTask
+	skipped bool
+	err     error
+}
+
+// copyStats summarizes a copy run.
+type copyStats struct {
+	copied  int
+	skipped int
+	failed  int
+}
+
+// copyAll copies the objects using a pool of workers. The progress is
+// reported in the order the objects were listed. The objects that fail to
+// copy are written to the error report, until more than maxErrors have
+// failed and the copy is aborted.
+func (s *swapper) copyAll(ctx context.Context) (copyStats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.progress.start()
+	defer s.progress.stop()
+
+	g, ctx := errgroup.WithContext(ctx)
+	window := semaphore.NewWeighted(int64(s.concurrency * progressWindow))
+	tasks := make(chan copyTask, s.concurrency)
+	results := make(chan copyResult, s.concurrency)
+
+	g.Go(func() error {
+		defer close(tasks)
+		index := 0
+		err := s.src.list(ctx, "", func(obj objectInfo) error {
+			if err := window.Acquire(ctx, 1); err != nil {
+				return err
+			}
+			index += 1
+			s.progress.listed(obj.Size)
+			s.metrics.listed(obj.Size)
+			if s.sync {
+				s.seen.add(s.mapper.dstKey(obj.Key))
+			}
+			select {
+			case tasks <- copyTask{index: index, obj: obj}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err == nil {
+			s.progress.listingDone()
+		}
+		return err
+	})
+
+	workers := sync.WaitGroup{}
+	for i := 0; i < s.concurrency; i++ {
+		workers.Add(1)
+		g.Go(func() error {
+			defer workers.Done()
+			for task := range tasks {
+				res := copyResult{copyTask: task}
+				if s.versions != nil {
+					res.skipped, res.err = s.replayVersions(ctx, task.obj)
+				} else {
+					res.skipped, res.err = s.syncObject(ctx, task.obj)
+				}
+				select {
+				case results <- res:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		})
+	}
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	var stats copyStats
+	var copyErr error
+	pending := make(map[int]copyResult)
+	next := 1
+	for res := range results {
+		pending[res.index] = res
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next += 1
+			window.Release(1)
+
+			name := r.obj.Key
+			if dstKey := s.mapper.dstKey(r.obj.Key); dstKey != name {
+				name = fmt.Sprintf("%s -> %s", name, dstKey)
+			}
+			if n := len(r.obj.Versions); n > 0 {
+				name = fmt.Sprintf("%s (%d versions)", name, n)
+			}
+			if r.err != nil {
+				if copyErr == nil && ctx.Err() == nil {
+					stats.failed += 1
+					s.progress.finished(r.obj.Size, false)
+					s.metrics.finished("failed")
+					s.progress.printf("%6d. %s (failed)\n", r.index, name)
+					copyErr = s.recordFailure(r.obj.Key, r.err, stats.failed)
+					if copyErr != nil {
+						cancel()
+					}
+				}
+				continue
+			}
+			if r.skipped {
+				stats.skipped += 1
+				s.progress.finished(r.obj.Size, false)
+				s.metrics.finished("skipped")
+				s.progress.printf("%6d. %s (skipped, already copied)\n", r.index, name)
+				continue
+			}
+			stats.copied += 1
+			s.progress.finished(r.obj.Size, true)
+			s.metrics.finished("copied")
+			s.progress.printf("%6d. %s\n", r.index, name)
+		}
+	}
+
+	if err := g.Wait(); copyErr == nil {
+		copyErr = err
+	}
+	return stats, copyErr
+}
+
+// recordFailure writes a failed object to the error report. It returns an
+// error if the copy has to be aborted, because failed objects exceed the
+// error budget.
+func (s *swapper) recordFailure(key string, failure error, failed int) error {
+	if s.report == nil {
+		return failure
+	}
+	if err := s.report.add(key, failure); err != nil {
+		return fmt.Errorf("failed to write error report. Reason: %w", err)
+	}
+	if s.maxErrors >= 0 && failed > s.maxErrors {
+		if s.maxErrors == 0 {
+			return failure
+		}
+		return fmt.Errorf("%d objects failed to copy, more than --max-errors=%d. Last error: %w", failed, s.maxErrors, failure)
+	}
+	s.progress.printf("        %s: %v\n", errorOp(failure), failure)
+	return nil
+}
+
+// syncObject copies an object unless it can be skipped because it has been
+// copied already. The copied objects are recorded in the checkpoint.
+func (s *swapper) syncObject(ctx context.Context, obj objectInfo) (bool, error) {
+	skip, err := s.isCopied(ctx, &obj)
+	if err != nil {
+		return false, err
+	}
+	if skip {
+		return true, nil
+	}
+
+	if err := s.copyObjectWithRetry(ctx, obj); err != nil {
+		return false, err
+	}
+	if s.checkpoint != nil {
+		if err := s.checkpoint.record(obj); err != nil {
+			return false, withOp(opCheckpoint, errors.Wrapf(err, "record %s in checkpoint", obj.Key))
+		}
+	}
+	return false, nil
+}
+
+// isCopied reports whether obj is already present at the destination. On
+// resume, the objects recorded in the checkpoint are trusted. In incremental
+// mode, they are trusted only if the source object hasn't changed since.
+// Otherwise, the object is compared with the destination by size and checksum,
+// which is always the case in sync mode. When either side has no MD5 (i.e.
+// multipart uploads), the recorded objects that haven't changed at either side
+// since are trusted rather than hashed.
+func (s *swapper) isCopied(ctx context.Context, obj *objectInfo) (bool, error) {
+	if !s.resume && !s.incremental && !s.sync {
+		return false, nil
+	}
+	if len(obj.MD5) == 0 && obj.ETag == "" {
+		// some providers don't return the checksums while listing
+		start := time.Now()
+		info, err := s.src.stat(ctx, obj.Key)
+		s.metrics.observe(sideSource, "stat", start)
+		if err != nil {
+			return false, withOp(opStatSource, errors.Wrapf(err, "read attributes of %s from source", obj.Key))
+		}
+		obj.MD5, obj.ETag, obj.Meta = info.MD5, info.ETag, info.Meta
+	}
+	entry, recorded := s.checkpoint.lookup(obj.Key)
+	if recorded && !s.sync {
+		if s.resume {
+			return true, nil
+		}
+		if obj.sameContent(entry.Size, entry.MD5, entry.ETag) {
+			return true, nil
+		}
+	}
+
+	start := time.Now()
+	attrs, err := s.dst.Attributes(ctx, s.mapper.dstKey(obj.Key))
+	s.metrics.observe(sideDestination, "stat", start)
+	if gcerrors.Code(err) == gcerrors.NotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, withOp(opStatDestination, errors.Wrapf(err, "read attributes of %s from destination", obj.Key))
+	}
+	if obj.Size == attrs.Size && (len(obj.MD5) == 0 || len(attrs.MD5) == 0) {
+		// the destination object is written before it is recorded, so it has
+		// been overwritten since if it is newer
+		if recorded && obj.sameContent(entry.Size, entry.MD5, entry.ETag) && !attrs.ModTime.After(entry.CopiedAt) {
+			return true, nil
+		}
+		return s.sameHash(ctx, *obj, attrs.MD5)
+	}
+	return obj.sameContent(attrs.Size, attrs.MD5, attrs.ETag), nil
+}
+
+// sameHash compares the content of a source object with its copy when the
+// checksum of either side is not exposed by the provider, by hashing the
+// side that lacks one.
+func (s *swapper) sameHash(ctx context.Context, obj objectInfo, dstMD5 []byte) (bool, error) {
+	return sameHash(ctx, s.src, obj, &bucketSource{bucket: s.dst}, s.mapper.dstKey(obj.Key), dstMD5)
+}
+
+func sameHash(ctx context.Context, src objectSource, obj objectInfo, dst objectSource, dstKey string, dstMD5 []byte) (bool, error) {
+	var err error
+	srcMD5 := obj.MD5
+	if len(srcMD5) == 0 {
+		if srcMD5, err = hashObject(ctx, src, obj.Key); err != nil {
+			return false, withOp(opReadSource, errors.Wrapf(err, "hash %s in source", obj.Key))
+		}
+	}
+	if len(dstMD5) == 0 {
+		if dstMD5, err = hashObject(ctx, dst, dstKey); err != nil {
+			return false, withOp(opReadDestination, errors.Wrapf(err, "hash %s in destination", dstKey))
+		}
+	}
+	return bytes.Equal(srcMD5, dstMD5), nil
+}
+
+// copyObjectWithRetry copies an object, retrying on failure.
+func (s *swapper) copyObjectWithRetry(ctx context.Context, obj objectInfo) error {
+	return s.withRetry(ctx, obj.Key, func(int) error {
+		return s.copyObject(ctx, obj)
+	})
+}
+
+// withRetry calls fn until it succeeds, retrying the retryable failures with
+// an exponential backoff. fn is given the number of the attempt, starting
+// from 0.
+func (s *swapper) withRetry(ctx context.Context, key string, fn func(attempt int) error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = fn(attempt)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		s.metrics.failed(errorOp(err))
+		if attempt >= s.retries || !isRetryable(err) {
+			return err
+		}
+
+		delay := backoff(attempt)
+		s.progress.printf("Retrying %s in %s (attempt %d/%d). Reason: %v\n", key, delay.Round(time.Millisecond), attempt+2, s.retries+1, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// copyObject streams an object from the source to the destination. The
+// content is written to the local backup on the way through a tee, so the
+// object is never held in memory as a whole. The local backup mirrors the
+// destination keys.
+func (s *swapper) copyObject(ctx context.Context, obj objectInfo) error {
+	if obj.Meta == nil && s.metadata.needsAttributes() {
+		start := time.Now()
+		info, err := s.src.stat(ctx, obj.Key)
+		s.metrics.observe(sideSource, "stat", start)
+		if err != nil {
+			return withOp(opStatSource, errors.Wrapf(err, "read attributes of %s from source", obj.Key))
+		}
+		obj.Meta = info.Meta
+	}
+	wopts := s.metadata.writerOptions(obj)
+	if copied, err := s.serverSideCopy(ctx, obj.Key, "", obj.Size, wopts); copied || err != nil {
+		return err
+	}
+
+	start := time.Now()
+	r, err := s.src.open(ctx, obj.Key)
+	s.metrics.observe(sideSource, "open", start)
+	if err != nil {
+		return withOp(opReadSource, errors.Wrapf(err, "read file from source"))
+	}
+	defer r.Close()
+
+	size := obj.Size
+	if sr, ok := r.(interface{ Size() int64 }); ok {
+		// the object may have changed since it was listed
+		size = sr.Size()
+	}
+	return s.writeObject(ctx, s.mapper.dstKey(obj.Key), r, size, wopts)
+}
+
+// writeObject writes the content of r to the destination and the local backup
+// with the given attributes. The objects of the local backup are recorded in
+// its manifest.
+func (s *swapper) writeObject(ctx context.Context, key string, r io.Reader, size int64, wopts *blob.WriterOptions) (err error) {
+	// cancelling the context aborts the pending writes, so that no partial
+	// object is left behind on failure.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	r = &meteredReader{ctx: ctx, r: r, limiter: s.limiter, count: func(n int) {
+		s.progress.transfer(n)
+		s.metrics.transfer(n)
+	}}
+	// the failures are marked with the side they happened at
+	r = &opReader{r: r, op: opReadSource}
+	var lw localWriter
+	hash := md5.New()
+	if s.local != nil {
+		lw, err = s.local.newWriter(ctx, key, size, wopts)
+		if err != nil {
+			return withOp(opWriteBackup, errors.Wrapf(err, "write file to local backup"))
+		}
+		r = io.TeeReader(r, &opWriter{w: io.MultiWriter(lw, hash), op: opWriteBackup})
+	}
+
+	dstOpts := *wopts
+	dstOpts.BufferSize = s.partSize
+	// one part is uploaded while the next one is read, so that the memory
+	// used by a worker is bounded to two parts
+	dstOpts.MaxConcurrency = 1
+	if s.write != nil {
+		dstOpts.BeforeWrite = s.write.beforeWrite
+	}
+	start := time.Now()
+	w, err := s.dst.NewWriter(ctx, key, &dstOpts)
+	if err != nil {
+		cancel()
+		abortWriter(lw)
+		return withOp(opWriteDestination, errors.Wrapf(err, "write file to destination"))
+	}
+
+	n, err := io.Copy(&opWriter{w: w, op: opWriteDestination}, r)
+	if err != nil {
+		cancel()
+		abortWriter(lw)
+		_ = w.Close()
+		return errors.Wrapf(err, "copy file %s", key)
+	}
+
+	var rec manifestRecord
+	if lw != nil {
+		if rec, err = lw.commit(); err != nil {
+			cancel()
+			_ = w.Close()
+			return withOp(opWriteBackup, errors.Wrapf(err, "write file to local backup"))
+		}
+	}
+	err = w.Close()
+	s.metrics.observe(sideDestination, "write", start)
+	if err != nil {
+		return withOp(opWriteDestination, errors.Wrapf(err, "write file to destination"))
+	}
+	if s.manifest != nil {
+		rec.Size, rec.MD5 = n, hash.Sum(nil)
+		if err = s.manifest.record(rec); err != nil {
+			return withOp(opWriteBackup, errors.Wrapf(err, "record %s in backup manifest", key))
+		}
+	}
+	return nil
+}
+
+func abortWriter(w localWriter) {
+	if w != nil {
+		w.abort()
+	}
+}
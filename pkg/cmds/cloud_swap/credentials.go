@@ -0,0 +1,203 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/spf13/pflag"
+	"gocloud.dev/blob"
+	"gocloud.dev/blob/azureblob"
+	"gocloud.dev/blob/gcsblob"
+	"gocloud.dev/blob/s3blob"
+	"gocloud.dev/gcp"
+	"golang.org/x/oauth2/google"
+)
+
+const gcsScope = "https://www.googleapis.com/auth/devstorage.full_control"
+
+// credentialOptions configure the credentials of one side of the copy. When
+// they are empty, the credentials are read from the environment as usual.
+type credentialOptions struct {
+	file    string
+	profile string
+	// static credentials are only set by the deprecated S3 proxy flags
+	accessKeyID     string
+	secretAccessKey string
+}
+
+func (c credentialOptions) isSet() bool {
+	return c.file != "" || c.profile != "" || c.accessKeyID != ""
+}
+
+func addCredentialFlags(fs *pflag.FlagSet, side string, opts *credentialOptions) {
+	fs.StringVar(&opts.file, side+"-credentials-file", "", fmt.Sprintf("Credentials of the %s bucket. An AWS shared credentials file for s3, a service account key for gs, or a file with AZURE_STORAGE_* variables for azblob. The environment is not used for this bucket when set.", sideName(side)))
+	fs.StringVar(&opts.profile, side+"-profile", "", fmt.Sprintf("Named AWS profile used for the %s s3 bucket", sideName(side)))
+}
+
+func sideName(side string) string {
+	if side == "src" {
+		return "source"
+	}
+	return "destination"
+}
+
+// openURLBucket opens a bucket with its own credentials. A dedicated URL mux
+// is used, so that the credentials of one side are never shared with the
+// other.
+func openURLBucket(ctx context.Context, bucketURL string, creds credentialOptions) (*blob.Bucket, error) {
+	if !creds.isSet() {
+		return blob.OpenBucket(ctx, bucketURL)
+	}
+
+	u, err := url.Parse(bucketURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bucket url %q. Reason: %w", bucketURL, err)
+	}
+	if (creds.profile != "" || creds.accessKeyID != "") && u.Scheme != s3blob.Scheme {
+		return nil, fmt.Errorf("profiles are only supported for %s buckets", s3blob.Scheme)
+	}
+
+	mux := new(blob.URLMux)
+	switch u.Scheme {
+	case s3blob.Scheme:
+		opener, err := newS3Opener(creds)
+		if err != nil {
+			return nil, err
+		}
+		// the profile is configured in the session, and the session is built
+		// with AWS SDK v1
+		q := u.Query()
+		q.Del("profile")
+		q.Del("awssdk")
+		u.RawQuery = q.Encode()
+		mux.RegisterBucket(s3blob.Scheme, opener)
+	case gcsblob.Scheme:
+		opener, err := newGCSOpener(ctx, creds)
+		if err != nil {
+			return nil, err
+		}
+		mux.RegisterBucket(gcsblob.Scheme, opener)
+	case azureblob.Scheme:
+		opener, err := newAzureOpener(creds)
+		if err != nil {
+			return nil, err
+		}
+		mux.RegisterBucket(azureblob.Scheme, opener)
+	default:
+		return nil, fmt.Errorf("credentials can't be configured for %q buckets", u.Scheme)
+	}
+	return mux.OpenBucket(ctx, u.String())
+}
+
+func newS3Opener(creds credentialOptions) (*s3blob.URLOpener, error) {
+	sess, err := newAWSSession(creds, aws.Config{})
+	if err != nil {
+		return nil, err
+	}
+	return &s3blob.URLOpener{ConfigProvider: sess}, nil
+}
+
+func newGCSOpener(ctx context.Context, creds credentialOptions) (*gcsblob.URLOpener, error) {
+	data, err := os.ReadFile(creds.file)
+	if err != nil {
+		return nil, err
+	}
+	gcreds, err := google.CredentialsFromJSON(ctx, data, gcsScope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GCP credentials %s. Reason: %w", creds.file, err)
+	}
+	client, err := gcp.NewHTTPClient(gcp.DefaultTransport(), gcp.CredentialsTokenSource(gcreds))
+	if err != nil {
+		return nil, err
+	}
+	return &gcsblob.URLOpener{Client: client}, nil
+}
+
+func newAzureOpener(creds credentialOptions) (*azureblob.URLOpener, error) {
+	env, err := readEnvFile(creds.file)
+	if err != nil {
+		return nil, err
+	}
+	account := env["AZURE_STORAGE_ACCOUNT"]
+	key := env["AZURE_STORAGE_KEY"]
+	sasToken := env["AZURE_STORAGE_SAS_TOKEN"]
+	connectionString := env["AZURE_STORAGE_CONNECTION_STRING"]
+
+	makeClient := func(svcURL azureblob.ServiceURL, containerName azureblob.ContainerName) (*container.Client, error) {
+		containerURL, err := url.JoinPath(string(svcURL), string(containerName))
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case account != "" && key != "":
+			cred, err := azblob.NewSharedKeyCredential(account, key)
+			if err != nil {
+				return nil, err
+			}
+			return container.NewClientWithSharedKeyCredential(containerURL, cred, nil)
+		case sasToken != "":
+			return container.NewClientWithNoCredential(containerURL, nil)
+		case connectionString != "":
+			return container.NewClientFromConnectionString(connectionString, string(containerName), nil)
+		default:
+			return nil, fmt.Errorf("no Azure credentials found in %s", creds.file)
+		}
+	}
+
+	return &azureblob.URLOpener{
+		MakeClient: makeClient,
+		ServiceURLOptions: azureblob.ServiceURLOptions{
+			AccountName:   account,
+			SASToken:      sasToken,
+			StorageDomain: env["AZURE_STORAGE_DOMAIN"],
+			Protocol:      env["AZURE_STORAGE_PROTOCOL"],
+		},
+	}, nil
+}
+
+// readEnvFile reads a file of KEY=value lines. Empty lines and lines starting
+// with '#' are ignored.
+func readEnvFile(name string) (map[string]string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, val, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid line %q in %s", line, name)
+		}
+		env[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(val), `"'`)
+	}
+	return env, scanner.Err()
+}
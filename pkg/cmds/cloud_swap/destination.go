@@ -0,0 +1,247 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/rs/xid"
+	"github.com/spf13/pflag"
+	"gocloud.dev/blob"
+)
+
+const (
+	sseAES256 = "AES256"
+	sseKMS    = "kms"
+)
+
+// gcsStorageClasses are the storage classes objects can be written with in
+// GCS.
+var gcsStorageClasses = []string{"STANDARD", "NEARLINE", "COLDLINE", "ARCHIVE"}
+
+// destinationOptions choose how the objects are stored at the destination.
+type destinationOptions struct {
+	storageClass string
+	sse          string
+	kmsKeyID     string
+	sseCKeyFile  string
+}
+
+func addDestinationFlags(fs *pflag.FlagSet, opts *destinationOptions) {
+	fs.StringVar(&opts.storageClass, "dst-storage-class", "", "Storage class the objects are written with at the destination (i.e. STANDARD_IA for S3, NEARLINE for GCS). Default is the storage class of the bucket.")
+	fs.StringVar(&opts.sse, "dst-sse", "", "Server-side encryption of the destination objects (any of AES256,kms). AES256 is only supported for S3. With kms, the key is given with --dst-sse-kms-key-id.")
+	fs.StringVar(&opts.kmsKeyID, "dst-sse-kms-key-id", "", "KMS key the destination objects are encrypted with, with --dst-sse=kms. An S3 key id or ARN, or a GCS key name (projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>). S3 uses the AWS managed key if not set.")
+	fs.StringVar(&opts.sseCKeyFile, "dst-sse-c-key-file", "", "File with the 256 bit customer key the destination objects are encrypted with, either as raw bytes or base64 encoded. The key is needed to read the objects afterwards.")
+}
+
+func (opts destinationOptions) isSet() bool {
+	return opts.storageClass != "" || opts.sse != "" || opts.kmsKeyID != "" || opts.sseCKeyFile != ""
+}
+
+// writePolicy applies the destination options to the objects written to the
+// destination, through the writer options of its provider.
+type writePolicy struct {
+	storageClass string
+	sse          string
+	kmsKeyID     string
+	// customerKey is the SSE-C key
+	customerKey []byte
+
+	// name and prefix locate the objects of the bucket for the probe
+	name   string
+	prefix string
+	s3     *s3.S3
+	gcs    *storage.Client
+}
+
+// newWritePolicy validates the destination options against the provider of
+// the destination. It returns nil if no option is set.
+func newWritePolicy(opts destinationOptions, b bucketOptions, bucket *blob.Bucket) (*writePolicy, error) {
+	if !opts.isSet() {
+		return nil, nil
+	}
+	p := &writePolicy{
+		storageClass: opts.storageClass,
+		sse:          opts.sse,
+		kmsKeyID:     opts.kmsKeyID,
+	}
+	var err error
+	if p.name, p.prefix, err = bucketLocation(b); err != nil {
+		return nil, err
+	}
+	if opts.sseCKeyFile != "" {
+		if p.customerKey, err = readCustomerKey(opts.sseCKeyFile); err != nil {
+			return nil, err
+		}
+	}
+	if p.kmsKeyID != "" && p.sse != sseKMS {
+		return nil, fmt.Errorf("--dst-sse-kms-key-id requires --dst-sse=%s", sseKMS)
+	}
+	if p.sse != "" && p.customerKey != nil {
+		return nil, fmt.Errorf("--dst-sse and --dst-sse-c-key-file can't be used together")
+	}
+
+	switch {
+	case bucket.As(&p.s3):
+		if p.storageClass != "" && !slices.Contains(s3.StorageClass_Values(), p.storageClass) {
+			return nil, fmt.Errorf("invalid S3 storage class %q. Supported storage classes are %s", p.storageClass, strings.Join(s3.StorageClass_Values(), ","))
+		}
+		if p.sse != "" && p.sse != sseAES256 && p.sse != sseKMS {
+			return nil, fmt.Errorf("invalid server-side encryption %q. Supported values are %s and %s", p.sse, sseAES256, sseKMS)
+		}
+	case bucket.As(&p.gcs):
+		if p.storageClass != "" && !slices.Contains(gcsStorageClasses, p.storageClass) {
+			return nil, fmt.Errorf("invalid GCS storage class %q. Supported storage classes are %s", p.storageClass, strings.Join(gcsStorageClasses, ","))
+		}
+		switch p.sse {
+		case "":
+		case sseKMS:
+			if p.kmsKeyID == "" {
+				return nil, fmt.Errorf("--dst-sse=%s requires --dst-sse-kms-key-id for GCS", sseKMS)
+			}
+		case sseAES256:
+			return nil, fmt.Errorf("--dst-sse=%s is not supported for GCS, which always encrypts the objects with Google-managed keys", sseAES256)
+		default:
+			return nil, fmt.Errorf("invalid server-side encryption %q. Supported value for GCS is %s", p.sse, sseKMS)
+		}
+	default:
+		return nil, fmt.Errorf("--dst-storage-class, --dst-sse and --dst-sse-c-key-file are only supported for S3 compatible and GCS destinations. The S3 buckets must use the AWS SDK v1, which is the default")
+	}
+	return p, nil
+}
+
+// readCustomerKey reads a 256 bit key, stored either as raw bytes or base64
+// encoded.
+func readCustomerKey(file string) ([]byte, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read customer key. Reason: %w", err)
+	}
+	if len(data) == 32 {
+		return data, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("customer key in %s must be 32 bytes, either raw or base64 encoded", file)
+	}
+	return key, nil
+}
+
+// beforeWrite sets the options on the request of the provider. It is used as
+// blob.WriterOptions.BeforeWrite.
+func (p *writePolicy) beforeWrite(as func(any) bool) error {
+	var in *s3manager.UploadInput
+	if as(&in) {
+		p.applyS3(&in.StorageClass, &in.ServerSideEncryption, &in.SSEKMSKeyId, &in.SSECustomerAlgorithm, &in.SSECustomerKey, &in.SSECustomerKeyMD5)
+		return nil
+	}
+	// the object handle must be accessed before the writer
+	var oh **storage.ObjectHandle
+	if as(&oh) && p.customerKey != nil {
+		*oh = (*oh).Key(p.customerKey)
+	}
+	var w *storage.Writer
+	if as(&w) {
+		p.applyGCS(&w.ObjectAttrs)
+		return nil
+	}
+	return fmt.Errorf("destination options are not supported by the destination writer")
+}
+
+func (p *writePolicy) applyS3(storageClass, sse, kmsKeyID, sseCAlgorithm, sseCKey, sseCKeyMD5 **string) {
+	if p.storageClass != "" {
+		*storageClass = aws.String(p.storageClass)
+	}
+	switch p.sse {
+	case sseAES256:
+		*sse = aws.String(s3.ServerSideEncryptionAes256)
+	case sseKMS:
+		*sse = aws.String(s3.ServerSideEncryptionAwsKms)
+		if p.kmsKeyID != "" {
+			*kmsKeyID = aws.String(p.kmsKeyID)
+		}
+	}
+	if p.customerKey != nil {
+		sum := md5.Sum(p.customerKey)
+		*sseCAlgorithm = aws.String(s3.ServerSideEncryptionAes256)
+		*sseCKey = aws.String(string(p.customerKey))
+		*sseCKeyMD5 = aws.String(base64.StdEncoding.EncodeToString(sum[:]))
+	}
+}
+
+func (p *writePolicy) applyGCS(attrs *storage.ObjectAttrs) {
+	if p.storageClass != "" {
+		attrs.StorageClass = p.storageClass
+	}
+	if p.sse == sseKMS {
+		attrs.KMSKeyName = p.kmsKeyID
+	}
+}
+
+// check writes an empty object with the options to the destination, and
+// removes it again. It fails if the destination doesn't accept the options,
+// i.e. the storage class is not available or the KMS key can't be used.
+func (p *writePolicy) check(ctx context.Context) error {
+	key := p.prefix + ".cloud-swap-probe-" + xid.New().String()
+	if p.s3 != nil {
+		in := &s3.PutObjectInput{
+			Bucket: aws.String(p.name),
+			Key:    aws.String(key),
+			Body:   bytes.NewReader(nil),
+		}
+		p.applyS3(&in.StorageClass, &in.ServerSideEncryption, &in.SSEKMSKeyId, &in.SSECustomerAlgorithm, &in.SSECustomerKey, &in.SSECustomerKeyMD5)
+		out, err := p.s3.PutObjectWithContext(ctx, in)
+		if err != nil {
+			return fmt.Errorf("destination doesn't accept the storage class or encryption options. Reason: %w", err)
+		}
+		// removing the version leaves nothing behind in a versioned bucket
+		_, err = p.s3.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+			Bucket:    aws.String(p.name),
+			Key:       aws.String(key),
+			VersionId: out.VersionId,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to remove probe object %s from destination. Reason: %w", key, err)
+		}
+		return nil
+	}
+
+	obj := p.gcs.Bucket(p.name).Object(key)
+	if p.customerKey != nil {
+		obj = obj.Key(p.customerKey)
+	}
+	w := obj.NewWriter(ctx)
+	p.applyGCS(&w.ObjectAttrs)
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("destination doesn't accept the storage class or encryption options. Reason: %w", err)
+	}
+	if err := obj.Generation(w.Attrs().Generation).Delete(ctx); err != nil {
+		return fmt.Errorf("failed to remove probe object %s from destination. Reason: %w", key, err)
+	}
+	return nil
+}
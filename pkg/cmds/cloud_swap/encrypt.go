@@ -0,0 +1,162 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+)
+
+// encryptionOptions choose how the archives of the local backup are
+// encrypted. The archives are encrypted with age, either to the given
+// recipients or with a passphrase.
+type encryptionOptions struct {
+	enabled        bool
+	recipients     []string
+	passphraseFile string
+}
+
+func addEncryptionFlags(fs *pflag.FlagSet, opts *encryptionOptions) {
+	fs.BoolVar(&opts.enabled, "local-backup-encrypt", false, "Encrypt the local backup with age. Requires --local-backup-format=tar.zst. Without --local-backup-recipient, a passphrase is used.")
+	fs.StringArrayVar(&opts.recipients, "local-backup-recipient", nil, "age public key (age1...) the local backup is encrypted to. Can be repeated.")
+	fs.StringVar(&opts.passphraseFile, "local-backup-passphrase-file", "", "File holding the passphrase the local backup is encrypted with. The passphrase is asked for if not given.")
+}
+
+// ageRecipients returns the recipients the local backup is encrypted to, or
+// nil if it is not encrypted.
+func (o encryptionOptions) ageRecipients() ([]age.Recipient, error) {
+	if !o.enabled {
+		if len(o.recipients) > 0 || o.passphraseFile != "" {
+			return nil, fmt.Errorf("--local-backup-recipient and --local-backup-passphrase-file require --local-backup-encrypt")
+		}
+		return nil, nil
+	}
+
+	if len(o.recipients) > 0 {
+		recipients := make([]age.Recipient, 0, len(o.recipients))
+		for _, s := range o.recipients {
+			r, err := age.ParseX25519Recipient(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid recipient %q. Reason: %w", s, err)
+			}
+			recipients = append(recipients, r)
+		}
+		return recipients, nil
+	}
+
+	passphrase, err := readPassphrase(o.passphraseFile, true)
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return []age.Recipient{r}, nil
+}
+
+// decryptionOptions give the keys an encrypted backup is restored with.
+type decryptionOptions struct {
+	identityFiles  []string
+	passphraseFile string
+}
+
+func addDecryptionFlags(fs *pflag.FlagSet, opts *decryptionOptions) {
+	fs.StringArrayVar(&opts.identityFiles, "identity-file", nil, "age identity file used to decrypt an encrypted backup. Can be repeated.")
+	fs.StringVar(&opts.passphraseFile, "passphrase-file", "", "File holding the passphrase of an encrypted backup. The passphrase is asked for if neither this nor --identity-file is given.")
+}
+
+// ageIdentities returns the identities the archives of the backup are
+// decrypted with.
+func (o decryptionOptions) ageIdentities() ([]age.Identity, error) {
+	if len(o.identityFiles) > 0 {
+		var identities []age.Identity
+		for _, name := range o.identityFiles {
+			data, err := os.ReadFile(name)
+			if err != nil {
+				return nil, err
+			}
+			ids, err := age.ParseIdentities(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse identity file %s. Reason: %w", name, err)
+			}
+			identities = append(identities, ids...)
+		}
+		return identities, nil
+	}
+
+	passphrase, err := readPassphrase(o.passphraseFile, false)
+	if err != nil {
+		return nil, err
+	}
+	id, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return []age.Identity{id}, nil
+}
+
+// readPassphrase reads the passphrase from a file, or asks for it on the
+// terminal if no file is given. A new passphrase has to be typed twice.
+func readPassphrase(file string, confirm bool) (string, error) {
+	var passphrase string
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		passphrase = strings.TrimRight(string(data), "\r\n")
+	} else {
+		fd := int(os.Stdin.Fd())
+		if !term.IsTerminal(fd) {
+			return "", fmt.Errorf("can't ask for the passphrase, stdin is not a terminal. Use a passphrase file instead")
+		}
+		var err error
+		if passphrase, err = promptPassword(fd, "Passphrase: "); err != nil {
+			return "", err
+		}
+		if confirm {
+			again, err := promptPassword(fd, "Confirm passphrase: ")
+			if err != nil {
+				return "", err
+			}
+			if again != passphrase {
+				return "", fmt.Errorf("the passphrases don't match")
+			}
+		}
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("the passphrase can't be empty")
+	}
+	return passphrase, nil
+}
+
+func promptPassword(fd int, prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	data, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase. Reason: %w", err)
+	}
+	return string(data), nil
+}
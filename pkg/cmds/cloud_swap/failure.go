@@ -0,0 +1,254 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"gocloud.dev/gcerrors"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	retryBaseDelay = time.Second
+	retryMaxDelay  = 30 * time.Second
+)
+
+// The operations an object copy can fail at, as written in the error report.
+const (
+	opStatSource       = "stat-source"
+	opReadSource       = "read-source"
+	opStatDestination  = "stat-destination"
+	opReadDestination  = "read-destination"
+	opWriteDestination = "write-destination"
+	opWriteBackup      = "write-local-backup"
+	opCheckpoint       = "checkpoint"
+)
+
+// objectError is the failure of an operation on an object.
+type objectError struct {
+	op  string
+	err error
+}
+
+func (e *objectError) Error() string {
+	return e.err.Error()
+}
+
+func (e *objectError) Unwrap() error {
+	return e.err
+}
+
+// withOp marks err as the failure of op, unless it is marked already.
+func withOp(op string, err error) error {
+	var oe *objectError
+	if err == nil || errors.As(err, &oe) {
+		return err
+	}
+	return &objectError{op: op, err: err}
+}
+
+// errorOp returns the operation err is the failure of.
+func errorOp(err error) string {
+	var oe *objectError
+	if errors.As(err, &oe) {
+		return oe.op
+	}
+	return "copy"
+}
+
+// opReader marks the read errors of r as failures of op.
+type opReader struct {
+	r  io.Reader
+	op string
+}
+
+func (r *opReader) Read(data []byte) (int, error) {
+	n, err := r.r.Read(data)
+	if err != nil && err != io.EOF {
+		err = withOp(r.op, err)
+	}
+	return n, err
+}
+
+// opWriter marks the write errors of w as failures of op.
+type opWriter struct {
+	w  io.Writer
+	op string
+}
+
+func (w *opWriter) Write(data []byte) (int, error) {
+	n, err := w.w.Write(data)
+	return n, withOp(w.op, err)
+}
+
+// isRetryable reports whether a failed operation may succeed if it is tried
+// again. Throttling, timeouts and server errors are retryable, while errors
+// like a missing object or a denied access are permanent.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if status, ok := httpStatus(err); ok {
+		return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+	}
+	switch gcerrors.Code(err) {
+	case gcerrors.NotFound,
+		gcerrors.AlreadyExists,
+		gcerrors.InvalidArgument,
+		gcerrors.FailedPrecondition,
+		gcerrors.PermissionDenied,
+		gcerrors.Unimplemented:
+		return false
+	}
+	// local files, such as the backup, fail for reasons like a full disk
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	return !errors.As(err, &pathErr) && !errors.As(err, &linkErr)
+}
+
+// httpStatus returns the HTTP status code of a failed request to a
+// provider.
+func httpStatus(err error) (int, bool) {
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) {
+		return gerr.Code, true
+	}
+	var aerr awserr.RequestFailure
+	if errors.As(err, &aerr) {
+		return aerr.StatusCode(), true
+	}
+	var azerr *azcore.ResponseError
+	if errors.As(err, &azerr) {
+		return azerr.StatusCode, true
+	}
+	return 0, false
+}
+
+// backoff returns the delay before a retry. It doubles with every attempt up
+// to retryMaxDelay, with a random jitter so that the workers don't retry in
+// lockstep.
+func backoff(attempt int) time.Duration {
+	d := retryMaxDelay
+	if attempt < 16 {
+		d = min(retryBaseDelay<<attempt, retryMaxDelay)
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// failureRecord is a line of the error report.
+type failureRecord struct {
+	Key       string `json:"key"`
+	Operation string `json:"operation"`
+	Error     string `json:"error"`
+}
+
+// errorReport lists the objects that failed to copy, so that they can be
+// copied again with --only-keys-from.
+type errorReport struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	failed int
+}
+
+// defaultErrorReport returns the error report location for a source and
+// destination pair under the CLI state directory.
+func defaultErrorReport(src, dst string) (string, error) {
+	return defaultStateFile("errors", src, dst)
+}
+
+func openErrorReport(path string) (*errorReport, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &errorReport{path: path, file: file}, nil
+}
+
+func (r *errorReport) add(key string, failure error) error {
+	data, err := json.Marshal(failureRecord{Key: key, Operation: errorOp(failure), Error: failure.Error()})
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failed += 1
+	_, err = r.file.Write(append(data, '\n'))
+	return err
+}
+
+func (r *errorReport) close() error {
+	return r.file.Close()
+}
+
+// readKeys reads the keys listed in a file, one per line. The lines of an
+// error report are accepted as well.
+func readKeys(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		key := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(key, "{") {
+			var rec failureRecord
+			if err := json.Unmarshal([]byte(key), &rec); err != nil {
+				return nil, fmt.Errorf("failed to parse line %d of %s. Reason: %w", line, path, err)
+			}
+			key = rec.Key
+		}
+		if key != "" {
+			seen[key] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
@@ -0,0 +1,212 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"google.golang.org/api/googleapi"
+)
+
+func TestIsRetryable(t *testing.T) {
+	awsFailure := func(status int) error {
+		return awserr.NewRequestFailure(awserr.New("Failure", "failure", nil), status, "id")
+	}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"gcs throttled", &googleapi.Error{Code: http.StatusTooManyRequests}, true},
+		{"gcs server error", &googleapi.Error{Code: http.StatusServiceUnavailable}, true},
+		{"gcs not found", &googleapi.Error{Code: http.StatusNotFound}, false},
+		{"s3 timeout", awsFailure(http.StatusRequestTimeout), true},
+		{"s3 denied", awsFailure(http.StatusForbidden), false},
+		{"azure server error", &azcore.ResponseError{StatusCode: http.StatusInternalServerError}, true},
+		{"azure conflict", &azcore.ResponseError{StatusCode: http.StatusConflict}, false},
+		{"wrapped", withOp(opReadSource, fmt.Errorf("read: %w", &googleapi.Error{Code: http.StatusBadGateway})), true},
+		{"canceled", fmt.Errorf("copy: %w", context.Canceled), false},
+		{"local file", &fs.PathError{Op: "write", Path: "backup", Err: errors.New("no space left on device")}, false},
+		{"connection reset", errors.New("connection reset by peer"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.err); got != tt.want {
+				t.Errorf("isRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorOp(t *testing.T) {
+	failure := errors.New("failure")
+	if op := errorOp(failure); op != "copy" {
+		t.Errorf("op of an unmarked error = %q, want copy", op)
+	}
+	err := withOp(opReadSource, failure)
+	if op := errorOp(fmt.Errorf("copy a.txt: %w", withOp(opWriteDestination, err))); op != opReadSource {
+		t.Errorf("op = %q, want the first operation %q", op, opReadSource)
+	}
+	if !errors.Is(err, failure) || err.Error() != failure.Error() {
+		t.Errorf("withOp changed the error to %v", err)
+	}
+	if withOp(opReadSource, nil) != nil {
+		t.Error("withOp(nil) must be nil")
+	}
+
+	r := &opReader{r: io.MultiReader(strings.NewReader("data"), &failingReader{failure}), op: opReadSource}
+	if _, err := io.ReadAll(r); errorOp(err) != opReadSource {
+		t.Errorf("op of the read error = %q, want %q", errorOp(err), opReadSource)
+	}
+	r = &opReader{r: strings.NewReader("data"), op: opReadSource}
+	if _, err := io.ReadAll(r); err != nil {
+		t.Errorf("read error = %v, want none", err)
+	}
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func TestReadKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.txt")
+	content := "b.txt\r\n" +
+		"\n" +
+		`{"key":"a.txt","operation":"read-source","error":"failure"}` + "\n" +
+		"b.txt\n" +
+		"dir/c d.txt\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := readKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.txt", "b.txt", "dir/c d.txt"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %q, want %q", keys, want)
+	}
+
+	if err := os.WriteFile(path, []byte("a.txt\n{\"key\":\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readKeys(path); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("error = %v, want a parse error at line 2", err)
+	}
+}
+
+// failingSource fails to read the objects listed in failures.
+type failingSource struct {
+	objectSource
+	failures map[string]error
+}
+
+func (s *failingSource) open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err, ok := s.failures[key]; ok {
+		return nil, err
+	}
+	return s.objectSource.open(ctx, key)
+}
+
+func TestErrorBudget(t *testing.T) {
+	denied := &googleapi.Error{Code: http.StatusForbidden, Message: "denied"}
+	tests := []struct {
+		name      string
+		maxErrors int
+		failures  []string
+		wantErr   string
+	}{
+		{"within budget", 1, []string{"b.txt"}, ""},
+		{"no limit", -1, []string{"a.txt", "b.txt", "c.txt"}, ""},
+		{"no failure allowed", 0, []string{"b.txt"}, "denied"},
+		{"over budget", 1, []string{"a.txt", "c.txt"}, "2 objects failed to copy, more than --max-errors=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, dst := newTestBucket(t), newTestBucket(t)
+			src.write(t, map[string]string{
+				"a.txt": "a",
+				"b.txt": "b",
+				"c.txt": "c",
+			})
+			opts := testSwapOptions(t, src, dst)
+			opts.concurrency = 1
+			opts.retries = 2
+			opts.maxErrors = tt.maxErrors
+
+			ctx := context.Background()
+			s, err := newSwapper(ctx, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.close()
+			failures := map[string]error{}
+			for _, key := range tt.failures {
+				failures[key] = withOp(opReadSource, denied)
+			}
+			s.src = &failingSource{objectSource: s.src, failures: failures}
+			if err := s.openErrorReport(opts); err != nil {
+				t.Fatal(err)
+			}
+			stats, err := s.copyAll(ctx)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("copy failed: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+			if stats.failed != len(tt.failures) {
+				t.Errorf("failed = %d, want %d", stats.failed, len(tt.failures))
+			}
+			if err := s.report.close(); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(opts.errorReport)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+			if len(lines) != len(tt.failures) || !bytes.Contains(lines[0], []byte(`"operation":"read-source"`)) {
+				t.Errorf("error report = %s, want a record of every failed object", data)
+			}
+			keys, err := readKeys(opts.errorReport)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(keys, tt.failures) {
+				t.Errorf("failed keys = %q, want %q", keys, tt.failures)
+			}
+		})
+	}
+}
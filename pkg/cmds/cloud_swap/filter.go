@@ -0,0 +1,213 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+const regexPatternPrefix = "regex:"
+
+// filterOptions select the objects to copy and map their keys to the keys
+// used at the destination.
+type filterOptions struct {
+	include   []string
+	exclude   []string
+	srcPrefix string
+	dstPrefix string
+	rename    []string
+}
+
+func addFilterFlags(fs *pflag.FlagSet, opts *filterOptions) {
+	fs.StringArrayVar(&opts.include, "include", nil, "Copy only the objects whose key matches the pattern. Patterns are globs (i.e. 'backups/**/*.tar.gz') unless prefixed with 'regex:'. Can be repeated.")
+	fs.StringArrayVar(&opts.exclude, "exclude", nil, "Skip the objects whose key matches the pattern. Takes precedence over --include. Can be repeated.")
+	fs.StringVar(&opts.srcPrefix, "src-prefix", "", "Copy only the objects under this prefix of the source. The prefix is removed from the keys before --rename and --dst-prefix are applied.")
+	fs.StringVar(&opts.dstPrefix, "dst-prefix", "", "Prefix added to the keys at the destination")
+	fs.StringArrayVar(&opts.rename, "rename", nil, "Rewrite the keys using a rule in '<regex>=<replacement>' format (i.e. '^v1/(.*)$=v2/$1'). Rules are applied in order. Can be repeated.")
+}
+
+// String returns a canonical description of the key mapping. It is used to
+// tell apart checkpoints of copies with different mappings.
+func (opts filterOptions) String() string {
+	if len(opts.include) == 0 && len(opts.exclude) == 0 && len(opts.rename) == 0 &&
+		opts.srcPrefix == "" && opts.dstPrefix == "" {
+		return ""
+	}
+	return fmt.Sprintf("include=%q exclude=%q src-prefix=%q dst-prefix=%q rename=%q",
+		opts.include, opts.exclude, opts.srcPrefix, opts.dstPrefix, opts.rename)
+}
+
+type renameRule struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// keyMapper filters the source keys and maps them to destination keys.
+// Patterns are matched against the keys with the source prefix removed.
+type keyMapper struct {
+	srcPrefix string
+	dstPrefix string
+	include   []*regexp.Regexp
+	exclude   []*regexp.Regexp
+	rename    []renameRule
+	// skipManifest leaves out the manifest of a local backup used as source
+	skipManifest bool
+}
+
+func newKeyMapper(opts filterOptions) (*keyMapper, error) {
+	m := &keyMapper{
+		srcPrefix: opts.srcPrefix,
+		dstPrefix: opts.dstPrefix,
+	}
+
+	var err error
+	if m.include, err = compilePatterns(opts.include); err != nil {
+		return nil, err
+	}
+	if m.exclude, err = compilePatterns(opts.exclude); err != nil {
+		return nil, err
+	}
+	for _, rule := range opts.rename {
+		pattern, replacement, ok := strings.Cut(rule, "=")
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("invalid rename rule %q. Expected format is <regex>=<replacement>", rule)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rename rule %q. Reason: %w", rule, err)
+		}
+		m.rename = append(m.rename, renameRule{pattern: re, replacement: replacement})
+	}
+	return m, nil
+}
+
+// selects reports whether the object with the given source key is copied.
+func (m *keyMapper) selects(key string) bool {
+	if m.skipManifest && key == backupManifestFile {
+		return false
+	}
+	if !strings.HasPrefix(key, m.srcPrefix) {
+		return false
+	}
+	key = strings.TrimPrefix(key, m.srcPrefix)
+	if len(m.include) > 0 && !matchesAny(m.include, key) {
+		return false
+	}
+	return !matchesAny(m.exclude, key)
+}
+
+// dstKey returns the destination key of an object of the source.
+func (m *keyMapper) dstKey(key string) string {
+	key = strings.TrimPrefix(key, m.srcPrefix)
+	for _, rule := range m.rename {
+		key = rule.pattern.ReplaceAllString(key, rule.replacement)
+	}
+	return m.dstPrefix + key
+}
+
+// filteredSource lists only the objects of the source selected by the mapper.
+type filteredSource struct {
+	objectSource
+	mapper *keyMapper
+}
+
+func (s *filteredSource) list(ctx context.Context, prefix string, fn func(obj objectInfo) error) error {
+	return s.objectSource.list(ctx, s.mapper.srcPrefix+prefix, func(obj objectInfo) error {
+		if !s.mapper.selects(obj.Key) {
+			return nil
+		}
+		return fn(obj)
+	})
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	out := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		expr := ""
+		if strings.HasPrefix(p, regexPatternPrefix) {
+			expr = strings.TrimPrefix(p, regexPatternPrefix)
+		} else {
+			expr = globToRegexp(p)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q. Reason: %w", p, err)
+		}
+		out = append(out, re)
+	}
+	return out, nil
+}
+
+// globToRegexp converts a glob to an anchored regular expression. '*' and '?'
+// don't match '/', while '**' matches any number of path segments.
+func globToRegexp(glob string) string {
+	// the glob is walked by runes, so that multi-byte characters are kept
+	// whole
+	g := []rune(glob)
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(g); i++ {
+		switch c := g[i]; c {
+		case '*':
+			if i+1 < len(g) && g[i+1] == '*' {
+				i++
+				// '**/' also matches no directory at all
+				if i+1 < len(g) && g[i+1] == '/' {
+					i++
+					sb.WriteString("(?:.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := slices.Index(g[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := string(g[i+1 : i+1+end])
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
+
+func matchesAny(patterns []*regexp.Regexp, key string) bool {
+	for _, re := range patterns {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"testing"
+)
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		glob    string
+		match   []string
+		noMatch []string
+	}{
+		{
+			glob:    "*.csv",
+			match:   []string{"a.csv", ".csv"},
+			noMatch: []string{"dir/a.csv", "a.csv.gz"},
+		},
+		{
+			glob:    "données/*.csv",
+			match:   []string{"données/a.csv", "données/été.csv"},
+			noMatch: []string{"donnees/a.csv", "données/x/a.csv"},
+		},
+		{
+			glob:    "backups/**/*.tar.gz",
+			match:   []string{"backups/a.tar.gz", "backups/2024/01/a.tar.gz"},
+			noMatch: []string{"a.tar.gz", "backups/a.tar"},
+		},
+		{
+			glob:  "**",
+			match: []string{"a", "a/b/c"},
+		},
+		{
+			glob:    "log?.txt",
+			match:   []string{"log1.txt", "logé.txt"},
+			noMatch: []string{"log.txt", "log12.txt", "log/.txt"},
+		},
+		{
+			glob:    "[ab]*.txt",
+			match:   []string{"a.txt", "b1.txt"},
+			noMatch: []string{"c.txt"},
+		},
+		{
+			glob:    "[!ab]*.txt",
+			match:   []string{"c.txt"},
+			noMatch: []string{"a.txt"},
+		},
+		{
+			glob:    "[é-ê].txt",
+			match:   []string{"é.txt", "ê.txt"},
+			noMatch: []string{"e.txt"},
+		},
+		{
+			glob:    "a[1.txt",
+			match:   []string{"a[1.txt"},
+			noMatch: []string{"a1.txt"},
+		},
+		{
+			glob:    "a+(b).txt",
+			match:   []string{"a+(b).txt"},
+			noMatch: []string{"aab.txt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.glob, func(t *testing.T) {
+			patterns, err := compilePatterns([]string{tt.glob})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, key := range tt.match {
+				if !patterns[0].MatchString(key) {
+					t.Errorf("%s (%s) doesn't match %q", tt.glob, patterns[0], key)
+				}
+			}
+			for _, key := range tt.noMatch {
+				if patterns[0].MatchString(key) {
+					t.Errorf("%s (%s) matches %q", tt.glob, patterns[0], key)
+				}
+			}
+		})
+	}
+}
+
+func TestKeyMapper(t *testing.T) {
+	tests := []struct {
+		name string
+		opts filterOptions
+		// keys maps the source keys to their destination key, or to an empty
+		// string if they are not selected
+		keys map[string]string
+	}{
+		{
+			name: "no filters",
+			keys: map[string]string{"a": "a", "données/été.csv": "données/été.csv"},
+		},
+		{
+			name: "include",
+			opts: filterOptions{include: []string{"données/*.csv", "regex:^logs/"}},
+			keys: map[string]string{
+				"données/été.csv": "données/été.csv",
+				"logs/a.txt":      "logs/a.txt",
+				"données/été.txt": "",
+				"other/a.csv":     "",
+			},
+		},
+		{
+			name: "exclude takes precedence",
+			opts: filterOptions{include: []string{"**/*.csv"}, exclude: []string{"tmp/**"}},
+			keys: map[string]string{
+				"a.csv":     "a.csv",
+				"x/a.csv":   "x/a.csv",
+				"tmp/a.csv": "",
+			},
+		},
+		{
+			name: "prefixes",
+			opts: filterOptions{srcPrefix: "data/", dstPrefix: "archive/", include: []string{"*.csv"}},
+			keys: map[string]string{
+				"data/a.csv":   "archive/a.csv",
+				"data/x/a.csv": "",
+				"a.csv":        "",
+				"other/a.csv":  "",
+			},
+		},
+		{
+			name: "rename",
+			opts: filterOptions{
+				srcPrefix: "data/",
+				dstPrefix: "new/",
+				rename:    []string{`^v1/(.*)$=v2/$1`, `\.jpeg$=.jpg`},
+			},
+			keys: map[string]string{
+				"data/v1/a.jpeg":      "new/v2/a.jpg",
+				"data/v1/données.csv": "new/v2/données.csv",
+				"data/v3/a.txt":       "new/v3/a.txt",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := newKeyMapper(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, want := range tt.keys {
+				if !m.selects(key) {
+					if want != "" {
+						t.Errorf("%q is not selected", key)
+					}
+					continue
+				}
+				if want == "" {
+					t.Errorf("%q is selected", key)
+				} else if got := m.dstKey(key); got != want {
+					t.Errorf("dstKey(%q) = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewKeyMapperErrors(t *testing.T) {
+	tests := []filterOptions{
+		{include: []string{"regex:("}},
+		{exclude: []string{"[z-a]"}},
+		{rename: []string{"a"}},
+		{rename: []string{"=b"}},
+		{rename: []string{"(=b"}},
+	}
+	for _, opts := range tests {
+		if _, err := newKeyMapper(opts); err == nil {
+			t.Errorf("newKeyMapper(%s) returned no error", opts)
+		}
+	}
+}
+
+func TestKeyMapperBackupManifest(t *testing.T) {
+	m, err := newKeyMapper(filterOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.selects(backupManifestFile) {
+		t.Errorf("%s is not selected from a bucket", backupManifestFile)
+	}
+	m.skipManifest = true
+	if m.selects(backupManifestFile) {
+		t.Errorf("%s is selected from a backup", backupManifestFile)
+	}
+	if !m.selects("dir/" + backupManifestFile) {
+		t.Errorf("dir/%s is not selected from a backup", backupManifestFile)
+	}
+}
@@ -18,135 +18,346 @@ package cloud_swap
 
 import (
 	"context"
-	goerr "errors"
 	"fmt"
 	"io"
-	"net/http"
-	"os"
 	"path"
-	"strings"
+	"path/filepath"
 
-	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
-	"github.com/pkg/errors"
+	"github.com/dustin/go-humanize"
 	"gocloud.dev/blob"
-	"gomodules.xyz/blobfs"
+	"golang.org/x/time/rate"
 	"k8s.io/client-go/util/homedir"
 )
 
-func initiateBuckets(ctx context.Context) (err error) {
-	if ms.endpoint != "" {
-		mc, err = minio.New(ms.endpoint, &minio.Options{
-			Creds:  credentials.NewStaticV4(ms.accessID, ms.secretKey, ""),
-			Secure: tlsEnabled(ms.endpoint),
-		})
-		if err != nil {
-			return err
-		}
-	} else {
-		srcBucket, err = blob.OpenBucket(ctx, srcBucketURL)
+const defaultPartSize = "16MiB"
+
+// swapper copies the objects of a source storage to the destination bucket
+// and optionally to a local backup directory.
+type swapper struct {
+	src         objectSource
+	mapper      *keyMapper
+	metadata    *metadataPolicy
+	dst         *blob.Bucket
+	local       localBackup
+	manifest    *backupManifest
+	partSize    int
+	concurrency int
+	retries     int
+	// maxErrors is the number of objects that may fail before the copy is
+	// aborted, or -1 for no limit
+	maxErrors int
+	report    *errorReport
+	// limiter keeps the copy under the bandwidth limit, if any
+	limiter  *rate.Limiter
+	out      io.Writer
+	progress *progress
+	metrics  *copyMetrics
+
+	// serverCopy copies the objects within the provider, if both buckets are
+	// on the same one
+	serverCopy *serverCopier
+	// write applies the storage class and encryption options, if any
+	write *writePolicy
+	// versionSrc lists and reads the versions of the source objects, and
+	// versions records the ones replayed, with --all-versions
+	versionSrc *versionSource
+	versions   *versionManifest
+
+	checkpoint  *checkpoint
+	resume      bool
+	incremental bool
+	// sync compares every object with the destination and records the keys
+	// seen, so that the stale destination objects can be found.
+	sync bool
+	seen *keySet
+}
+
+func newSwapper(ctx context.Context, opts *swapOptions) (_ *swapper, err error) {
+	s := &swapper{out: opts.output()}
+	s.progress = newProgress(s.out)
+	defer func() {
 		if err != nil {
-			return err
+			s.close()
 		}
-		srcFS = blobfs.New(srcBucketURL)
-	}
+	}()
 
-	dstFS = blobfs.New(dstBucketURL)
+	partSize, err := humanize.ParseBytes(opts.partSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid part size %q. Reason: %w", opts.partSize, err)
+	}
+	s.partSize = int(partSize)
 
-	// initiate local backup bucket
-	if !disableLocalBackup {
-		if _, err = os.Stat(localBackupDir); goerr.Is(err, os.ErrNotExist) {
-			if err = os.Mkdir(localBackupDir, os.ModePerm); err != nil {
-				return err
-			}
-		} else if err != nil {
-			return err
+	if opts.concurrency < 1 {
+		return nil, fmt.Errorf("concurrency must be at least 1")
+	}
+	s.concurrency = opts.concurrency
+	if opts.retries < 0 {
+		return nil, fmt.Errorf("retries can't be negative")
+	}
+	s.retries = opts.retries
+	if opts.maxErrors < -1 {
+		return nil, fmt.Errorf("max errors must be at least -1")
+	}
+	s.maxErrors = opts.maxErrors
+	s.limiter = opts.limiter
+	if s.limiter == nil {
+		if s.limiter, err = newBandwidthLimiter(opts.bandwidthLimit); err != nil {
+			return nil, err
 		}
+	}
+	if opts.registerer != nil {
+		s.metrics = newCopyMetrics(opts.registerer)
+	}
 
-		if !strings.HasPrefix(localBackupDir, "file://") {
-			localBackupDir = fmt.Sprintf("%s%s", "file://", localBackupDir)
+	switch opts.mode {
+	case modeCopy:
+		if opts.delete {
+			return nil, fmt.Errorf("--delete requires --mode=%s", modeSync)
 		}
-
-		localFS = blobfs.New(localBackupDir)
+	case modeSync:
+		if opts.onlyKeysFrom != "" {
+			// the objects not listed would be deleted as stale
+			return nil, fmt.Errorf("--only-keys-from can't be used with --mode=%s", modeSync)
+		}
+		s.sync = true
+		s.seen = newKeySet()
+	default:
+		return nil, fmt.Errorf("invalid mode %q. Supported modes are %s and %s", opts.mode, modeCopy, modeSync)
 	}
 
-	return nil
-}
+	s.mapper, err = newKeyMapper(opts.filter)
+	if err != nil {
+		return nil, err
+	}
+	s.mapper.skipManifest = opts.restore
+	s.metadata, err = newMetadataPolicy(opts.metadata)
+	if err != nil {
+		return nil, err
+	}
+	s.src, err = openSource(ctx, opts, s.mapper)
+	if err != nil {
+		return nil, err
+	}
 
-func localDefaultDir() string {
-	home := homedir.HomeDir()
-	return path.Join(home, "cloud-swap-backup")
-}
+	s.dst, err = openBucket(ctx, opts.dst)
+	if err != nil {
+		return nil, err
+	}
+	if s.write, err = newWritePolicy(opts.destination, opts.dst, s.dst); err != nil {
+		return nil, err
+	}
+	if s.write != nil {
+		if s.write.customerKey != nil && (opts.resume || opts.incremental || s.sync || opts.verify || opts.allVersions) {
+			// the destination objects are compared through their attributes,
+			// which can't be read without the key
+			return nil, fmt.Errorf("--dst-sse-c-key-file can't be used with --resume, --incremental, --mode=%s, --verify or --all-versions", modeSync)
+		}
+		if err := s.write.check(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if opts.allVersions {
+		if err := s.openVersions(ctx, opts); err != nil {
+			return nil, err
+		}
+	}
 
-func tlsEnabled(url string) bool {
-	resp, err := http.Get("https://" + url)
+	checkpointFile, err := opts.checkpointPath()
 	if err != nil {
-		return false
+		return nil, err
+	}
+	s.resume = opts.resume
+	s.incremental = opts.incremental
+	// a run of the listed keys adds to the state of the previous runs, and a
+	// sync run relies on it to skip the unchanged objects and to find the
+	// objects it owns
+	keep := s.resume || s.incremental || s.sync || opts.onlyKeysFrom != ""
+	s.checkpoint, err = openCheckpoint(checkpointFile, keep)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoint manifest. Reason: %w", err)
 	}
-	defer resp.Body.Close()
-	return resp.TLS != nil
-}
 
-func copyFromMinio(ctx context.Context) error {
-	fileCount := 0
-	for oi := range mc.ListObjects(ctx, ms.bucket, minio.ListObjectsOptions{Recursive: true}) {
-		if oi.Err != nil {
-			return oi.Err
+	// initiate local backup bucket
+	if !opts.disableLocalBackup {
+		dir, err := filepath.Abs(opts.localBackupDir)
+		if err != nil {
+			return nil, err
+		}
+		if opts.encryption.enabled && opts.localBackupFormat != backupFormatTarZst {
+			return nil, fmt.Errorf("--local-backup-encrypt requires --local-backup-format=%s", backupFormatTarZst)
 		}
-		fileCount += 1
-		obj, err := mc.GetObject(ctx, ms.bucket, oi.Key, minio.GetObjectOptions{})
+		recipients, err := opts.encryption.ageRecipients()
 		if err != nil {
-			return err
+			return nil, err
 		}
+		s.local, err = openLocalBackup(dir, opts.localBackupFormat, s.concurrency, recipients)
+		if err != nil {
+			return nil, err
+		}
+		s.manifest, err = openManifest(dir, keep, opts.src.String(), opts.dst.String())
+		if err != nil {
+			return nil, fmt.Errorf("failed to open backup manifest. Reason: %w", err)
+		}
+	}
 
-		data, err := io.ReadAll(obj)
+	if opts.serverSideCopy {
+		copier, err := newServerCopier(opts.src, opts.dst, sourceBucket(s.src), s.dst)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		if err = writeDataToDestination(ctx, oi.Key, data); err != nil {
-			return err
+		switch {
+		case copier == nil:
+		case s.local != nil:
+			fmt.Fprintln(s.out, "Both buckets are on the same provider, but the files are streamed for the local backup. Use --disable-local-backup to copy them within the provider.")
+		default:
+			copier.partSize = int64(s.partSize)
+			copier.concurrency = s.concurrency
+			copier.write = s.write
+			s.serverCopy = copier
 		}
-		fmt.Printf("%6d. %s\n", fileCount, oi.Key)
 	}
 
-	return nil
+	return s, nil
 }
 
-func copyFromSrc(ctx context.Context) (err error) {
-	var objs []*blob.ListObject
-	fileCount := 0
-	pageToken := blob.FirstPageToken
-	for pageToken != nil {
-		objs, pageToken, err = srcBucket.ListPage(ctx, pageToken, 20, &blob.ListOptions{})
+// openVersions prepares the replay of the source versions. The destination
+// must keep them, and the versions replayed by the previous runs are skipped
+// on resume or in incremental mode.
+func (s *swapper) openVersions(ctx context.Context, opts *swapOptions) error {
+	switch {
+	case s.sync:
+		return fmt.Errorf("--all-versions can't be used with --mode=%s", modeSync)
+	case opts.onlyKeysFrom != "":
+		return fmt.Errorf("--all-versions can't be used with --only-keys-from")
+	case opts.verify:
+		return fmt.Errorf("--verify compares the current versions only and can't be used with --all-versions. Run `ace cloud-swap verify` after the copy instead")
+	}
+	s.versionSrc = s.src.(*filteredSource).objectSource.(*versionSource)
+	if err := checkDestinationVersioning(ctx, opts.dst, s.dst); err != nil {
+		return err
+	}
+
+	path := opts.versionManifest
+	if path == "" {
+		var err error
+		path, err = defaultStateFile("versions", opts.src.String(), opts.dst.String()+opts.filter.String())
 		if err != nil {
 			return err
 		}
+	}
+	var err error
+	s.versions, err = openVersionManifest(path, opts.resume || opts.incremental)
+	if err != nil {
+		return fmt.Errorf("failed to open version manifest. Reason: %w", err)
+	}
+	return nil
+}
 
-		for _, obj := range objs {
-			fileCount += 1
-			data, err := srcFS.ReadFile(ctx, obj.Key)
-			if err != nil {
-				return errors.Wrapf(err, "read file from source")
-			}
+// sourceBucket returns the bucket a source reads from.
+func sourceBucket(src objectSource) *blob.Bucket {
+	switch src := src.(type) {
+	case *bucketSource:
+		return src.bucket
+	case *versionSource:
+		return src.bucket
+	case *filteredSource:
+		return sourceBucket(src.objectSource)
+	case *keyListSource:
+		return sourceBucket(src.objectSource)
+	}
+	return nil
+}
 
-			if err = writeDataToDestination(ctx, obj.Key, data); err != nil {
-				return err
-			}
-			fmt.Printf("%6d. %s\n", fileCount, obj.Key)
+// openSource opens the source bucket. Only the objects selected by the
+// mapper are listed, out of the keys given with --only-keys-from if any.
+func openSource(ctx context.Context, opts *swapOptions, mapper *keyMapper) (objectSource, error) {
+	var keys []string
+	if opts.onlyKeysFrom != "" {
+		var err error
+		if keys, err = readKeys(opts.onlyKeysFrom); err != nil {
+			return nil, fmt.Errorf("failed to read keys from %s. Reason: %w", opts.onlyKeysFrom, err)
 		}
 	}
-	return nil
+	bucket, err := openBucket(ctx, opts.src)
+	if err != nil {
+		return nil, err
+	}
+
+	var src objectSource = &bucketSource{bucket: bucket}
+	if opts.allVersions {
+		if src, err = newVersionSource(opts.src, bucket); err != nil {
+			_ = bucket.Close()
+			return nil, err
+		}
+	}
+	if opts.onlyKeysFrom != "" {
+		src = &keyListSource{objectSource: src, keys: keys, out: opts.output()}
+	}
+	return &filteredSource{
+		objectSource: src,
+		mapper:       mapper,
+	}, nil
 }
 
-func writeDataToDestination(ctx context.Context, fileName string, data []byte) error {
-	if !disableLocalBackup {
-		if err := localFS.WriteFile(ctx, fileName, data); err != nil {
-			return errors.Wrapf(err, "write file to local backup")
+// openErrorReport starts the error report of a copy.
+func (s *swapper) openErrorReport(opts *swapOptions) error {
+	path := opts.errorReport
+	if path == "" {
+		var err error
+		path, err = defaultErrorReport(opts.src.String(), opts.dst.String()+opts.filter.String())
+		if err != nil {
+			return err
 		}
 	}
+	var err error
+	if s.report, err = openErrorReport(path); err != nil {
+		return fmt.Errorf("failed to open error report. Reason: %w", err)
+	}
+	return nil
+}
+
+func (s *swapper) close() {
+	if s.src != nil {
+		_ = s.src.close()
+	}
+	if s.dst != nil {
+		_ = s.dst.Close()
+	}
+	if s.local != nil {
+		_ = s.local.close()
+	}
+	if s.checkpoint != nil {
+		_ = s.checkpoint.close()
+	}
+	if s.manifest != nil {
+		_ = s.manifest.close()
+	}
+	if s.versions != nil {
+		_ = s.versions.close()
+	}
+	if s.report != nil {
+		_ = s.report.close()
+	}
+}
 
-	if err := dstFS.WriteFile(ctx, fileName, data); err != nil {
-		return errors.Wrapf(err, "write file to destination")
+// finishBackup flushes the local backup and marks it as complete in its
+// manifest.
+func (s *swapper) finishBackup() error {
+	if s.local == nil {
+		return nil
+	}
+	err := s.local.close()
+	s.local = nil
+	if err != nil {
+		return fmt.Errorf("failed to write local backup. Reason: %w", err)
+	}
+	if err := s.manifest.complete(); err != nil {
+		return fmt.Errorf("failed to complete backup manifest. Reason: %w", err)
 	}
 	return nil
 }
+
+func localDefaultDir() string {
+	home := homedir.HomeDir()
+	return path.Join(home, "cloud-swap-backup")
+}
@@ -0,0 +1,172 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"go.bytebuilders.dev/ace/pkg/config"
+	"gocloud.dev/blob"
+	_ "gocloud.dev/blob/fileblob"
+)
+
+// testBucket is a fileblob bucket in a temporary directory.
+type testBucket struct {
+	dir    string
+	url    string
+	bucket *blob.Bucket
+}
+
+func newTestBucket(t *testing.T) *testBucket {
+	t.Helper()
+	return openTestBucket(t, t.TempDir())
+}
+
+// openTestBucket opens a directory as a fileblob bucket.
+func openTestBucket(t *testing.T, dir string) *testBucket {
+	t.Helper()
+	u := (&url.URL{Scheme: "file", Path: filepath.ToSlash(dir)}).String()
+	bucket, err := blob.OpenBucket(context.Background(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = bucket.Close() })
+	return &testBucket{dir: dir, url: u, bucket: bucket}
+}
+
+func (b *testBucket) options(side string) bucketOptions {
+	return bucketOptions{side: side, url: b.url}
+}
+
+func (b *testBucket) write(t *testing.T, objects map[string]string) {
+	t.Helper()
+	for key, content := range objects {
+		if err := b.bucket.WriteAll(context.Background(), key, []byte(content), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func (b *testBucket) delete(t *testing.T, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		if err := b.bucket.Delete(context.Background(), key); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// contents returns the objects of the bucket, keyed by their key.
+func (b *testBucket) contents(t *testing.T) map[string]string {
+	t.Helper()
+	out := map[string]string{}
+	it := b.bucket.List(nil)
+	for {
+		obj, err := it.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := b.bucket.ReadAll(context.Background(), obj.Key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[obj.Key] = string(data)
+	}
+	return out
+}
+
+// testSwapOptions returns the options of a copy between two test buckets,
+// with its state files in a temporary directory.
+func testSwapOptions(t *testing.T, src, dst *testBucket) *swapOptions {
+	t.Helper()
+	state := t.TempDir()
+	return &swapOptions{
+		src:                src.options("src"),
+		dst:                dst.options("dst"),
+		disableLocalBackup: true,
+		partSize:           defaultPartSize,
+		concurrency:        2,
+		mode:               modeCopy,
+		metadata:           metadataOptions{mode: metadataPreserve},
+		checkpointFile:     filepath.Join(state, "checkpoint.jsonl"),
+		errorReport:        filepath.Join(state, "errors.jsonl"),
+		versionManifest:    filepath.Join(state, "versions.jsonl"),
+		out:                io.Discard,
+	}
+}
+
+// countingSource counts the objects read from a source.
+type countingSource struct {
+	objectSource
+	opened atomic.Int32
+}
+
+func (s *countingSource) open(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.opened.Add(1)
+	return s.objectSource.open(ctx, key)
+}
+
+// runTestCopy copies the objects like runCopy, counting the objects read
+// from the source.
+func runTestCopy(t *testing.T, opts *swapOptions) (copyStats, *countingSource) {
+	t.Helper()
+	ctx := context.Background()
+	s, err := newSwapper(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close()
+	src := &countingSource{objectSource: s.src}
+	s.src = src
+	if err := s.openErrorReport(opts); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := s.copyAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.finishBackup(); err != nil {
+		t.Fatal(err)
+	}
+	if s.sync {
+		if err := s.deleteStale(ctx, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return stats, src
+}
+
+// testRestoreOptions returns the options of a restore of a local backup into
+// a test bucket, with its state files in a temporary directory.
+func testRestoreOptions(t *testing.T, dir string, dst *testBucket) *restoreOptions {
+	t.Helper()
+	t.Setenv(config.ACESTATEDIR, t.TempDir())
+	return &restoreOptions{
+		from:        dir,
+		dst:         dst.options("dst"),
+		partSize:    defaultPartSize,
+		concurrency: 2,
+	}
+}
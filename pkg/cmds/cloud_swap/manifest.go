@@ -0,0 +1,173 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupManifestFile is written in the local backup directory. It lists the
+// objects of the backup, so that a restore can check its completeness.
+const backupManifestFile = ".cloud-swap-manifest.jsonl"
+
+const (
+	manifestKindHeader   = "header"
+	manifestKindObject   = "object"
+	manifestKindComplete = "complete"
+)
+
+// manifestRecord is a line of the backup manifest. A header is written when a
+// copy starts, an object record for every object of the backup, and a
+// complete record once the copy has finished successfully. The objects of an
+// archived backup are located by their archive part and entry index. Their
+// key is left out if the archive is encrypted.
+type manifestRecord struct {
+	Kind        string    `json:"kind"`
+	Time        time.Time `json:"time"`
+	Source      string    `json:"source,omitempty"`
+	Destination string    `json:"destination,omitempty"`
+	Key         string    `json:"key,omitempty"`
+	Size        int64     `json:"size,omitempty"`
+	MD5         []byte    `json:"md5,omitempty"`
+	Archive     string    `json:"archive,omitempty"`
+	Entry       int       `json:"entry,omitempty"`
+	Objects     int       `json:"objects,omitempty"`
+}
+
+// backupManifest records the objects written to the local backup.
+type backupManifest struct {
+	mu      sync.Mutex
+	file    *os.File
+	objects int
+}
+
+// openManifest starts a run in the manifest of the backup directory. The
+// records of the previous runs are kept if appendTo is true.
+func openManifest(dir string, appendTo bool, src, dst string) (*backupManifest, error) {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if !appendTo {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(filepath.Join(dir, backupManifestFile), flags, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	m := &backupManifest{file: file}
+	if err := m.write(manifestRecord{Kind: manifestKindHeader, Source: src, Destination: dst}); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *backupManifest) record(rec manifestRecord) error {
+	m.mu.Lock()
+	m.objects += 1
+	m.mu.Unlock()
+	rec.Kind = manifestKindObject
+	return m.write(rec)
+}
+
+// complete marks the backup as complete.
+func (m *backupManifest) complete() error {
+	m.mu.Lock()
+	objects := m.objects
+	m.mu.Unlock()
+	return m.write(manifestRecord{Kind: manifestKindComplete, Objects: objects})
+}
+
+func (m *backupManifest) write(rec manifestRecord) error {
+	rec.Time = time.Now()
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err = m.file.Write(append(data, '\n'))
+	return err
+}
+
+func (m *backupManifest) close() error {
+	return m.file.Close()
+}
+
+// backupContents is the state of a backup described by its manifest.
+type backupContents struct {
+	// objects lists the object records in the order they were written
+	objects []manifestRecord
+	// complete is true if the last copy into the backup finished
+	complete bool
+	source   string
+}
+
+// readManifest reads the manifest of a backup directory.
+func readManifest(dir string) (*backupContents, error) {
+	file, err := os.Open(filepath.Join(dir, backupManifestFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%s has no backup manifest. Only backups taken by cloud-swap can be restored", dir)
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	contents := &backupContents{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec manifestRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("failed to parse backup manifest. Reason: %w", err)
+		}
+		switch rec.Kind {
+		case manifestKindHeader:
+			contents.complete = false
+			contents.source = rec.Source
+		case manifestKindObject:
+			contents.objects = append(contents.objects, rec)
+		case manifestKindComplete:
+			contents.complete = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return contents, nil
+}
+
+// encrypted reports whether the backup has encrypted archives.
+func (c *backupContents) encrypted() bool {
+	for _, rec := range c.objects {
+		if strings.HasSuffix(rec.Archive, encryptedSuffix) {
+			return true
+		}
+	}
+	return false
+}
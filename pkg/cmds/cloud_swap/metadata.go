@@ -0,0 +1,149 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"gocloud.dev/blob"
+)
+
+const (
+	metadataPreserve = "preserve"
+	metadataDrop     = "drop"
+	metadataOverride = "override"
+
+	// sourceModTimeKey is the user metadata key the modification time of the
+	// source object is preserved in, since providers don't allow setting it.
+	sourceModTimeKey = "source-mtime"
+)
+
+// objectMeta holds the attributes of an object that are carried over to the
+// destination.
+type objectMeta struct {
+	ContentType        string
+	ContentEncoding    string
+	CacheControl       string
+	ContentDisposition string
+	ContentLanguage    string
+	Metadata           map[string]string
+}
+
+// metadataOptions choose how the object attributes are written at the
+// destination.
+type metadataOptions struct {
+	mode string
+	set  map[string]string
+}
+
+func addMetadataFlags(fs *pflag.FlagSet, opts *metadataOptions) {
+	fs.StringVar(&opts.mode, "metadata", metadataPreserve, "How the object attributes are copied (any of preserve,drop,override). Override preserves the source attributes, replacing the ones given with --metadata-set.")
+	fs.StringToStringVar(&opts.set, "metadata-set", nil, "Attributes written with --metadata=override (i.e. cache-control=max-age=3600). Keys other than content-type, content-encoding, cache-control, content-disposition and content-language are set as user metadata.")
+}
+
+// metadataPolicy computes the writer options of the copied objects.
+type metadataPolicy struct {
+	mode      string
+	overrides objectMeta
+}
+
+func newMetadataPolicy(opts metadataOptions) (*metadataPolicy, error) {
+	p := &metadataPolicy{mode: opts.mode}
+	switch opts.mode {
+	case metadataPreserve, metadataDrop:
+		if len(opts.set) > 0 {
+			return nil, fmt.Errorf("--metadata-set requires --metadata=%s", metadataOverride)
+		}
+	case metadataOverride:
+		if len(opts.set) == 0 {
+			return nil, fmt.Errorf("--metadata=%s requires at least one --metadata-set attribute", metadataOverride)
+		}
+		p.overrides.Metadata = make(map[string]string)
+		for key, val := range opts.set {
+			switch strings.ToLower(key) {
+			case "content-type":
+				p.overrides.ContentType = val
+			case "content-encoding":
+				p.overrides.ContentEncoding = val
+			case "cache-control":
+				p.overrides.CacheControl = val
+			case "content-disposition":
+				p.overrides.ContentDisposition = val
+			case "content-language":
+				p.overrides.ContentLanguage = val
+			default:
+				p.overrides.Metadata[strings.ToLower(key)] = val
+			}
+		}
+	default:
+		return nil, fmt.Errorf("invalid metadata mode %q. Supported modes are %s, %s and %s", opts.mode, metadataPreserve, metadataDrop, metadataOverride)
+	}
+	return p, nil
+}
+
+// needsAttributes reports whether the attributes of the source objects have
+// to be read before copying them.
+func (p *metadataPolicy) needsAttributes() bool {
+	return p.mode != metadataDrop
+}
+
+// writerOptions returns the options the copy of obj is written with. When the
+// metadata is dropped, the destination detects the content type on its own.
+func (p *metadataPolicy) writerOptions(obj objectInfo) *blob.WriterOptions {
+	if p.mode == metadataDrop || obj.Meta == nil {
+		return &blob.WriterOptions{}
+	}
+
+	meta := *obj.Meta
+	meta.Metadata = make(map[string]string, len(obj.Meta.Metadata)+1)
+	for key, val := range obj.Meta.Metadata {
+		meta.Metadata[strings.ToLower(key)] = val
+	}
+	// keep the time of the original object when copying a copy
+	if _, ok := meta.Metadata[sourceModTimeKey]; !ok && !obj.ModTime.IsZero() {
+		meta.Metadata[sourceModTimeKey] = obj.ModTime.UTC().Format(time.RFC3339Nano)
+	}
+
+	if p.mode == metadataOverride {
+		meta.ContentType = override(meta.ContentType, p.overrides.ContentType)
+		meta.ContentEncoding = override(meta.ContentEncoding, p.overrides.ContentEncoding)
+		meta.CacheControl = override(meta.CacheControl, p.overrides.CacheControl)
+		meta.ContentDisposition = override(meta.ContentDisposition, p.overrides.ContentDisposition)
+		meta.ContentLanguage = override(meta.ContentLanguage, p.overrides.ContentLanguage)
+		maps.Copy(meta.Metadata, p.overrides.Metadata)
+	}
+
+	return &blob.WriterOptions{
+		ContentType:        meta.ContentType,
+		ContentEncoding:    meta.ContentEncoding,
+		CacheControl:       meta.CacheControl,
+		ContentDisposition: meta.ContentDisposition,
+		ContentLanguage:    meta.ContentLanguage,
+		Metadata:           meta.Metadata,
+	}
+}
+
+func override(val, newVal string) string {
+	if newVal != "" {
+		return newVal
+	}
+	return val
+}
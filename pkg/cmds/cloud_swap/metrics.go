@@ -0,0 +1,139 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	sideSource      = "source"
+	sideDestination = "destination"
+)
+
+// copyMetrics are the Prometheus metrics of a copy. The methods do nothing
+// on a nil receiver, so that the metrics are optional.
+type copyMetrics struct {
+	listedObjects prometheus.Counter
+	listedBytes   prometheus.Counter
+	objects       *prometheus.CounterVec
+	bytesCopied   prometheus.Counter
+	errors        *prometheus.CounterVec
+	duration      *prometheus.HistogramVec
+}
+
+func newCopyMetrics(reg prometheus.Registerer) *copyMetrics {
+	m := &copyMetrics{
+		listedObjects: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "cloud_swap_listed_objects_total",
+			Help: "Number of source objects listed.",
+		}),
+		listedBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "cloud_swap_listed_bytes_total",
+			Help: "Size of the source objects listed.",
+		}),
+		objects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cloud_swap_objects_total",
+			Help: "Number of objects done with, by result (copied, skipped or failed).",
+		}, []string{"result"}),
+		bytesCopied: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "cloud_swap_bytes_copied_total",
+			Help: "Number of bytes transferred to the destination.",
+		}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cloud_swap_errors_total",
+			Help: "Number of failed attempts, including the retried ones, by operation.",
+		}, []string{"operation"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cloud_swap_request_duration_seconds",
+			Help:    "Latency of the requests to the source and destination, by side and operation. Writes last until the whole object is uploaded.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 4, 10),
+		}, []string{"side", "operation"}),
+	}
+	reg.MustRegister(m.listedObjects, m.listedBytes, m.objects, m.bytesCopied, m.errors, m.duration)
+	return m
+}
+
+// serveMetrics exposes the metrics gathered by g at /metrics of addr until
+// ctx is done.
+func serveMetrics(ctx context.Context, addr string, g prometheus.Gatherer) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to serve metrics. Reason: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(g, promhttp.HandlerOpts{}))
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+	go func() {
+		if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("WARNING: failed to serve metrics. Reason: %v\n", err)
+		}
+	}()
+	fmt.Printf("Serving metrics at http://%s/metrics\n", l.Addr())
+	return nil
+}
+
+func (m *copyMetrics) listed(size int64) {
+	if m == nil {
+		return
+	}
+	m.listedObjects.Inc()
+	m.listedBytes.Add(float64(size))
+}
+
+func (m *copyMetrics) transfer(n int) {
+	if m == nil {
+		return
+	}
+	m.bytesCopied.Add(float64(n))
+}
+
+// finished counts an object by result.
+func (m *copyMetrics) finished(result string) {
+	if m == nil {
+		return
+	}
+	m.objects.WithLabelValues(result).Inc()
+}
+
+func (m *copyMetrics) failed(op string) {
+	if m == nil {
+		return
+	}
+	m.errors.WithLabelValues(op).Inc()
+}
+
+// observe records the latency of a request started at start.
+func (m *copyMetrics) observe(side, op string, start time.Time) {
+	if m == nil {
+		return
+	}
+	m.duration.WithLabelValues(side, op).Observe(time.Since(start).Seconds())
+}
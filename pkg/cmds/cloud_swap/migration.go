@@ -0,0 +1,528 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	pairSucceeded = "succeeded"
+	pairFailed    = "failed"
+	pairSkipped   = "skipped"
+)
+
+// migrationSpec is the file given with --file. It lists the source and
+// destination pairs of a migration, i.e. the registry, backups, assets and
+// logs buckets of a platform.
+type migrationSpec struct {
+	// Parallelism is the number of pairs copied at the same time. The pairs
+	// are copied one after another by default.
+	Parallelism int             `json:"parallelism,omitempty"`
+	Pairs       []migrationPair `json:"pairs"`
+}
+
+// migrationPair is one copy of a migration. The fields left empty take the
+// value of the matching command line flag.
+type migrationPair struct {
+	Name        string          `json:"name"`
+	Source      migrationBucket `json:"source"`
+	Destination migrationBucket `json:"destination"`
+	Filter      migrationFilter `json:"filter,omitempty"`
+
+	Concurrency    *int              `json:"concurrency,omitempty"`
+	Retries        *int              `json:"retries,omitempty"`
+	MaxErrors      *int              `json:"maxErrors,omitempty"`
+	PartSize       string            `json:"partSize,omitempty"`
+	BandwidthLimit string            `json:"bandwidthLimit,omitempty"`
+	Mode           string            `json:"mode,omitempty"`
+	Delete         *bool             `json:"delete,omitempty"`
+	Metadata       string            `json:"metadata,omitempty"`
+	MetadataSet    map[string]string `json:"metadataSet,omitempty"`
+	Verify         *bool             `json:"verify,omitempty"`
+	AllVersions    *bool             `json:"allVersions,omitempty"`
+	ServerSideCopy *bool             `json:"serverSideCopy,omitempty"`
+	StorageClass   string            `json:"storageClass,omitempty"`
+	SSE            string            `json:"sse,omitempty"`
+	SSEKMSKeyID    string            `json:"sseKMSKeyID,omitempty"`
+	SSECKeyFile    string            `json:"sseCKeyFile,omitempty"`
+
+	CheckpointFile  string `json:"checkpointFile,omitempty"`
+	ErrorReport     string `json:"errorReport,omitempty"`
+	VersionManifest string `json:"versionManifest,omitempty"`
+
+	LocalBackup migrationLocalBackup `json:"localBackup,omitempty"`
+}
+
+// migrationBucket is one side of a pair, given either as a gocloud.dev URL
+// or as a bucket of an S3 compatible endpoint.
+type migrationBucket struct {
+	URL                string `json:"url,omitempty"`
+	CredentialsFile    string `json:"credentialsFile,omitempty"`
+	Profile            string `json:"profile,omitempty"`
+	Endpoint           string `json:"endpoint,omitempty"`
+	Bucket             string `json:"bucket,omitempty"`
+	Region             string `json:"region,omitempty"`
+	Addressing         string `json:"addressing,omitempty"`
+	CAFile             string `json:"caFile,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+}
+
+type migrationFilter struct {
+	Include   []string `json:"include,omitempty"`
+	Exclude   []string `json:"exclude,omitempty"`
+	SrcPrefix string   `json:"srcPrefix,omitempty"`
+	DstPrefix string   `json:"dstPrefix,omitempty"`
+	Rename    []string `json:"rename,omitempty"`
+}
+
+// migrationLocalBackup configures the local backup of a pair. By default it
+// is kept in a directory named after the pair under --local-backup-dir.
+type migrationLocalBackup struct {
+	Disabled       *bool    `json:"disabled,omitempty"`
+	Dir            string   `json:"dir,omitempty"`
+	Format         string   `json:"format,omitempty"`
+	Encrypt        *bool    `json:"encrypt,omitempty"`
+	Recipients     []string `json:"recipients,omitempty"`
+	PassphraseFile string   `json:"passphraseFile,omitempty"`
+}
+
+func loadMigrationSpec(file string) (*migrationSpec, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration file. Reason: %w", err)
+	}
+	var spec migrationSpec
+	if err := yaml.UnmarshalStrict(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse migration file %s. Reason: %w", file, err)
+	}
+	if len(spec.Pairs) == 0 {
+		return nil, fmt.Errorf("migration file %s has no pairs", file)
+	}
+	if spec.Parallelism < 0 {
+		return nil, fmt.Errorf("parallelism can't be negative")
+	}
+	names := map[string]bool{}
+	for i, pair := range spec.Pairs {
+		if pair.Name == "" {
+			return nil, fmt.Errorf("pair %d has no name", i+1)
+		}
+		if strings.ContainsAny(pair.Name, `/\`) {
+			return nil, fmt.Errorf("invalid pair name %q. The name is used as a directory name", pair.Name)
+		}
+		if names[pair.Name] {
+			return nil, fmt.Errorf("pair name %q is used more than once", pair.Name)
+		}
+		names[pair.Name] = true
+	}
+	return &spec, nil
+}
+
+// options returns the options of the pair, starting from the command line
+// flags.
+func (p migrationPair) options(base *swapOptions) (*swapOptions, error) {
+	opts := *base
+	var err error
+	if opts.src, err = p.Source.options("src"); err != nil {
+		return nil, fmt.Errorf("pair %s: %w", p.Name, err)
+	}
+	if opts.dst, err = p.Destination.options("dst"); err != nil {
+		return nil, fmt.Errorf("pair %s: %w", p.Name, err)
+	}
+
+	f := p.Filter
+	if f.Include != nil {
+		opts.filter.include = f.Include
+	}
+	if f.Exclude != nil {
+		opts.filter.exclude = f.Exclude
+	}
+	if f.SrcPrefix != "" {
+		opts.filter.srcPrefix = f.SrcPrefix
+	}
+	if f.DstPrefix != "" {
+		opts.filter.dstPrefix = f.DstPrefix
+	}
+	if f.Rename != nil {
+		opts.filter.rename = f.Rename
+	}
+
+	if p.Concurrency != nil {
+		opts.concurrency = *p.Concurrency
+	}
+	if p.Retries != nil {
+		opts.retries = *p.Retries
+	}
+	if p.MaxErrors != nil {
+		opts.maxErrors = *p.MaxErrors
+	}
+	if p.PartSize != "" {
+		opts.partSize = p.PartSize
+	}
+	if p.BandwidthLimit != "" {
+		// the pair has its own limit instead of the one shared by the pairs
+		opts.bandwidthLimit = p.BandwidthLimit
+		opts.limiter = nil
+	}
+	if p.Mode != "" {
+		opts.mode = p.Mode
+	}
+	if p.Delete != nil {
+		opts.delete = *p.Delete
+	}
+	if p.Metadata != "" {
+		opts.metadata.mode = p.Metadata
+	}
+	if p.MetadataSet != nil {
+		opts.metadata.set = p.MetadataSet
+	}
+	if p.Verify != nil {
+		opts.verify = *p.Verify
+	}
+	if p.AllVersions != nil {
+		opts.allVersions = *p.AllVersions
+	}
+	if p.ServerSideCopy != nil {
+		opts.serverSideCopy = *p.ServerSideCopy
+	}
+	if p.StorageClass != "" {
+		opts.destination.storageClass = p.StorageClass
+	}
+	if p.SSE != "" || p.SSECKeyFile != "" {
+		opts.destination.sse = p.SSE
+		opts.destination.kmsKeyID = p.SSEKMSKeyID
+		opts.destination.sseCKeyFile = p.SSECKeyFile
+	}
+	opts.checkpointFile = p.CheckpointFile
+	opts.errorReport = p.ErrorReport
+	opts.versionManifest = p.VersionManifest
+	if opts.mode == modeSync && (opts.resume || opts.incremental) {
+		return nil, fmt.Errorf("pair %s: mode %s can't be used with --resume or --incremental", p.Name, modeSync)
+	}
+
+	b := p.LocalBackup
+	if b.Disabled != nil {
+		opts.disableLocalBackup = *b.Disabled
+	}
+	opts.localBackupDir = filepath.Join(base.localBackupDir, p.Name)
+	if b.Dir != "" {
+		opts.localBackupDir = b.Dir
+	}
+	if b.Format != "" {
+		opts.localBackupFormat = b.Format
+	}
+	if b.Encrypt != nil {
+		opts.encryption.enabled = *b.Encrypt
+	}
+	if b.Recipients != nil || b.PassphraseFile != "" {
+		opts.encryption.recipients = b.Recipients
+		opts.encryption.passphraseFile = b.PassphraseFile
+	}
+	if len(opts.encryption.recipients) > 0 && opts.encryption.passphraseFile != "" {
+		return nil, fmt.Errorf("pair %s: the local backup can be encrypted either to recipients or with a passphrase file", p.Name)
+	}
+	return &opts, nil
+}
+
+func (b migrationBucket) options(side string) (bucketOptions, error) {
+	opts := bucketOptions{
+		side: side,
+		url:  b.URL,
+		credentials: credentialOptions{
+			file:    b.CredentialsFile,
+			profile: b.Profile,
+		},
+		endpoint: endpointOptions{
+			endpoint:           b.Endpoint,
+			bucket:             b.Bucket,
+			region:             b.Region,
+			addressing:         b.Addressing,
+			caFile:             b.CAFile,
+			insecureSkipVerify: b.InsecureSkipVerify,
+		},
+	}
+	switch {
+	case b.URL == "" && b.Endpoint == "":
+		return opts, fmt.Errorf("%s needs a url or an endpoint", sideName(side))
+	case b.URL != "" && b.Endpoint != "":
+		return opts, fmt.Errorf("%s can't have both a url and an endpoint", sideName(side))
+	case b.Endpoint != "" && b.Bucket == "":
+		return opts, fmt.Errorf("%s endpoint needs a bucket", sideName(side))
+	}
+	if opts.endpoint.region == "" {
+		opts.endpoint.region = defaultRegion
+	}
+	if opts.endpoint.addressing == "" {
+		opts.endpoint.addressing = addressingPath
+	}
+	return opts, opts.validate()
+}
+
+// pairResult is a row of the consolidated report of a migration.
+type pairResult struct {
+	Name        string        `json:"name"`
+	Source      string        `json:"source"`
+	Destination string        `json:"destination"`
+	Status      string        `json:"status"`
+	Copied      int           `json:"copied"`
+	Skipped     int           `json:"skipped"`
+	Failed      int           `json:"failed"`
+	Verified    bool          `json:"verified"`
+	Mismatches  int           `json:"mismatches,omitempty"`
+	ErrorReport string        `json:"errorReport,omitempty"`
+	Duration    time.Duration `json:"-"`
+	Seconds     float64       `json:"durationSeconds"`
+	Error       string        `json:"error,omitempty"`
+}
+
+// runMigration copies the pairs of the migration file, then prints one
+// report for all of them. A failed pair does not stop the other ones.
+func runMigration(ctx context.Context, base *swapOptions) error {
+	spec, err := loadMigrationSpec(base.file)
+	if err != nil {
+		return err
+	}
+	pairs := make([]*swapOptions, len(spec.Pairs))
+	for i, p := range spec.Pairs {
+		if pairs[i], err = p.options(base); err != nil {
+			return err
+		}
+	}
+
+	if base.dryRun {
+		for i, opts := range pairs {
+			fmt.Printf("==> Pair %d/%d: %s (%s -> %s)\n\n", i+1, len(pairs), spec.Pairs[i].Name, opts.src, opts.dst)
+			if err := planCopy(ctx, opts); err != nil {
+				return fmt.Errorf("pair %s: %w", spec.Pairs[i].Name, err)
+			}
+			fmt.Println()
+		}
+		return nil
+	}
+
+	parallel := spec.Parallelism > 1
+	if parallel {
+		// nothing can be asked from the user while the output of the pairs is
+		// interleaved
+		for i, opts := range pairs {
+			if opts.delete && !opts.yes {
+				return fmt.Errorf("pair %s: deleting while the pairs run in parallel requires --yes", spec.Pairs[i].Name)
+			}
+			e := opts.encryption
+			if e.enabled && len(e.recipients) == 0 && e.passphraseFile == "" && !opts.disableLocalBackup {
+				return fmt.Errorf("pair %s: encrypting the local backup while the pairs run in parallel requires recipients or a passphrase file", spec.Pairs[i].Name)
+			}
+		}
+	}
+
+	// the command line limit is shared by the pairs without a limit of their
+	// own
+	limiter, err := newBandwidthLimiter(base.bandwidthLimit)
+	if err != nil {
+		return err
+	}
+	var registry *prometheus.Registry
+	if base.metricsAddr != "" {
+		registry = prometheus.NewRegistry()
+		metricsCtx, stopMetrics := context.WithCancel(ctx)
+		defer stopMetrics()
+		if err := serveMetrics(metricsCtx, base.metricsAddr, registry); err != nil {
+			return err
+		}
+	}
+	for i, opts := range pairs {
+		if spec.Pairs[i].BandwidthLimit == "" {
+			opts.limiter = limiter
+		}
+		if registry != nil {
+			opts.registerer = prometheus.WrapRegistererWith(prometheus.Labels{"pair": spec.Pairs[i].Name}, registry)
+		}
+	}
+
+	results := make([]*pairResult, len(pairs))
+	run := func(i int, out io.Writer) {
+		results[i] = runPair(ctx, spec.Pairs[i].Name, pairs[i], out)
+	}
+	if parallel {
+		var mu sync.Mutex
+		g := &errgroup.Group{}
+		g.SetLimit(spec.Parallelism)
+		for i := range pairs {
+			g.Go(func() error {
+				out := &prefixWriter{mu: &mu, w: os.Stdout, prefix: "[" + spec.Pairs[i].Name + "] "}
+				run(i, out)
+				out.flush()
+				return nil
+			})
+		}
+		_ = g.Wait()
+	} else {
+		for i, opts := range pairs {
+			fmt.Printf("==> Pair %d/%d: %s (%s -> %s)\n\n", i+1, len(pairs), spec.Pairs[i].Name, opts.src, opts.dst)
+			run(i, os.Stdout)
+		}
+	}
+
+	if err := printMigrationReport(results, base.reportFile); err != nil {
+		return err
+	}
+	failed := 0
+	for _, r := range results {
+		if r.Status != pairSucceeded {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d pairs did not complete", failed, len(results))
+	}
+	return nil
+}
+
+func runPair(ctx context.Context, name string, opts *swapOptions, out io.Writer) *pairResult {
+	r := &pairResult{
+		Name:        name,
+		Source:      opts.src.String(),
+		Destination: opts.dst.String(),
+		Status:      pairSkipped,
+	}
+	if ctx.Err() != nil {
+		r.Error = "interrupted before the copy started"
+		return r
+	}
+
+	opts.out = out
+	start := time.Now()
+	result, err := runCopy(ctx, opts)
+	r.Duration = time.Since(start)
+	r.Seconds = r.Duration.Seconds()
+	r.Copied = result.stats.copied
+	r.Skipped = result.stats.skipped
+	r.Failed = result.stats.failed
+	if result.stats.failed > 0 {
+		r.ErrorReport = result.errorReport
+	}
+	if v := result.verification; v != nil {
+		r.Verified = true
+		r.Mismatches = v.discrepancies()
+	}
+	r.Status = pairSucceeded
+	if err != nil {
+		r.Status = pairFailed
+		r.Error = err.Error()
+		fmt.Fprintf(out, "ERROR: %v\n", err)
+	}
+	return r
+}
+
+func printMigrationReport(results []*pairResult, reportFile string) error {
+	fmt.Println("\nMigration report:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSOURCE\tDESTINATION\tCOPIED\tSKIPPED\tFAILED\tVERIFY\tDURATION\tSTATUS")
+	for _, r := range results {
+		verify := "-"
+		if r.Verified {
+			verify = "ok"
+			if r.Mismatches > 0 {
+				verify = fmt.Sprintf("%d mismatches", r.Mismatches)
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\n", r.Name, r.Source, r.Destination, r.Copied, r.Skipped, r.Failed, verify, r.Duration.Round(time.Second), r.Status)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	printed := false
+	for _, r := range results {
+		if r.Error == "" {
+			continue
+		}
+		if !printed {
+			fmt.Println("\nErrors:")
+			printed = true
+		}
+		fmt.Printf("  %s: %s\n", r.Name, r.Error)
+	}
+
+	if reportFile == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(reportFile, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write migration report. Reason: %w", err)
+	}
+	fmt.Printf("\nThe migration report is written to %s\n", reportFile)
+	return nil
+}
+
+// prefixWriter prefixes every line written to it with the name of a pair,
+// so that the output of parallel pairs can be told apart. Lines are written
+// whole, under a lock shared by the pairs.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.buf = append(p.buf, data...)
+	i := bytes.LastIndexByte(p.buf, '\n')
+	if i < 0 {
+		return len(data), nil
+	}
+	lines := p.buf[:i+1]
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(lines) > 0 {
+		n := bytes.IndexByte(lines, '\n')
+		line := lines[:n+1]
+		lines = lines[n+1:]
+		if n == 0 {
+			// blank lines only separate the output of a pair run alone
+			continue
+		}
+		if _, err := fmt.Fprintf(p.w, "%s%s", p.prefix, line); err != nil {
+			return 0, err
+		}
+	}
+	p.buf = append(p.buf[:0], p.buf[i+1:]...)
+	return len(data), nil
+}
+
+// flush writes the last line, if it was not terminated.
+func (p *prefixWriter) flush() {
+	if len(p.buf) > 0 {
+		_, _ = p.Write([]byte("\n"))
+	}
+}
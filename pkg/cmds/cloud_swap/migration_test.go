@@ -0,0 +1,218 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadMigrationSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			spec: `
+parallelism: 2
+pairs:
+- name: registry
+  source:
+    url: s3://registry
+  destination:
+    endpoint: https://minio.example.com
+    bucket: registry
+- name: backups
+  source:
+    url: gs://backups
+  destination:
+    url: s3://backups
+`,
+		},
+		{
+			name:    "unknown field",
+			spec:    "pairs:\n- name: registry\n  sorce:\n    url: s3://registry\n",
+			wantErr: `unknown field "sorce"`,
+		},
+		{
+			name:    "no pairs",
+			spec:    "parallelism: 2\n",
+			wantErr: "has no pairs",
+		},
+		{
+			name:    "negative parallelism",
+			spec:    "parallelism: -1\npairs:\n- name: registry\n",
+			wantErr: "parallelism can't be negative",
+		},
+		{
+			name:    "no name",
+			spec:    "pairs:\n- name: registry\n- source:\n    url: s3://assets\n",
+			wantErr: "pair 2 has no name",
+		},
+		{
+			name:    "path in name",
+			spec:    "pairs:\n- name: platform/registry\n",
+			wantErr: `invalid pair name "platform/registry"`,
+		},
+		{
+			name:    "duplicate name",
+			spec:    "pairs:\n- name: registry\n- name: registry\n",
+			wantErr: `pair name "registry" is used more than once`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "migration.yaml")
+			if err := os.WriteFile(file, []byte(tt.spec), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			spec, err := loadMigrationSpec(file)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if spec.Parallelism != 2 || len(spec.Pairs) != 2 {
+				t.Fatalf("spec = %+v, want 2 pairs copied 2 at a time", spec)
+			}
+			dst := spec.Pairs[0].Destination
+			if dst.Endpoint != "https://minio.example.com" || dst.Bucket != "registry" {
+				t.Errorf("destination = %+v", dst)
+			}
+		})
+	}
+}
+
+func TestMigrationPairOptions(t *testing.T) {
+	base := &swapOptions{
+		concurrency:       4,
+		retries:           3,
+		mode:              modeCopy,
+		localBackupDir:    "/backup",
+		localBackupFormat: backupFormatDir,
+		filter:            filterOptions{exclude: []string{"tmp/**"}},
+		metadata:          metadataOptions{mode: metadataPreserve},
+	}
+	concurrency, verify := 8, true
+	pair := migrationPair{
+		Name:        "registry",
+		Source:      migrationBucket{URL: "s3://registry"},
+		Destination: migrationBucket{Endpoint: "https://minio.example.com", Bucket: "registry"},
+		Filter:      migrationFilter{DstPrefix: "mirror/"},
+		Concurrency: &concurrency,
+		Mode:        modeSync,
+		Verify:      &verify,
+	}
+	opts, err := pair.options(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opts.concurrency != 8 || opts.retries != 3 || opts.mode != modeSync || !opts.verify {
+		t.Errorf("options = %+v, want the pair values over the flags", opts)
+	}
+	wantFilter := filterOptions{exclude: []string{"tmp/**"}, dstPrefix: "mirror/"}
+	if !reflect.DeepEqual(opts.filter, wantFilter) {
+		t.Errorf("filter = %+v, want %+v", opts.filter, wantFilter)
+	}
+	if opts.localBackupDir != filepath.Join("/backup", "registry") {
+		t.Errorf("local backup dir = %s, want a directory of the pair", opts.localBackupDir)
+	}
+	e := opts.dst.endpoint
+	if e.region != defaultRegion || e.addressing != addressingPath {
+		t.Errorf("destination endpoint = %+v, want the default region and addressing", e)
+	}
+	if base.concurrency != 4 || base.mode != modeCopy {
+		t.Error("the options of a pair changed the command line options")
+	}
+}
+
+func TestMigrationPairOptionsErrors(t *testing.T) {
+	src := migrationBucket{URL: "s3://registry"}
+	dst := migrationBucket{URL: "gs://registry"}
+	tests := []struct {
+		name    string
+		base    swapOptions
+		pair    migrationPair
+		wantErr string
+	}{
+		{
+			name:    "no source",
+			pair:    migrationPair{Name: "registry", Destination: dst},
+			wantErr: "source needs a url or an endpoint",
+		},
+		{
+			name:    "url and endpoint",
+			pair:    migrationPair{Name: "registry", Source: src, Destination: migrationBucket{URL: "gs://registry", Endpoint: "https://minio.example.com"}},
+			wantErr: "destination can't have both a url and an endpoint",
+		},
+		{
+			name:    "endpoint without bucket",
+			pair:    migrationPair{Name: "registry", Source: migrationBucket{Endpoint: "https://minio.example.com"}, Destination: dst},
+			wantErr: "source endpoint needs a bucket",
+		},
+		{
+			name:    "invalid endpoint",
+			pair:    migrationPair{Name: "registry", Source: src, Destination: migrationBucket{Endpoint: "ftp://minio.example.com", Bucket: "registry"}},
+			wantErr: "The scheme must be http or https",
+		},
+		{
+			name:    "sync with resume",
+			base:    swapOptions{resume: true},
+			pair:    migrationPair{Name: "registry", Source: src, Destination: dst, Mode: modeSync},
+			wantErr: "pair registry: mode sync can't be used with --resume",
+		},
+		{
+			name: "recipients and passphrase",
+			base: swapOptions{encryption: encryptionOptions{passphraseFile: "passphrase.txt"}},
+			pair: migrationPair{Name: "registry", Source: src, Destination: dst, LocalBackup: migrationLocalBackup{
+				Recipients: []string{"age1recipient"},
+			}},
+		},
+		{
+			name: "recipients and passphrase of the pair",
+			pair: migrationPair{Name: "registry", Source: src, Destination: dst, LocalBackup: migrationLocalBackup{
+				Recipients:     []string{"age1recipient"},
+				PassphraseFile: "passphrase.txt",
+			}},
+			wantErr: "either to recipients or with a passphrase file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.pair.options(&tt.base)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("error = %v, want the recipients of the pair to replace the passphrase file", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	defaultThroughput = "50MiB"
+	largestObjects    = 10
+)
+
+// copyPlan summarizes what a copy would do without writing anything.
+type copyPlan struct {
+	objects        int
+	bytes          uint64
+	existing       int
+	identical      int
+	identicalBytes uint64
+	largest        objectHeap
+	// stale lists the destination objects missing at the source. It is
+	// computed in sync mode only.
+	stale []string
+}
+
+// planCopy lists the source and the destination and prints the copy plan
+// along with an estimation of the time it would take. The objects are
+// compared the same way as the copy does, hashing them when the provider
+// doesn't expose their MD5 checksum. The keys and attributes of the
+// destination objects are held in memory while the source is listed, about
+// 200 bytes per object.
+func planCopy(ctx context.Context, opts *swapOptions) error {
+	throughput, err := humanize.ParseBytes(opts.throughput)
+	if err != nil || throughput == 0 {
+		return fmt.Errorf("invalid throughput %q", opts.throughput)
+	}
+	if opts.mode != modeCopy && opts.mode != modeSync {
+		return fmt.Errorf("invalid mode %q. Supported modes are %s and %s", opts.mode, modeCopy, modeSync)
+	}
+	if opts.concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1")
+	}
+
+	mapper, err := newKeyMapper(opts.filter)
+	if err != nil {
+		return err
+	}
+	src, err := openSource(ctx, opts, mapper)
+	if err != nil {
+		return err
+	}
+	defer src.close()
+
+	dst, err := openBucket(ctx, opts.dst)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	if _, err := newWritePolicy(opts.destination, opts.dst, dst); err != nil {
+		return err
+	}
+
+	out := opts.output()
+	fmt.Fprintf(out, "Planning the copy (dry run, nothing will be written) ...\n\n")
+
+	dstSource := &bucketSource{bucket: dst}
+	dstObjects := make(map[string]objectInfo)
+	err = dstSource.list(ctx, mapper.dstPrefix, func(obj objectInfo) error {
+		dstObjects[obj.Key] = obj
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "list destination")
+	}
+
+	plan := &copyPlan{}
+	mu := sync.Mutex{}
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(opts.concurrency)
+	err = src.list(gctx, "", func(obj objectInfo) error {
+		plan.objects += 1
+		plan.bytes += uint64(obj.Size)
+		plan.addLargest(obj)
+
+		dstKey := mapper.dstKey(obj.Key)
+		dstObj, ok := dstObjects[dstKey]
+		if !ok {
+			return nil
+		}
+		delete(dstObjects, dstKey)
+		plan.existing += 1
+		g.Go(func() error {
+			same, err := sameObject(gctx, src, obj, dstSource, dstObj)
+			if err != nil || !same {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			plan.identical += 1
+			plan.identicalBytes += uint64(obj.Size)
+			return nil
+		})
+		return nil
+	})
+	if waitErr := g.Wait(); err == nil {
+		err = waitErr
+	}
+	if err != nil {
+		return errors.Wrap(err, "compare source with destination")
+	}
+
+	if opts.mode == modeSync {
+		// with rename rules, only the objects copied by the previous runs
+		// are deleted
+		var copied map[string]string
+		if len(mapper.rename) > 0 {
+			path, err := opts.checkpointPath()
+			if err != nil {
+				return err
+			}
+			c, err := readCheckpoint(path)
+			if err != nil {
+				return fmt.Errorf("failed to read checkpoint manifest. Reason: %w", err)
+			}
+			copied = c.copiedKeys(mapper)
+		}
+		plan.stale = []string{}
+		for key := range dstObjects {
+			if _, ok := copied[key]; copied != nil && !ok {
+				continue
+			}
+			if mapper.mayOwn(key) {
+				plan.stale = append(plan.stale, key)
+			}
+		}
+		sort.Strings(plan.stale)
+	}
+	return plan.print(out, throughput)
+}
+
+// sameObject reports whether an object of the source has the same content as
+// an object of the destination, as the copy compares them.
+func sameObject(ctx context.Context, src objectSource, obj objectInfo, dst objectSource, dstObj objectInfo) (bool, error) {
+	var err error
+	// some drivers (i.e. fileblob) don't report checksums when listing
+	if len(obj.MD5) == 0 && obj.ETag == "" {
+		if obj, err = src.stat(ctx, obj.Key); err != nil {
+			return false, err
+		}
+	}
+	if len(dstObj.MD5) == 0 {
+		if dstObj, err = dst.stat(ctx, dstObj.Key); err != nil {
+			return false, err
+		}
+	}
+	if obj.Size == dstObj.Size && (len(obj.MD5) == 0 || len(dstObj.MD5) == 0) {
+		return sameHash(ctx, src, obj, dst, dstObj.Key, dstObj.MD5)
+	}
+	return obj.sameContent(dstObj.Size, dstObj.MD5, dstObj.ETag), nil
+}
+
+func (p *copyPlan) addLargest(obj objectInfo) {
+	if p.largest.Len() < largestObjects {
+		heap.Push(&p.largest, obj)
+	} else if p.largest[0].Size < obj.Size {
+		p.largest[0] = obj
+		heap.Fix(&p.largest, 0)
+	}
+}
+
+func (p *copyPlan) print(out io.Writer, throughput uint64) error {
+	transferBytes := p.bytes - p.identicalBytes
+	full := time.Duration(float64(p.bytes) / float64(throughput) * float64(time.Second))
+	incremental := time.Duration(float64(transferBytes) / float64(throughput) * float64(time.Second))
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Source objects:\t%d\n", p.objects)
+	fmt.Fprintf(w, "Total size:\t%s\n", humanize.IBytes(p.bytes))
+	fmt.Fprintf(w, "Already in destination:\t%d (%d identical, %s)\n", p.existing, p.identical, humanize.IBytes(p.identicalBytes))
+	fmt.Fprintf(w, "Estimated time:\t%s for a full copy, %s with --incremental (at %s/s)\n",
+		full.Round(time.Second), incremental.Round(time.Second), humanize.IBytes(throughput))
+	if p.stale != nil {
+		fmt.Fprintf(w, "Missing at source:\t%d (deleted from the destination with --delete)\n", len(p.stale))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(p.stale) > 0 {
+		fmt.Fprintln(out, "\nObjects to delete from the destination:")
+		for _, key := range p.stale {
+			fmt.Fprintf(out, "  %s\n", key)
+		}
+	}
+
+	if p.largest.Len() == 0 {
+		return nil
+	}
+	largest := append([]objectInfo(nil), p.largest...)
+	sort.Slice(largest, func(i, j int) bool {
+		return largest[i].Size > largest[j].Size
+	})
+	fmt.Fprintln(out, "\nLargest objects:")
+	w = tabwriter.NewWriter(out, 0, 0, 5, ' ', 0)
+	fmt.Fprintln(w, "SIZE\tKEY")
+	for _, obj := range largest {
+		fmt.Fprintf(w, "%s\t%s\n", humanize.IBytes(uint64(obj.Size)), obj.Key)
+	}
+	return w.Flush()
+}
+
+// objectHeap is a min-heap of objects ordered by size.
+type objectHeap []objectInfo
+
+func (h objectHeap) Len() int           { return len(h) }
+func (h objectHeap) Less(i, j int) bool { return h[i].Size < h[j].Size }
+func (h objectHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *objectHeap) Push(x any) {
+	*h = append(*h, x.(objectInfo))
+}
+
+func (h *objectHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
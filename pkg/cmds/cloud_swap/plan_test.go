@@ -0,0 +1,95 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPlanCopy(t *testing.T) {
+	src, dst := newTestBucket(t), newTestBucket(t)
+	src.write(t, map[string]string{
+		"same.txt":      "same",
+		"multipart.bin": "multipart",
+		"changed.txt":   "new",
+		"new.txt":       "new",
+	})
+	dst.write(t, map[string]string{
+		"same.txt":      "same",
+		"multipart.bin": "multipart",
+		"changed.txt":   "old",
+		"stale.txt":     "stale",
+	})
+	// multipart uploads don't expose the MD5 checksum of the object
+	if err := os.Remove(filepath.Join(src.dir, "multipart.bin.attrs")); err != nil {
+		t.Fatal(err)
+	}
+
+	out := &bytes.Buffer{}
+	opts := testSwapOptions(t, src, dst)
+	opts.mode = modeSync
+	opts.throughput = defaultThroughput
+	opts.out = out
+	if err := planCopy(context.Background(), opts); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"Source objects:          4\n",
+		"Already in destination:  3 (2 identical, 13 B)\n",
+		"Missing at source:       1 ",
+		"\n  stale.txt\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("plan doesn't contain %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestPlanCopyWithRename(t *testing.T) {
+	src, dst := newTestBucket(t), newTestBucket(t)
+	src.write(t, map[string]string{
+		"v1/a.txt": "a",
+		"v1/b.txt": "b",
+	})
+	dst.write(t, map[string]string{
+		"unrelated.txt": "x",
+	})
+	opts := testSwapOptions(t, src, dst)
+	opts.mode = modeSync
+	opts.throughput = defaultThroughput
+	opts.filter = filterOptions{rename: []string{"^v1/=v2/"}}
+	runTestCopy(t, opts)
+	src.delete(t, "v1/b.txt")
+
+	out := &bytes.Buffer{}
+	opts.out = out
+	if err := planCopy(context.Background(), opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), "Missing at source:       1 ") ||
+		!strings.Contains(out.String(), "\n  v2/b.txt\n") ||
+		strings.Contains(out.String(), "unrelated.txt") {
+		t.Errorf("plan must only report v2/b.txt as stale:\n%s", out.String())
+	}
+}
@@ -0,0 +1,232 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"golang.org/x/term"
+)
+
+const (
+	progressInterval = time.Second
+	// progressLogInterval is how often the status is printed when the
+	// output is not a terminal.
+	progressLogInterval = 10 * time.Second
+	// rateSamples is the number of samples the current rates are computed
+	// from, one per progressInterval.
+	rateSamples = 10
+)
+
+// progress tracks the objects and bytes of a copy and prints its status.
+// On a terminal, the status line is kept below the lines of the copied
+// objects. Otherwise, the status is printed periodically.
+type progress struct {
+	out      io.Writer
+	terminal bool
+
+	mu      sync.Mutex
+	started time.Time
+	logged  time.Time
+	listing bool
+	samples []progressSample
+	stopped chan struct{}
+	done    chan struct{}
+
+	listedObjects int64
+	listedBytes   int64
+	// finishedObjects counts the copied, skipped and failed objects
+	finishedObjects int64
+	copiedObjects   int64
+	// skippedBytes counts the bytes of the skipped and failed objects
+	skippedBytes int64
+	transferred  int64
+}
+
+type progressSample struct {
+	at          time.Time
+	objects     int64
+	transferred int64
+}
+
+func newProgress(out io.Writer) *progress {
+	f, ok := out.(*os.File)
+	return &progress{
+		out:      out,
+		terminal: ok && term.IsTerminal(int(f.Fd())),
+	}
+}
+
+// start resets the counters and starts printing the status.
+func (p *progress) start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	p.started, p.logged, p.listing = now, now, true
+	p.samples = []progressSample{{at: now}}
+	p.listedObjects, p.listedBytes = 0, 0
+	p.finishedObjects, p.copiedObjects, p.skippedBytes, p.transferred = 0, 0, 0, 0
+	p.stopped, p.done = make(chan struct{}), make(chan struct{})
+	go p.run(p.stopped, p.done)
+}
+
+func (p *progress) run(stopped <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			p.tick(now)
+		case <-stopped:
+			return
+		}
+	}
+}
+
+func (p *progress) tick(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.samples = append(p.samples, progressSample{at: now, objects: p.copiedObjects, transferred: p.transferred})
+	if len(p.samples) > rateSamples+1 {
+		p.samples = p.samples[1:]
+	}
+
+	if p.terminal {
+		p.draw()
+	} else if now.Sub(p.logged) >= progressLogInterval {
+		p.logged = now
+		fmt.Fprintln(p.out, p.status())
+	}
+}
+
+// stop stops printing the status and prints a summary of the copy.
+func (p *progress) stop() {
+	if p.stopped == nil {
+		return
+	}
+	close(p.stopped)
+	<-p.done
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopped = nil
+	if p.terminal {
+		fmt.Fprint(p.out, "\r\033[K")
+	}
+	if p.listedObjects == 0 {
+		return
+	}
+	elapsed := time.Since(p.started)
+	fmt.Fprintf(p.out, "\nTransferred %s of %d objects in %s (%s/s)\n",
+		humanize.IBytes(uint64(p.transferred)), p.finishedObjects, elapsed.Round(time.Second),
+		humanize.IBytes(uint64(float64(p.transferred)/elapsed.Seconds())))
+}
+
+// printf prints a line above the status line.
+func (p *progress) printf(format string, a ...any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.terminal && p.stopped != nil {
+		fmt.Fprint(p.out, "\r\033[K")
+		fmt.Fprintf(p.out, format, a...)
+		p.draw()
+		return
+	}
+	fmt.Fprintf(p.out, format, a...)
+}
+
+func (p *progress) draw() {
+	fmt.Fprint(p.out, "\r\033[K"+p.status())
+}
+
+// status describes the progress, as in
+//
+//	1,234/5,678 objects, 1.2 GiB/3.4 GiB (35%), 42.0 objects/s, 45 MiB/s, ETA 50s
+//
+// The totals are followed by a + until the listing of the source finishes.
+func (p *progress) status() string {
+	more := ""
+	if p.listing {
+		more = "+"
+	}
+	done := min(p.skippedBytes+p.transferred, p.listedBytes)
+	percent := 0
+	if p.listedBytes > 0 {
+		percent = int(done * 100 / p.listedBytes)
+	}
+	objectRate, byteRate := p.rates()
+	eta := "-"
+	if byteRate > 0 {
+		eta = time.Duration(float64(p.listedBytes-done)/byteRate*float64(time.Second)).Round(time.Second).String() + more
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s/%s%s objects, ", humanize.Comma(p.finishedObjects), humanize.Comma(p.listedObjects), more)
+	fmt.Fprintf(&b, "%s/%s%s (%d%%), ", humanize.IBytes(uint64(done)), humanize.IBytes(uint64(p.listedBytes)), more, percent)
+	fmt.Fprintf(&b, "%.1f objects/s, %s/s, ETA %s", objectRate, humanize.IBytes(uint64(byteRate)), eta)
+	return b.String()
+}
+
+// rates returns the current copy rates in objects and bytes per second.
+func (p *progress) rates() (float64, float64) {
+	if len(p.samples) < 2 {
+		return 0, 0
+	}
+	first, last := p.samples[0], p.samples[len(p.samples)-1]
+	elapsed := last.at.Sub(first.at).Seconds()
+	return float64(last.objects-first.objects) / elapsed, float64(last.transferred-first.transferred) / elapsed
+}
+
+func (p *progress) listed(size int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.listedObjects += 1
+	p.listedBytes += size
+}
+
+// listingDone marks the totals as final.
+func (p *progress) listingDone() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.listing = false
+}
+
+func (p *progress) transfer(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.transferred += int64(n)
+}
+
+// finished counts an object that is done with. The bytes of the objects
+// that weren't copied are counted as done without being transferred.
+func (p *progress) finished(size int64, copied bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.finishedObjects += 1
+	if copied {
+		p.copiedObjects += 1
+	} else {
+		p.skippedBytes += size
+	}
+}
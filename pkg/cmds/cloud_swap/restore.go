@@ -0,0 +1,364 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gocloud.dev/gcerrors"
+	"golang.org/x/sync/errgroup"
+)
+
+type restoreOptions struct {
+	from            string
+	dst             bucketOptions
+	partSize        string
+	concurrency     int
+	retries         int
+	resume          bool
+	allowIncomplete bool
+	decryption      decryptionOptions
+}
+
+func newCmdRestore() *cobra.Command {
+	opts := &restoreOptions{}
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Upload a local backup taken by cloud-swap to a bucket",
+		Example: `
+ace cloud-swap restore --from ~/cloud-swap-backup \
+    --dst-bucket-url="s3://<s3-compatible-bucket>?region=<us-east-1>&endpoint=<bucket-endpoint>"
+
+# Restore a backup encrypted to an age recipient
+ace cloud-swap restore --from ~/cloud-swap-backup --identity-file=<age-identity-path> \
+    --dst-bucket-url="s3://<s3-compatible-bucket>?region=<us-east-1>&endpoint=<bucket-endpoint>"
+`,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// the flags are valid at this point, so the usage does not help
+			cmd.SilenceUsage = true
+			return restoreBackup(cmd.Context(), opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.from, "from", localDefaultDir(), "Local backup directory")
+	addBucketFlags(cmd, "dst", &opts.dst)
+	cmd.MarkFlagsOneRequired("dst-bucket-url", "dst-endpoint")
+	cmd.Flags().StringVar(&opts.partSize, "part-size", defaultPartSize, "Size of the parts used to upload large objects (i.e. 16MiB)")
+	cmd.Flags().IntVar(&opts.concurrency, "concurrency", 4, "Number of objects uploaded in parallel")
+	cmd.Flags().IntVar(&opts.retries, "retries", 3, "Number of times a failed object upload is retried before giving up")
+	cmd.Flags().BoolVar(&opts.resume, "resume", false, "Resume an interrupted restore")
+	cmd.Flags().BoolVar(&opts.allowIncomplete, "allow-incomplete", false, "Restore a backup whose copy did not finish")
+	addDecryptionFlags(cmd.Flags(), &opts.decryption)
+	cmd.MarkFlagsMutuallyExclusive("identity-file", "passphrase-file")
+
+	return cmd
+}
+
+func restoreBackup(ctx context.Context, opts *restoreOptions) error {
+	dir, err := filepath.Abs(opts.from)
+	if err != nil {
+		return err
+	}
+	contents, err := readManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	s, err := newSwapper(ctx, &swapOptions{
+		src: bucketOptions{
+			side: "src",
+			url:  (&url.URL{Scheme: "file", Path: filepath.ToSlash(dir)}).String(),
+		},
+		dst:                opts.dst,
+		disableLocalBackup: true,
+		partSize:           opts.partSize,
+		concurrency:        opts.concurrency,
+		retries:            opts.retries,
+		resume:             opts.resume,
+		mode:               modeCopy,
+		metadata:           metadataOptions{mode: metadataPreserve},
+		restore:            true,
+	})
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	fmt.Printf("Checking the backup in %s ...\n", dir)
+	if contents.source != "" {
+		fmt.Printf("The backup was taken from %s\n", contents.source)
+	}
+	archives := &backupArchives{dir: dir, concurrency: s.concurrency}
+	if contents.encrypted() {
+		if archives.identities, err = opts.decryption.ageIdentities(); err != nil {
+			return err
+		}
+	}
+	objects, err := checkBackup(ctx, s.src, archives, contents)
+	if err != nil {
+		return err
+	}
+	if !contents.complete {
+		if !opts.allowIncomplete {
+			return fmt.Errorf("the copy that took the backup did not finish, so the backup may miss objects. Use --allow-incomplete to restore it anyway")
+		}
+		fmt.Println("WARNING: the copy that took the backup did not finish, so the backup may miss objects")
+	}
+	fmt.Printf("The backup has all the %d objects listed in its manifest\n", len(objects))
+
+	var files, archived []manifestRecord
+	for _, rec := range objects {
+		if rec.Archive == "" {
+			files = append(files, rec)
+		} else {
+			archived = append(archived, rec)
+		}
+	}
+
+	fmt.Printf("Uploading files to destination storage ...\n\n")
+	s.src = &manifestSource{objectSource: s.src, objects: files}
+	stats, err := s.copyAll(ctx)
+	if err == nil {
+		var archiveStats copyStats
+		archiveStats, err = s.restoreArchives(ctx, archives, archived, stats.copied+stats.skipped)
+		stats.copied += archiveStats.copied
+		stats.skipped += archiveStats.skipped
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("restore interrupted by user. Run again with --resume to continue from where it stopped")
+		}
+		return err
+	}
+
+	fmt.Println("\nRestore completed successfully!")
+	fmt.Printf("%d files uploaded, %d files skipped\n", stats.copied, stats.skipped)
+	return nil
+}
+
+// checkBackup makes sure that every object listed in the manifest is present
+// in the backup with the recorded size and checksum. It returns the latest
+// record of every object, sorted by key.
+func checkBackup(ctx context.Context, backup objectSource, archives *backupArchives, contents *backupContents) ([]manifestRecord, error) {
+	type problem struct {
+		key    string
+		reason string
+	}
+	var problems []problem
+
+	// the archives are read first, since the keys of the encrypted entries
+	// are only found in them
+	reasons, err := archives.check(ctx, contents.objects)
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[string]int)
+	for i, rec := range contents.objects {
+		if rec.Key == "" {
+			problems = append(problems, problem{fmt.Sprintf("%s#%d", rec.Archive, rec.Entry), reasons[i]})
+			continue
+		}
+		latest[rec.Key] = i
+	}
+	objects := make([]manifestRecord, 0, len(latest))
+	for key, i := range latest {
+		if reason, ok := reasons[i]; ok {
+			problems = append(problems, problem{key, reason})
+			continue
+		}
+		objects = append(objects, contents.objects[i])
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+
+	for _, rec := range objects {
+		if rec.Archive != "" {
+			continue
+		}
+		info, err := backup.stat(ctx, rec.Key)
+		if gcerrors.Code(err) == gcerrors.NotFound {
+			problems = append(problems, problem{rec.Key, "missing"})
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "read attributes of %s from backup", rec.Key)
+		}
+		if info.Size != rec.Size {
+			problems = append(problems, problem{rec.Key, fmt.Sprintf("size is %d, expected %d", info.Size, rec.Size)})
+			continue
+		}
+		if len(rec.MD5) == 0 {
+			continue
+		}
+		sum := info.MD5
+		if len(sum) == 0 {
+			if sum, err = hashObject(ctx, backup, rec.Key); err != nil {
+				return nil, errors.Wrapf(err, "hash %s in backup", rec.Key)
+			}
+		}
+		if !bytes.Equal(sum, rec.MD5) {
+			problems = append(problems, problem{rec.Key, "content differs"})
+		}
+	}
+	if len(problems) == 0 {
+		return objects, nil
+	}
+
+	sort.Slice(problems, func(i, j int) bool {
+		return problems[i].key < problems[j].key
+	})
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', 0)
+	fmt.Fprintln(w, "KEY\tPROBLEM")
+	for _, p := range problems {
+		fmt.Fprintf(w, "%s\t%s\n", p.key, p.reason)
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("the backup is damaged. Found %d missing or corrupted objects", len(problems))
+}
+
+// restoreArchives uploads the archived objects. The archive parts are read in
+// parallel, each one sequentially. The progress is numbered from offset.
+func (s *swapper) restoreArchives(ctx context.Context, archives *backupArchives, records []manifestRecord, offset int) (copyStats, error) {
+	var (
+		mu    sync.Mutex
+		stats copyStats
+	)
+	if len(records) == 0 {
+		return stats, nil
+	}
+	s.progress.start()
+	defer s.progress.stop()
+	for _, rec := range records {
+		s.progress.listed(rec.Size)
+	}
+	s.progress.listingDone()
+
+	report := func(rec manifestRecord, skipped bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		offset += 1
+		s.progress.finished(rec.Size, !skipped)
+		if skipped {
+			stats.skipped += 1
+			s.progress.printf("%6d. %s (skipped, already copied)\n", offset, rec.Key)
+			return
+		}
+		stats.copied += 1
+		s.progress.printf("%6d. %s\n", offset, rec.Key)
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(s.concurrency)
+	for name, recs := range groupArchives(records) {
+		g.Go(func() error {
+			return s.restoreArchive(ctx, archives, name, recs, report)
+		})
+	}
+	err := g.Wait()
+	return stats, err
+}
+
+// restoreArchive uploads the given entries of an archive part, sorted by
+// index. Since an entry can't be read twice, a failed upload is retried by
+// reading the archive again.
+func (s *swapper) restoreArchive(ctx context.Context, archives *backupArchives, name string, records []manifestRecord, report func(rec manifestRecord, skipped bool)) error {
+	var r *archiveReader
+	defer func() {
+		if r != nil {
+			_ = r.close()
+		}
+	}()
+
+	for _, rec := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, ok := s.checkpoint.lookup(rec.Key); ok && s.resume {
+			report(rec, true)
+			continue
+		}
+
+		err := s.withRetry(ctx, rec.Key, func(attempt int) error {
+			if attempt > 0 && r != nil {
+				_ = r.close()
+				r = nil
+			}
+			if r == nil {
+				var err error
+				if r, err = openArchive(archives.dir, name, archives.identities); err != nil {
+					return err
+				}
+			}
+			hdr, err := r.seek(rec.Entry)
+			if err != nil {
+				return err
+			}
+			meta, err := entryMeta(hdr)
+			if err != nil {
+				return err
+			}
+			obj := objectInfo{Key: rec.Key, Size: rec.Size, MD5: rec.MD5, Meta: meta}
+			return s.writeObject(ctx, s.mapper.dstKey(rec.Key), r, hdr.Size, s.metadata.writerOptions(obj))
+		})
+		if err != nil {
+			return err
+		}
+		if err := s.checkpoint.record(objectInfo{Key: rec.Key, Size: rec.Size, MD5: rec.MD5}); err != nil {
+			return errors.Wrapf(err, "record %s in checkpoint", rec.Key)
+		}
+		report(rec, false)
+	}
+	return nil
+}
+
+// manifestSource lists the objects recorded in the manifest of a backup
+// instead of the files of the backup directory, which may hold the objects
+// of unrelated copies.
+type manifestSource struct {
+	objectSource
+	objects []manifestRecord
+}
+
+func (s *manifestSource) list(ctx context.Context, prefix string, fn func(obj objectInfo) error) error {
+	for _, rec := range s.objects {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !strings.HasPrefix(rec.Key, prefix) {
+			continue
+		}
+		if err := fn(objectInfo{Key: rec.Key, Size: rec.Size, MD5: rec.MD5}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
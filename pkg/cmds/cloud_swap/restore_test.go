@@ -0,0 +1,189 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gocloud.dev/blob"
+)
+
+// takeTestBackup copies the objects to a bucket, keeping a local backup in
+// the dir format. It returns the backup directory.
+func takeTestBackup(t *testing.T, objects map[string]string) string {
+	t.Helper()
+	src, dst := newTestBucket(t), newTestBucket(t)
+	src.write(t, objects)
+	backup := t.TempDir()
+	opts := testSwapOptions(t, src, dst)
+	opts.disableLocalBackup = false
+	opts.localBackupDir = backup
+	opts.localBackupFormat = backupFormatDir
+	runTestCopy(t, opts)
+	return backup
+}
+
+func TestRestoreBackup(t *testing.T) {
+	objects := map[string]string{
+		"a.txt":     "a",
+		"dir/b.txt": "bb",
+	}
+	tests := []struct {
+		name            string
+		damage          func(t *testing.T, backup *blob.Bucket, dir string)
+		allowIncomplete bool
+		wantErr         string
+	}{
+		{
+			name: "complete",
+			damage: func(t *testing.T, backup *blob.Bucket, _ string) {
+				// files of other copies are not restored
+				if err := backup.WriteAll(context.Background(), "unrelated.txt", []byte("x"), nil); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name:    "incomplete",
+			damage:  removeCompleteRecord,
+			wantErr: "did not finish",
+		},
+		{
+			name:            "incomplete allowed",
+			damage:          removeCompleteRecord,
+			allowIncomplete: true,
+		},
+		{
+			name: "missing object",
+			damage: func(t *testing.T, backup *blob.Bucket, _ string) {
+				if err := backup.Delete(context.Background(), "a.txt"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: "Found 1 missing or corrupted objects",
+		},
+		{
+			name: "truncated object",
+			damage: func(t *testing.T, backup *blob.Bucket, _ string) {
+				if err := backup.WriteAll(context.Background(), "dir/b.txt", []byte("b"), nil); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: "Found 1 missing or corrupted objects",
+		},
+		{
+			name: "corrupted object",
+			damage: func(t *testing.T, backup *blob.Bucket, dir string) {
+				if err := backup.WriteAll(context.Background(), "dir/b.txt", []byte("xx"), nil); err != nil {
+					t.Fatal(err)
+				}
+				// the checksum is computed if the attributes are lost
+				if err := os.Remove(filepath.Join(dir, "dir", "b.txt.attrs")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: "Found 1 missing or corrupted objects",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := takeTestBackup(t, objects)
+			tt.damage(t, openTestBucket(t, dir).bucket, dir)
+
+			restored := newTestBucket(t)
+			opts := testRestoreOptions(t, dir, restored)
+			opts.allowIncomplete = tt.allowIncomplete
+			err := restoreBackup(context.Background(), opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				if got := restored.contents(t); len(got) != 0 {
+					t.Errorf("restored %v, want nothing", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := restored.contents(t); !reflect.DeepEqual(got, objects) {
+				t.Errorf("restored %v, want %v", got, objects)
+			}
+		})
+	}
+}
+
+// removeCompleteRecord makes the backup look like its copy was interrupted.
+func removeCompleteRecord(t *testing.T, _ *blob.Bucket, dir string) {
+	t.Helper()
+	path := filepath.Join(dir, backupManifestFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kept [][]byte
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if !bytes.Contains(line, []byte(`"kind":"complete"`)) {
+			kept = append(kept, line)
+		}
+	}
+	if err := os.WriteFile(path, bytes.Join(kept, []byte("\n")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := readManifest(dir); err == nil || !strings.Contains(err.Error(), "has no backup manifest") {
+		t.Errorf("error = %v, want a missing manifest", err)
+	}
+
+	manifest := strings.Join([]string{
+		`{"kind":"header","source":"s3://old"}`,
+		`{"kind":"object","key":"a.txt","size":1}`,
+		`{"kind":"complete","objects":1}`,
+		``,
+		`{"kind":"header","source":"s3://new"}`,
+		`{"kind":"object","archive":"cloud-swap-1-1.tar.zst.age","entry":2,"size":2}`,
+	}, "\n")
+	if err := os.WriteFile(filepath.Join(dir, backupManifestFile), []byte(manifest), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	contents, err := readManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contents.complete {
+		t.Error("backup is complete, want the interrupted last run to leave it incomplete")
+	}
+	if contents.source != "s3://new" || len(contents.objects) != 2 || !contents.encrypted() {
+		t.Errorf("contents = %+v, want the objects of both runs from s3://new, encrypted", contents)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, backupManifestFile), []byte("{\"kind\":\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readManifest(dir); err == nil || !strings.Contains(err.Error(), "failed to parse backup manifest") {
+		t.Errorf("error = %v, want a parse error", err)
+	}
+}
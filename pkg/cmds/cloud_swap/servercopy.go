@@ -0,0 +1,317 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"gocloud.dev/blob"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// maxCopyObjectSize is the largest object S3 copies in a single request
+	maxCopyObjectSize = 5 << 30
+	// minCopyPartSize keeps the number of requests of a multipart copy low,
+	// since the parts are not buffered locally
+	minCopyPartSize = 256 << 20
+	maxUploadParts  = 10000
+)
+
+// serverCopier copies objects within a provider, without reading them. The
+// copies are requested with the destination credentials, which need read
+// access to the source bucket.
+type serverCopier struct {
+	srcName   string
+	srcPrefix string
+	dstName   string
+	dstPrefix string
+	s3        *s3.S3
+	gcs       *storage.Client
+
+	partSize    int64
+	concurrency int
+	write       *writePolicy
+	// disabled is set once the provider refused a copy
+	disabled atomic.Bool
+}
+
+// newServerCopier returns a copier if both buckets are on the same S3
+// compatible endpoint or in GCS, or nil otherwise.
+func newServerCopier(srcOpts, dstOpts bucketOptions, src, dst *blob.Bucket) (*serverCopier, error) {
+	c := &serverCopier{}
+	var srcS3 *s3.S3
+	var srcGCS *storage.Client
+	switch {
+	case src.As(&srcS3) && dst.As(&c.s3):
+		if !sameS3Endpoint(srcS3.Endpoint, c.s3.Endpoint) {
+			return nil, nil
+		}
+	case src.As(&srcGCS) && dst.As(&c.gcs):
+	default:
+		return nil, nil
+	}
+
+	var err error
+	if c.srcName, c.srcPrefix, err = bucketLocation(srcOpts); err != nil {
+		return nil, err
+	}
+	if c.dstName, c.dstPrefix, err = bucketLocation(dstOpts); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// sameS3Endpoint reports whether objects can be copied between the two
+// endpoints. The regional AWS endpoints copy between each other.
+func sameS3Endpoint(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	if ua.Host == ub.Host {
+		return true
+	}
+	return strings.HasSuffix(ua.Hostname(), ".amazonaws.com") && strings.HasSuffix(ub.Hostname(), ".amazonaws.com")
+}
+
+// copyUnsupported reports whether a failed copy should be streamed instead,
+// because the provider or the credentials don't allow copying between the
+// buckets.
+func copyUnsupported(err error) bool {
+	status, ok := httpStatus(err)
+	return ok && (status == http.StatusBadRequest || status == http.StatusForbidden || status == http.StatusNotImplemented)
+}
+
+// copy copies a source object, or a version of it, to dstKey with the given
+// attributes.
+func (c *serverCopier) copy(ctx context.Context, srcKey, version string, size int64, dstKey string, wopts *blob.WriterOptions) error {
+	if c.gcs != nil {
+		return c.copyGCS(ctx, srcKey, version, dstKey, wopts)
+	}
+	if size > maxCopyObjectSize {
+		return c.copyS3Parts(ctx, srcKey, version, size, dstKey, wopts)
+	}
+
+	in := &s3.CopyObjectInput{
+		Bucket:             aws.String(c.dstName),
+		Key:                aws.String(c.dstPrefix + dstKey),
+		CopySource:         aws.String(c.copySource(srcKey, version)),
+		MetadataDirective:  aws.String(s3.MetadataDirectiveReplace),
+		ContentType:        optionalString(wopts.ContentType),
+		ContentEncoding:    optionalString(wopts.ContentEncoding),
+		CacheControl:       optionalString(wopts.CacheControl),
+		ContentDisposition: optionalString(wopts.ContentDisposition),
+		ContentLanguage:    optionalString(wopts.ContentLanguage),
+		Metadata:           aws.StringMap(wopts.Metadata),
+	}
+	if c.write != nil {
+		c.write.applyS3(&in.StorageClass, &in.ServerSideEncryption, &in.SSEKMSKeyId, &in.SSECustomerAlgorithm, &in.SSECustomerKey, &in.SSECustomerKeyMD5)
+	}
+	_, err := c.s3.CopyObjectWithContext(ctx, in)
+	return err
+}
+
+// copyS3Parts copies an object too large for a single request in parts.
+func (c *serverCopier) copyS3Parts(ctx context.Context, srcKey, version string, size int64, dstKey string, wopts *blob.WriterOptions) error {
+	key := aws.String(c.dstPrefix + dstKey)
+	in := &s3.CreateMultipartUploadInput{
+		Bucket:             aws.String(c.dstName),
+		Key:                key,
+		ContentType:        optionalString(wopts.ContentType),
+		ContentEncoding:    optionalString(wopts.ContentEncoding),
+		CacheControl:       optionalString(wopts.CacheControl),
+		ContentDisposition: optionalString(wopts.ContentDisposition),
+		ContentLanguage:    optionalString(wopts.ContentLanguage),
+		Metadata:           aws.StringMap(wopts.Metadata),
+	}
+	if c.write != nil {
+		c.write.applyS3(&in.StorageClass, &in.ServerSideEncryption, &in.SSEKMSKeyId, &in.SSECustomerAlgorithm, &in.SSECustomerKey, &in.SSECustomerKeyMD5)
+	}
+	upload, err := c.s3.CreateMultipartUploadWithContext(ctx, in)
+	if err != nil {
+		return err
+	}
+
+	partSize := max(c.partSize, minCopyPartSize, (size+maxUploadParts-1)/maxUploadParts)
+	var mu sync.Mutex
+	var parts []*s3.CompletedPart
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(c.concurrency, 1))
+	for n, offset := int64(1), int64(0); offset < size; n, offset = n+1, offset+partSize {
+		g.Go(func() error {
+			part := &s3.UploadPartCopyInput{
+				Bucket:          aws.String(c.dstName),
+				Key:             key,
+				UploadId:        upload.UploadId,
+				PartNumber:      aws.Int64(n),
+				CopySource:      aws.String(c.copySource(srcKey, version)),
+				CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", offset, min(offset+partSize, size)-1)),
+			}
+			if c.write != nil && c.write.customerKey != nil {
+				var ignored *string
+				c.write.applyS3(&ignored, &ignored, &ignored, &part.SSECustomerAlgorithm, &part.SSECustomerKey, &part.SSECustomerKeyMD5)
+			}
+			out, err := c.s3.UploadPartCopyWithContext(gctx, part)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			parts = append(parts, &s3.CompletedPart{ETag: out.CopyPartResult.ETag, PartNumber: aws.Int64(n)})
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		c.abort(upload)
+		return err
+	}
+
+	sort.Slice(parts, func(i, j int) bool { return *parts[i].PartNumber < *parts[j].PartNumber })
+	_, err = c.s3.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(c.dstName),
+		Key:             key,
+		UploadId:        upload.UploadId,
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		c.abort(upload)
+	}
+	return err
+}
+
+// abort removes the parts of a failed copy. It is done in the background
+// context, since the copy context may be the reason of the failure.
+func (c *serverCopier) abort(upload *s3.CreateMultipartUploadOutput) {
+	_, _ = c.s3.AbortMultipartUploadWithContext(context.Background(), &s3.AbortMultipartUploadInput{
+		Bucket:   upload.Bucket,
+		Key:      upload.Key,
+		UploadId: upload.UploadId,
+	})
+}
+
+// copySource returns the URL encoded source of an S3 copy.
+func (c *serverCopier) copySource(key, version string) string {
+	source := c.srcName + "/" + strings.ReplaceAll(url.PathEscape(c.srcPrefix+key), "%2F", "/")
+	if version != "" {
+		source += "?versionId=" + url.QueryEscape(version)
+	}
+	return source
+}
+
+// copyGCS copies an object with the rewrite API, which may take several
+// requests for large objects.
+func (c *serverCopier) copyGCS(ctx context.Context, srcKey, version, dstKey string, wopts *blob.WriterOptions) error {
+	src := c.gcs.Bucket(c.srcName).Object(c.srcPrefix + srcKey)
+	if version != "" {
+		gen, err := strconv.ParseInt(version, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid generation %q. Reason: %w", version, err)
+		}
+		src = src.Generation(gen)
+	}
+	dst := c.gcs.Bucket(c.dstName).Object(c.dstPrefix + dstKey)
+	if c.write != nil && c.write.customerKey != nil {
+		dst = dst.Key(c.write.customerKey)
+	}
+
+	copier := dst.CopierFrom(src)
+	copier.ContentType = wopts.ContentType
+	copier.ContentEncoding = wopts.ContentEncoding
+	copier.CacheControl = wopts.CacheControl
+	copier.ContentDisposition = wopts.ContentDisposition
+	copier.ContentLanguage = wopts.ContentLanguage
+	copier.Metadata = wopts.Metadata
+	if c.write != nil {
+		c.write.applyGCS(&copier.ObjectAttrs)
+		// the key of a rewrite is given apart from the object attributes
+		copier.DestinationKMSKeyName, copier.KMSKeyName = copier.KMSKeyName, ""
+	}
+	_, err := copier.Run(ctx)
+	return err
+}
+
+// versionMeta returns the attributes of a source version for the providers
+// that don't list them.
+func (c *serverCopier) versionMeta(ctx context.Context, key, version string) (*objectMeta, error) {
+	if c.s3 == nil {
+		return nil, nil
+	}
+	out, err := c.s3.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket:    aws.String(c.srcName),
+		Key:       aws.String(c.srcPrefix + key),
+		VersionId: aws.String(version),
+	})
+	if err != nil {
+		return nil, err
+	}
+	meta := &objectMeta{
+		ContentType:        aws.StringValue(out.ContentType),
+		ContentEncoding:    aws.StringValue(out.ContentEncoding),
+		CacheControl:       aws.StringValue(out.CacheControl),
+		ContentDisposition: aws.StringValue(out.ContentDisposition),
+		ContentLanguage:    aws.StringValue(out.ContentLanguage),
+		Metadata:           aws.StringValueMap(out.Metadata),
+	}
+	return meta, nil
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+// serverSideCopy copies an object within the provider if possible. It
+// returns false if the object has to be streamed instead.
+func (s *swapper) serverSideCopy(ctx context.Context, srcKey, version string, size int64, wopts *blob.WriterOptions) (bool, error) {
+	if s.serverCopy == nil || s.serverCopy.disabled.Load() {
+		return false, nil
+	}
+	start := time.Now()
+	err := s.serverCopy.copy(ctx, srcKey, version, size, s.mapper.dstKey(srcKey), wopts)
+	s.metrics.observe(sideDestination, "copy", start)
+	if err == nil {
+		s.progress.transfer(int(size))
+		s.metrics.transfer(int(size))
+		return true, nil
+	}
+	if !copyUnsupported(err) {
+		return true, withOp(opWriteDestination, fmt.Errorf("failed to copy file within the provider. Reason: %w", err))
+	}
+	if s.serverCopy.disabled.CompareAndSwap(false, true) {
+		s.progress.printf("WARNING: server-side copy is not possible, streaming the files instead. Reason: %v\n", err)
+	}
+	return false, nil
+}
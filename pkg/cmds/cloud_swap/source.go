@@ -0,0 +1,206 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+)
+
+// objectInfo describes an object of the source storage.
+type objectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+	MD5     []byte
+	ETag    string
+	// Meta is only set by stat, since listing doesn't return the attributes
+	Meta *objectMeta
+	// Versions are listed oldest first with --all-versions. Size is the total
+	// of the versions then.
+	Versions []objectVersion
+}
+
+// sameContent reports whether an object with the given size and checksums has
+// the same content as o. Objects without a comparable checksum never match.
+func (o objectInfo) sameContent(size int64, md5 []byte, etag string) bool {
+	if o.Size != size {
+		return false
+	}
+	if len(o.MD5) > 0 && len(md5) > 0 {
+		return bytes.Equal(o.MD5, md5)
+	}
+	etag = normalizeETag(etag)
+	return etag != "" && normalizeETag(o.ETag) == etag
+}
+
+func normalizeETag(etag string) string {
+	return strings.TrimPrefix(strings.Trim(etag, `"`), "W/")
+}
+
+// objectSource is a storage the objects are copied from.
+type objectSource interface {
+	// list calls fn for every object of the source storage under prefix
+	list(ctx context.Context, prefix string, fn func(obj objectInfo) error) error
+	// stat returns the details of a single object
+	stat(ctx context.Context, key string) (objectInfo, error)
+	// open returns a streaming reader of the object content
+	open(ctx context.Context, key string) (io.ReadCloser, error)
+	close() error
+}
+
+// bucketSource reads objects from any bucket supported by gocloud.dev/blob.
+type bucketSource struct {
+	bucket *blob.Bucket
+}
+
+func (s *bucketSource) list(ctx context.Context, prefix string, fn func(obj objectInfo) error) error {
+	iter := s.bucket.List(&blob.ListOptions{Prefix: prefix})
+	for {
+		obj, err := iter.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = fn(objectInfo{
+			Key:     obj.Key,
+			Size:    obj.Size,
+			ModTime: obj.ModTime,
+			MD5:     obj.MD5,
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *bucketSource) stat(ctx context.Context, key string) (objectInfo, error) {
+	attrs, err := s.bucket.Attributes(ctx, key)
+	if err != nil {
+		return objectInfo{}, err
+	}
+	return objectInfo{
+		Key:     key,
+		Size:    attrs.Size,
+		ModTime: attrs.ModTime,
+		MD5:     attrs.MD5,
+		ETag:    attrs.ETag,
+		Meta: &objectMeta{
+			ContentType:        attrs.ContentType,
+			ContentEncoding:    attrs.ContentEncoding,
+			CacheControl:       attrs.CacheControl,
+			ContentDisposition: attrs.ContentDisposition,
+			ContentLanguage:    attrs.ContentLanguage,
+			Metadata:           attrs.Metadata,
+		},
+	}, nil
+}
+
+func (s *bucketSource) open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return newRawReader(ctx, s.bucket, key, nil)
+}
+
+// newRawReader returns a reader of the bytes of an object as stored. Otherwise,
+// the objects with a content encoding (i.e. gzip) are decompressed on the fly
+// by GCS and by the HTTP transport used for S3, and their copies would not
+// match the content encoding they are written with. beforeRead, if set, is
+// called before the request is sent.
+func newRawReader(ctx context.Context, bucket *blob.Bucket, key string, beforeRead func(as func(any) bool) error) (*blob.Reader, error) {
+	r, err := bucket.NewReader(ctx, key, &blob.ReaderOptions{
+		BeforeRead: func(as func(any) bool) error {
+			if beforeRead != nil {
+				if err := beforeRead(as); err != nil {
+					return err
+				}
+			}
+			return readRaw(as)
+		},
+	})
+	var aerr awserr.Error
+	if err != nil && bucket.ErrorAs(err, &aerr) && aerr.Code() == "InvalidRange" {
+		// empty objects can't be read by range, and have nothing to decompress
+		return bucket.NewReader(ctx, key, &blob.ReaderOptions{BeforeRead: beforeRead})
+	}
+	return r, err
+}
+
+func readRaw(as func(any) bool) error {
+	var in *s3.GetObjectInput
+	if as(&in) {
+		// Go's HTTP transport asks for a compressed response and decompresses
+		// it unless the request is for a range
+		if in.Range == nil {
+			in.Range = aws.String("bytes=0-")
+		}
+		return nil
+	}
+	var oh **storage.ObjectHandle
+	if as(&oh) {
+		*oh = (*oh).ReadCompressed(true)
+	}
+	return nil
+}
+
+func (s *bucketSource) close() error {
+	return s.bucket.Close()
+}
+
+// keyListSource lists only the given keys of a source. Their attributes are
+// read one by one, instead of listing the whole source.
+type keyListSource struct {
+	objectSource
+	// keys are sorted
+	keys []string
+	out  io.Writer
+}
+
+func (s *keyListSource) list(ctx context.Context, prefix string, fn func(obj objectInfo) error) error {
+	for _, key := range s.keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		info, err := s.stat(ctx, key)
+		if gcerrors.Code(err) == gcerrors.NotFound {
+			fmt.Fprintf(s.out, "WARNING: %s is not found at the source, skipping\n", key)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read attributes of %s from source. Reason: %w", key, err)
+		}
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
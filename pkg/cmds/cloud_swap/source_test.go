@@ -0,0 +1,159 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"gocloud.dev/blob"
+	"gocloud.dev/blob/gcsblob"
+	"gocloud.dev/blob/s3blob"
+	"gocloud.dev/gcp"
+)
+
+// encodedObjectServer serves the objects stored with Content-Encoding: gzip.
+// Like the providers, it returns the stored bytes to the clients accepting
+// gzip, or to any client reading a range for S3, and decompresses them for the
+// other clients.
+func encodedObjectServer(t *testing.T, objects map[string][]byte, rangeIsRaw bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var data []byte
+		found := false
+		for key, val := range objects {
+			if strings.HasSuffix(r.URL.Path, "/"+key) {
+				data, found = val, true
+			}
+		}
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+
+		rangeReq := r.Header.Get("Range")
+		if rangeReq != "" && len(data) == 0 {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			_, _ = io.WriteString(w, `<Error><Code>InvalidRange</Code><Message>The requested range is not satisfiable</Message></Error>`)
+			return
+		}
+		raw := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") || rangeIsRaw && rangeReq != ""
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		if !raw && len(data) > 0 {
+			zr, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if data, err = io.ReadAll(zr); err != nil {
+				t.Error(err)
+				return
+			}
+		} else if len(data) > 0 {
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		status := http.StatusOK
+		if rangeReq != "" {
+			w.Header().Set("Content-Range", "bytes 0-"+strconv.Itoa(len(data)-1)+"/"+strconv.Itoa(len(data)))
+			status = http.StatusPartialContent
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write(data)
+	}))
+}
+
+func gzipped(t *testing.T, s string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(zw, s); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestOpenReadsStoredBytes(t *testing.T) {
+	objects := map[string][]byte{
+		"report.json.gz": gzipped(t, strings.Repeat(`{"hello":"world"}`, 100)),
+		"empty":          {},
+	}
+
+	openers := map[string]func(t *testing.T, srv *httptest.Server) *blob.Bucket{
+		"s3": func(t *testing.T, srv *httptest.Server) *blob.Bucket {
+			sess, err := session.NewSession(&aws.Config{
+				Endpoint:         aws.String(srv.URL),
+				Region:           aws.String("us-east-1"),
+				S3ForcePathStyle: aws.Bool(true),
+				Credentials:      credentials.NewStaticCredentials("id", "secret", ""),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := s3blob.OpenBucket(context.Background(), sess, "bucket", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return b
+		},
+		"gs": func(t *testing.T, srv *httptest.Server) *blob.Bucket {
+			t.Setenv("STORAGE_EMULATOR_HOST", srv.Listener.Addr().String())
+			b, err := gcsblob.OpenBucket(context.Background(), &gcp.HTTPClient{Client: *srv.Client()}, "bucket", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return b
+		},
+	}
+
+	for provider, openBucket := range openers {
+		for key, want := range objects {
+			t.Run(provider+"/"+key, func(t *testing.T) {
+				srv := encodedObjectServer(t, objects, provider == "s3")
+				defer srv.Close()
+				src := &bucketSource{bucket: openBucket(t, srv)}
+				defer src.close()
+
+				r, err := src.open(context.Background(), key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer r.Close()
+				got, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("read %d bytes, want the %d bytes stored", len(got), len(want))
+				}
+			})
+		}
+	}
+}
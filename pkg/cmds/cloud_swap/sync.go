@@ -0,0 +1,187 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gocloud.dev/gcerrors"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	modeCopy = "copy"
+	modeSync = "sync"
+)
+
+// keySet records the destination keys of the source objects seen while
+// copying in sync mode.
+type keySet struct {
+	mu   sync.Mutex
+	keys map[string]struct{}
+}
+
+func newKeySet() *keySet {
+	return &keySet{keys: make(map[string]struct{})}
+}
+
+func (s *keySet) add(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key] = struct{}{}
+}
+
+func (s *keySet) has(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.keys[key]
+	return ok
+}
+
+// mayOwn reports whether a destination object is part of the mirror, so that
+// it is removed when its source is gone. Objects outside the destination
+// prefix are never touched. Without rename rules, the include and exclude
+// patterns apply to the destination keys too, so excluded objects are kept.
+// With rename rules, any object under the prefix may be part of it, and the
+// checkpoint tells which ones are.
+func (m *keyMapper) mayOwn(dstKey string) bool {
+	if !strings.HasPrefix(dstKey, m.dstPrefix) {
+		return false
+	}
+	if len(m.rename) > 0 {
+		return true
+	}
+	return m.selects(m.srcPrefix + strings.TrimPrefix(dstKey, m.dstPrefix))
+}
+
+// copiedKeys maps the destination keys of the objects recorded in the
+// checkpoint to their source keys.
+func (c *checkpoint) copiedKeys(m *keyMapper) map[string]string {
+	copied := map[string]string{}
+	for _, key := range c.keys() {
+		copied[m.dstKey(key)] = key
+	}
+	return copied
+}
+
+// findStale lists the destination objects of the mirror that don't exist at
+// the source anymore. With rename rules, the keys of the destination can't be
+// matched against the filters, so only the objects copied by the previous
+// runs are listed, and the objects written by others are kept.
+func (s *swapper) findStale(ctx context.Context) ([]string, error) {
+	var copied map[string]string
+	if len(s.mapper.rename) > 0 {
+		copied = s.checkpoint.copiedKeys(s.mapper)
+	}
+	var stale []string
+	err := (&bucketSource{bucket: s.dst}).list(ctx, s.mapper.dstPrefix, func(obj objectInfo) error {
+		if !s.mapper.mayOwn(obj.Key) || s.seen.has(obj.Key) {
+			return nil
+		}
+		if _, ok := copied[obj.Key]; copied != nil && !ok {
+			return nil
+		}
+		stale = append(stale, obj.Key)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "list destination")
+	}
+	sort.Strings(stale)
+	return stale, nil
+}
+
+// deleteStale removes the stale objects from the destination after the user
+// confirms it. Without --delete, they are only reported.
+func (s *swapper) deleteStale(ctx context.Context, opts *swapOptions) error {
+	stale, err := s.findStale(ctx)
+	if err != nil {
+		return err
+	}
+	if len(stale) == 0 {
+		fmt.Fprintln(s.out, "Destination has no object that is missing at the source")
+		return nil
+	}
+
+	fmt.Fprintf(s.out, "\n%d objects at the destination don't exist at the source:\n", len(stale))
+	for _, key := range stale {
+		fmt.Fprintf(s.out, "  %s\n", key)
+	}
+	if !opts.delete {
+		fmt.Fprintln(s.out, "Run again with --delete to remove them.")
+		return nil
+	}
+	if !opts.yes {
+		ok, err := confirm(fmt.Sprintf("Delete %d objects from the destination?", len(stale)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Fprintln(s.out, "Deletion skipped.")
+			return nil
+		}
+	}
+
+	// the deleted objects are forgotten, so that an object written later
+	// under the same key is not taken for a copy
+	copied := s.checkpoint.copiedKeys(s.mapper)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(s.concurrency)
+	for _, key := range stale {
+		g.Go(func() error {
+			err := s.dst.Delete(gctx, key)
+			if err != nil && gcerrors.Code(err) != gcerrors.NotFound {
+				return errors.Wrapf(err, "delete %s from destination", key)
+			}
+			if src, ok := copied[key]; ok {
+				if err := s.checkpoint.forget(src); err != nil {
+					return errors.Wrapf(err, "record deletion of %s in checkpoint", key)
+				}
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "%d objects deleted from the destination\n", len(stale))
+	return nil
+}
+
+// confirm asks the user a yes/no question on the terminal. It fails when the
+// standard input is not a terminal, so that nothing is deleted unattended.
+func confirm(question string) (bool, error) {
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return false, fmt.Errorf("confirmation required, but standard input is not a terminal. Use --yes to confirm non-interactively")
+	}
+
+	fmt.Printf("%s [y/N]: ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
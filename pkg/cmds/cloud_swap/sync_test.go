@@ -0,0 +1,150 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSyncDeletesStaleObjects(t *testing.T) {
+	src, dst := newTestBucket(t), newTestBucket(t)
+	src.write(t, map[string]string{
+		"a.txt":     "a",
+		"b.txt":     "b",
+		"tmp/c.txt": "c",
+	})
+	dst.write(t, map[string]string{
+		"unrelated.txt":    "x",
+		"mirror/tmp/d.txt": "excluded",
+	})
+	opts := testSwapOptions(t, src, dst)
+	opts.mode = modeSync
+	opts.delete = true
+	opts.yes = true
+	opts.filter = filterOptions{dstPrefix: "mirror/", exclude: []string{"tmp/**"}}
+
+	runTestCopy(t, opts)
+	src.delete(t, "b.txt")
+	runTestCopy(t, opts)
+
+	want := map[string]string{
+		"unrelated.txt":    "x",
+		"mirror/tmp/d.txt": "excluded",
+		"mirror/a.txt":     "a",
+	}
+	if got := dst.contents(t); !reflect.DeepEqual(got, want) {
+		t.Errorf("destination = %v, want %v", got, want)
+	}
+}
+
+func TestSyncWithRenameDeletesOnlyCopiedObjects(t *testing.T) {
+	src, dst := newTestBucket(t), newTestBucket(t)
+	src.write(t, map[string]string{
+		"v1/a.txt": "a",
+		"v1/b.txt": "b",
+	})
+	dst.write(t, map[string]string{
+		"unrelated.txt": "x",
+		"v2/other.txt":  "y",
+	})
+	opts := testSwapOptions(t, src, dst)
+	opts.mode = modeSync
+	opts.delete = true
+	opts.yes = true
+	opts.filter = filterOptions{rename: []string{"^v1/=v2/"}}
+
+	runTestCopy(t, opts)
+	src.delete(t, "v1/b.txt")
+	runTestCopy(t, opts)
+
+	want := map[string]string{
+		"unrelated.txt": "x",
+		"v2/other.txt":  "y",
+		"v2/a.txt":      "a",
+	}
+	if got := dst.contents(t); !reflect.DeepEqual(got, want) {
+		t.Errorf("destination = %v, want %v", got, want)
+	}
+
+	// an object written under the key of a deleted copy is not taken for one
+	dst.write(t, map[string]string{"v2/b.txt": "z"})
+	runTestCopy(t, opts)
+	want["v2/b.txt"] = "z"
+	if got := dst.contents(t); !reflect.DeepEqual(got, want) {
+		t.Errorf("destination = %v, want %v", got, want)
+	}
+}
+
+func TestSyncWithoutDeleteKeepsStaleObjects(t *testing.T) {
+	src, dst := newTestBucket(t), newTestBucket(t)
+	src.write(t, map[string]string{"a.txt": "a"})
+	dst.write(t, map[string]string{"stale.txt": "s"})
+	opts := testSwapOptions(t, src, dst)
+	opts.mode = modeSync
+
+	runTestCopy(t, opts)
+	want := map[string]string{"a.txt": "a", "stale.txt": "s"}
+	if got := dst.contents(t); !reflect.DeepEqual(got, want) {
+		t.Errorf("destination = %v, want %v", got, want)
+	}
+}
+
+// TestSyncTrustsCheckpoint checks that repeated syncs don't read the objects
+// whose checksums can't be compared, unless they have changed.
+func TestSyncTrustsCheckpoint(t *testing.T) {
+	src, dst := newTestBucket(t), newTestBucket(t)
+	src.write(t, map[string]string{"a.txt": "a", "b.txt": "b"})
+	// the source has no MD5 for the objects it has not written itself, as
+	// for multipart uploads
+	for _, key := range []string{"a.txt", "b.txt"} {
+		if err := os.Remove(filepath.Join(src.dir, key+".attrs")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := testSwapOptions(t, src, dst)
+	opts.mode = modeSync
+
+	if stats, read := runTestCopy(t, opts); stats.copied != 2 || read.opened.Load() != 2 {
+		t.Fatalf("first sync copied %d objects and read %d, want 2 and 2", stats.copied, read.opened.Load())
+	}
+	if stats, read := runTestCopy(t, opts); stats.skipped != 2 || read.opened.Load() != 0 {
+		t.Fatalf("second sync skipped %d objects and read %d, want 2 and 0", stats.skipped, read.opened.Load())
+	}
+
+	// an object overwritten at the destination is hashed and copied again
+	dst.write(t, map[string]string{"b.txt": "x"})
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(dst.dir, "b.txt"), future, future); err != nil {
+		t.Fatal(err)
+	}
+	stats, _ := runTestCopy(t, opts)
+	if stats.copied != 1 || stats.skipped != 1 {
+		t.Errorf("third sync copied %d objects and skipped %d, want 1 and 1", stats.copied, stats.skipped)
+	}
+	data, err := dst.bucket.ReadAll(context.Background(), "b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "b" {
+		t.Errorf("b.txt = %q, want %q", data, "b")
+	}
+}
@@ -0,0 +1,308 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	verifyStatusMissing    = "Missing"
+	verifyStatusExtra      = "Extra"
+	verifyStatusMismatched = "Mismatched"
+)
+
+// verifyReport lists the differences found between the source and the
+// destination.
+type verifyReport struct {
+	Checked    int              `json:"checked"`
+	Rehashed   int              `json:"rehashed"`
+	Missing    []string         `json:"missing"`
+	Extra      []string         `json:"extra"`
+	Mismatched []verifyMismatch `json:"mismatched"`
+}
+
+type verifyMismatch struct {
+	Key     string `json:"key"`
+	Reason  string `json:"reason"`
+	SrcSize int64  `json:"srcSize"`
+	DstSize int64  `json:"dstSize"`
+	SrcMD5  string `json:"srcMD5,omitempty"`
+	DstMD5  string `json:"dstMD5,omitempty"`
+}
+
+func (r *verifyReport) discrepancies() int {
+	return len(r.Missing) + len(r.Extra) + len(r.Mismatched)
+}
+
+// verifier compares the objects of the source and the destination by key,
+// size and MD5 checksum. Objects whose checksum is not exposed by the
+// provider are downloaded and hashed.
+type verifier struct {
+	src         objectSource
+	dst         objectSource
+	mapper      *keyMapper
+	concurrency int
+	// keysOnly is set when the source is restricted to a list of keys, so
+	// that the other objects of the destination are not extra
+	keysOnly bool
+	// out receives the report and log the progress messages
+	out io.Writer
+	log io.Writer
+}
+
+func newCmdVerify() *cobra.Command {
+	opts := &swapOptions{}
+	var output string
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify that the destination storage matches the source",
+		Example: `
+ace cloud-swap verify --src-bucket-url="gs://<google-bucket-name>" \
+    --dst-bucket-url="s3://<s3-compatible-bucket>?region=<us-east-1>&endpoint=<bucket-endpoint>" -o json
+`,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// the flags are valid at this point, so the usage does not help
+			cmd.SilenceUsage = true
+			return verifyBuckets(cmd.Context(), opts, output)
+		},
+	}
+
+	addBucketFlags(cmd, "src", &opts.src)
+	addBucketFlags(cmd, "dst", &opts.dst)
+	addS3ProxyFlags(cmd, &opts.src)
+	addFilterFlags(cmd.Flags(), &opts.filter)
+	cmd.Flags().IntVar(&opts.concurrency, "concurrency", 4, "Number of objects hashed in parallel when the provider does not expose MD5 checksums")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format of the report (any of table,json)")
+	cmd.MarkFlagsOneRequired("src-bucket-url", "src-endpoint", "s3proxy.endpoint")
+	cmd.MarkFlagsOneRequired("dst-bucket-url", "dst-endpoint")
+
+	return cmd
+}
+
+func verifyBuckets(ctx context.Context, opts *swapOptions, output string) error {
+	mapper, err := newKeyMapper(opts.filter)
+	if err != nil {
+		return err
+	}
+	src, err := openSource(ctx, opts, mapper)
+	if err != nil {
+		return err
+	}
+	defer src.close()
+
+	dst, err := openBucket(ctx, opts.dst)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	v := &verifier{
+		src:         src,
+		dst:         &bucketSource{bucket: dst},
+		mapper:      mapper,
+		concurrency: max(opts.concurrency, 1),
+		out:         os.Stdout,
+		log:         os.Stderr,
+	}
+	_, err = v.verify(ctx, output)
+	return err
+}
+
+// verify compares both sides and prints the report. It fails if there is
+// any discrepancy.
+func (v *verifier) verify(ctx context.Context, output string) (*verifyReport, error) {
+	fmt.Fprintln(v.log, "Verifying destination storage ...")
+	report, err := v.run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := printVerifyReport(v.out, report, output); err != nil {
+		return report, err
+	}
+	if n := report.discrepancies(); n > 0 {
+		return report, fmt.Errorf("verification failed. Found %d discrepancies", n)
+	}
+	return report, nil
+}
+
+// run compares the selected objects of the source with the objects under the
+// destination prefix the filters select. Source keys are reported for missing and mismatched
+// objects, destination keys for the extra ones.
+func (v *verifier) run(ctx context.Context) (*verifyReport, error) {
+	dstObjects := make(map[string]objectInfo)
+	err := v.dst.list(ctx, v.mapper.dstPrefix, func(obj objectInfo) error {
+		dstObjects[obj.Key] = obj
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "list destination")
+	}
+
+	report := &verifyReport{
+		Missing:    []string{},
+		Extra:      []string{},
+		Mismatched: []verifyMismatch{},
+	}
+	mu := sync.Mutex{}
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(v.concurrency)
+
+	err = v.src.list(gctx, "", func(srcObj objectInfo) error {
+		report.Checked += 1
+		dstKey := v.mapper.dstKey(srcObj.Key)
+		dstObj, ok := dstObjects[dstKey]
+		if !ok {
+			report.Missing = append(report.Missing, srcObj.Key)
+			return nil
+		}
+		delete(dstObjects, dstKey)
+
+		g.Go(func() error {
+			m, rehashed, err := v.compare(gctx, srcObj, dstObj)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			report.Rehashed += rehashed
+			if m != nil {
+				report.Mismatched = append(report.Mismatched, *m)
+			}
+			return nil
+		})
+		return nil
+	})
+	if waitErr := g.Wait(); err == nil {
+		err = waitErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for key := range dstObjects {
+		// the objects left out by the filters are not part of the copy
+		if !v.keysOnly && v.mapper.mayOwn(key) {
+			report.Extra = append(report.Extra, key)
+		}
+	}
+	sort.Strings(report.Missing)
+	sort.Strings(report.Extra)
+	sort.Slice(report.Mismatched, func(i, j int) bool {
+		return report.Mismatched[i].Key < report.Mismatched[j].Key
+	})
+	return report, nil
+}
+
+// compare checks an object of the source with its copy. It returns the number
+// of objects that had to be downloaded to compute their checksum.
+func (v *verifier) compare(ctx context.Context, srcObj, dstObj objectInfo) (*verifyMismatch, int, error) {
+	m := &verifyMismatch{
+		Key:     srcObj.Key,
+		SrcSize: srcObj.Size,
+		DstSize: dstObj.Size,
+	}
+	if srcObj.Size != dstObj.Size {
+		m.Reason = "size differs"
+		return m, 0, nil
+	}
+
+	rehashed := 0
+	srcMD5, dstMD5 := srcObj.MD5, dstObj.MD5
+	var err error
+	if len(srcMD5) == 0 {
+		if srcMD5, err = hashObject(ctx, v.src, srcObj.Key); err != nil {
+			return nil, rehashed, errors.Wrapf(err, "hash %s in source", srcObj.Key)
+		}
+		rehashed += 1
+	}
+	if len(dstMD5) == 0 {
+		if dstMD5, err = hashObject(ctx, v.dst, dstObj.Key); err != nil {
+			return nil, rehashed, errors.Wrapf(err, "hash %s in destination", dstObj.Key)
+		}
+		rehashed += 1
+	}
+	if !bytes.Equal(srcMD5, dstMD5) {
+		m.Reason = "content differs"
+		m.SrcMD5 = hex.EncodeToString(srcMD5)
+		m.DstMD5 = hex.EncodeToString(dstMD5)
+		return m, rehashed, nil
+	}
+	return nil, rehashed, nil
+}
+
+// hashObject computes the MD5 checksum of an object by reading it fully.
+func hashObject(ctx context.Context, src objectSource, key string) ([]byte, error) {
+	r, err := src.open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+func printVerifyReport(out io.Writer, report *verifyReport, output string) error {
+	if output == "json" {
+		data, err := json.MarshalIndent(report, "", " ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(data))
+		return nil
+	}
+
+	fmt.Fprintf(out, "\nChecked %d objects, %d of them re-hashed. Found %d discrepancies.\n", report.Checked, report.Rehashed, report.discrepancies())
+	if report.discrepancies() == 0 {
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 5, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tKEY\tDETAILS")
+	for _, key := range report.Missing {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", verifyStatusMissing, key, "not found in destination")
+	}
+	for _, key := range report.Extra {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", verifyStatusExtra, key, "not found in source")
+	}
+	for _, m := range report.Mismatched {
+		details := fmt.Sprintf("%s (size %d/%d", m.Reason, m.SrcSize, m.DstSize)
+		if m.SrcMD5 != "" {
+			details += fmt.Sprintf(", md5 %s/%s", m.SrcMD5, m.DstMD5)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s)\n", verifyStatusMismatched, m.Key, details)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,570 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+	"google.golang.org/api/iterator"
+)
+
+// objectVersion is a version of a source object, or a delete marker.
+type objectVersion struct {
+	ID           string
+	ModTime      time.Time
+	Size         int64
+	MD5          []byte
+	ETag         string
+	DeleteMarker bool
+	// Latest is set for the current version of the object
+	Latest bool
+	Meta   *objectMeta
+	// deleted is the time a GCS generation became noncurrent
+	deleted time.Time
+}
+
+// versionSource lists every version of the objects of an S3 compatible or
+// GCS bucket. An object is listed once with all of its versions, oldest
+// first, so that they can be replayed in order by a single worker.
+type versionSource struct {
+	*bucketSource
+	// name and prefix locate the objects of the bucket for the provider API
+	name   string
+	prefix string
+	s3     *s3.S3
+	gcs    *storage.Client
+}
+
+func newVersionSource(b bucketOptions, bucket *blob.Bucket) (*versionSource, error) {
+	name, prefix, err := bucketLocation(b)
+	if err != nil {
+		return nil, err
+	}
+	s := &versionSource{
+		bucketSource: &bucketSource{bucket: bucket},
+		name:         name,
+		prefix:       prefix,
+	}
+	if !bucket.As(&s.s3) && !bucket.As(&s.gcs) {
+		return nil, fmt.Errorf("--all-versions is only supported for S3 compatible and GCS sources. The S3 buckets must use the AWS SDK v1, which is the default")
+	}
+	return s, nil
+}
+
+// bucketLocation returns the bucket name and the key prefix of a bucket, as
+// they are given in its URL.
+func bucketLocation(b bucketOptions) (string, string, error) {
+	if b.endpoint.endpoint != "" {
+		return b.endpoint.bucket, "", nil
+	}
+	u, err := url.Parse(b.url)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid bucket url %q. Reason: %w", b.url, err)
+	}
+	return u.Host, u.Query().Get("prefix"), nil
+}
+
+func (s *versionSource) list(ctx context.Context, prefix string, fn func(obj objectInfo) error) error {
+	l := &versionLister{emit: func(key string, versions []objectVersion) error {
+		if s.gcs != nil {
+			versions = gcsDeleteMarkers(versions)
+		} else {
+			// S3 lists the versions newest first
+			slices.Reverse(versions)
+		}
+		// the versions with the same modification time are kept in the order
+		// they were written, except for the current one which must stay the
+		// last
+		sort.SliceStable(versions, func(i, j int) bool {
+			if !versions[i].ModTime.Equal(versions[j].ModTime) {
+				return versions[i].ModTime.Before(versions[j].ModTime)
+			}
+			return !versions[i].Latest && versions[j].Latest
+		})
+		obj := objectInfo{Key: key, Versions: versions}
+		for _, v := range versions {
+			obj.Size += v.Size
+			obj.ModTime = v.ModTime
+		}
+		return fn(obj)
+	}}
+	var err error
+	if s.s3 != nil {
+		err = s.listS3(ctx, prefix, l)
+	} else {
+		err = s.listGCS(ctx, prefix, l)
+	}
+	if err != nil {
+		return err
+	}
+	return l.flush()
+}
+
+func (s *versionSource) listS3(ctx context.Context, prefix string, l *versionLister) error {
+	in := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(s.name),
+		Prefix: aws.String(s.prefix + prefix),
+	}
+	var listErr error
+	err := s.s3.ListObjectVersionsPagesWithContext(ctx, in, func(page *s3.ListObjectVersionsOutput, _ bool) bool {
+		// the versions and the delete markers are listed apart, each sorted
+		// by key
+		type entry struct {
+			key     string
+			version objectVersion
+		}
+		entries := make([]entry, 0, len(page.Versions)+len(page.DeleteMarkers))
+		for _, v := range page.Versions {
+			entries = append(entries, entry{
+				key: strings.TrimPrefix(aws.StringValue(v.Key), s.prefix),
+				version: objectVersion{
+					ID:      aws.StringValue(v.VersionId),
+					ModTime: aws.TimeValue(v.LastModified),
+					Size:    aws.Int64Value(v.Size),
+					ETag:    aws.StringValue(v.ETag),
+					Latest:  aws.BoolValue(v.IsLatest),
+				},
+			})
+		}
+		for _, m := range page.DeleteMarkers {
+			entries = append(entries, entry{
+				key: strings.TrimPrefix(aws.StringValue(m.Key), s.prefix),
+				version: objectVersion{
+					ID:           aws.StringValue(m.VersionId),
+					ModTime:      aws.TimeValue(m.LastModified),
+					DeleteMarker: true,
+					Latest:       aws.BoolValue(m.IsLatest),
+				},
+			})
+		}
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+		for _, e := range entries {
+			if listErr = l.add(e.key, e.version); listErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return listErr
+}
+
+func (s *versionSource) listGCS(ctx context.Context, prefix string, l *versionLister) error {
+	it := s.gcs.Bucket(s.name).Objects(ctx, &storage.Query{
+		Prefix:   s.prefix + prefix,
+		Versions: true,
+	})
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = l.add(strings.TrimPrefix(attrs.Name, s.prefix), objectVersion{
+			ID:      strconv.FormatInt(attrs.Generation, 10),
+			ModTime: attrs.Created,
+			Size:    attrs.Size,
+			MD5:     attrs.MD5,
+			ETag:    attrs.Etag,
+			Latest:  attrs.Deleted.IsZero(),
+			Meta: &objectMeta{
+				ContentType:        attrs.ContentType,
+				ContentEncoding:    attrs.ContentEncoding,
+				CacheControl:       attrs.CacheControl,
+				ContentDisposition: attrs.ContentDisposition,
+				ContentLanguage:    attrs.ContentLanguage,
+				Metadata:           attrs.Metadata,
+			},
+			// the time the generation stopped being the live one
+			deleted: attrs.Deleted,
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// gcsDeleteMarkers adds the deletions of a GCS object to its generations,
+// which are listed oldest first. GCS has no delete markers, a generation is
+// made noncurrent both when it is replaced and when it is deleted. It was
+// deleted if no generation replaced it right away.
+func gcsDeleteMarkers(generations []objectVersion) []objectVersion {
+	versions := make([]objectVersion, 0, len(generations))
+	for i, g := range generations {
+		versions = append(versions, g)
+		if g.deleted.IsZero() {
+			continue
+		}
+		if i+1 < len(generations) && generations[i+1].ModTime.Sub(g.deleted) < time.Second {
+			continue
+		}
+		versions = append(versions, objectVersion{
+			ID:           "deleted-" + g.ID,
+			ModTime:      g.deleted,
+			DeleteMarker: true,
+			Latest:       i+1 == len(generations),
+		})
+	}
+	return versions
+}
+
+// openVersion returns a reader of a version of an object.
+func (s *versionSource) openVersion(ctx context.Context, key string, v objectVersion) (*blob.Reader, error) {
+	return newRawReader(ctx, s.bucket, key, func(as func(any) bool) error {
+		var in *s3.GetObjectInput
+		if as(&in) {
+			in.VersionId = aws.String(v.ID)
+			return nil
+		}
+		var oh **storage.ObjectHandle
+		if as(&oh) {
+			gen, err := strconv.ParseInt(v.ID, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid generation %q. Reason: %w", v.ID, err)
+			}
+			*oh = (*oh).Generation(gen)
+		}
+		return nil
+	})
+}
+
+// versionMeta returns the attributes of the version read by r, for the
+// providers that don't list them.
+func versionMeta(r *blob.Reader) *objectMeta {
+	var out s3.GetObjectOutput
+	if !r.As(&out) {
+		return nil
+	}
+	meta := &objectMeta{
+		ContentType:        aws.StringValue(out.ContentType),
+		ContentEncoding:    aws.StringValue(out.ContentEncoding),
+		CacheControl:       aws.StringValue(out.CacheControl),
+		ContentDisposition: aws.StringValue(out.ContentDisposition),
+		ContentLanguage:    aws.StringValue(out.ContentLanguage),
+		Metadata:           make(map[string]string, len(out.Metadata)),
+	}
+	for key, val := range out.Metadata {
+		meta.Metadata[key] = aws.StringValue(val)
+	}
+	return meta
+}
+
+// versionLister collects the versions of an object from a listing sorted by
+// key, and emits them once the listing moves to the next object.
+type versionLister struct {
+	key      string
+	versions []objectVersion
+	emit     func(key string, versions []objectVersion) error
+}
+
+func (l *versionLister) add(key string, v objectVersion) error {
+	if key != l.key {
+		if err := l.flush(); err != nil {
+			return err
+		}
+		l.key = key
+	}
+	l.versions = append(l.versions, v)
+	return nil
+}
+
+func (l *versionLister) flush() error {
+	if len(l.versions) == 0 {
+		return nil
+	}
+	versions := l.versions
+	l.versions = nil
+	return l.emit(l.key, versions)
+}
+
+// checkDestinationVersioning fails unless the destination keeps the versions
+// written to it.
+func checkDestinationVersioning(ctx context.Context, b bucketOptions, bucket *blob.Bucket) error {
+	name, _, err := bucketLocation(b)
+	if err != nil {
+		return err
+	}
+	var client *s3.S3
+	var gcs *storage.Client
+	switch {
+	case bucket.As(&client):
+		out, err := client.GetBucketVersioningWithContext(ctx, &s3.GetBucketVersioningInput{Bucket: aws.String(name)})
+		if err != nil {
+			return fmt.Errorf("failed to read versioning of destination bucket. Reason: %w", err)
+		}
+		if aws.StringValue(out.Status) != s3.BucketVersioningStatusEnabled {
+			return fmt.Errorf("versioning is not enabled for destination bucket %s", name)
+		}
+	case bucket.As(&gcs):
+		attrs, err := gcs.Bucket(name).Attrs(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to read versioning of destination bucket. Reason: %w", err)
+		}
+		if !attrs.VersioningEnabled {
+			return fmt.Errorf("versioning is not enabled for destination bucket %s", name)
+		}
+	default:
+		return fmt.Errorf("--all-versions requires an S3 compatible or GCS destination with versioning enabled")
+	}
+	return nil
+}
+
+// destinationVersion returns the version id of the current version of key at
+// the destination.
+func destinationVersion(ctx context.Context, bucket *blob.Bucket, key string) (string, error) {
+	attrs, err := bucket.Attributes(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	var head s3.HeadObjectOutput
+	if attrs.As(&head) {
+		return aws.StringValue(head.VersionId), nil
+	}
+	var oa storage.ObjectAttrs
+	if attrs.As(&oa) {
+		return strconv.FormatInt(oa.Generation, 10), nil
+	}
+	return "", nil
+}
+
+// replayVersions writes the versions of an object to the destination, oldest
+// first, and deletes the object for its delete markers. The versions already
+// recorded in the version manifest are skipped.
+func (s *swapper) replayVersions(ctx context.Context, obj objectInfo) (skipped bool, err error) {
+	dstKey := s.mapper.dstKey(obj.Key)
+	entry := versionEntry{Key: obj.Key}
+	defer func() {
+		// the replayed versions are recorded even if the rest failed, so
+		// that they are not written twice on resume
+		if len(entry.Versions) == 0 {
+			return
+		}
+		if rerr := s.versions.record(entry); rerr != nil && err == nil {
+			err = withOp(opCheckpoint, fmt.Errorf("failed to record %s in version manifest. Reason: %w", obj.Key, rerr))
+		}
+	}()
+
+	skipped = true
+	for _, v := range obj.Versions {
+		if s.versions.replayed(obj.Key, v.ID) {
+			continue
+		}
+		skipped = false
+		rec := versionRecord{
+			Source:       v.ID,
+			ModTime:      v.ModTime,
+			Size:         v.Size,
+			DeleteMarker: v.DeleteMarker,
+			Latest:       v.Latest,
+		}
+		if v.DeleteMarker {
+			err = s.withRetry(ctx, obj.Key, func(int) error {
+				err := s.dst.Delete(ctx, dstKey)
+				if gcerrors.Code(err) == gcerrors.NotFound {
+					return nil
+				}
+				return withOp(opWriteDestination, err)
+			})
+			if err != nil {
+				return false, fmt.Errorf("failed to delete %s at destination. Reason: %w", dstKey, err)
+			}
+			entry.Versions = append(entry.Versions, rec)
+			continue
+		}
+
+		err = s.withRetry(ctx, obj.Key, func(int) error {
+			return s.copyVersion(ctx, obj.Key, v)
+		})
+		if err != nil {
+			return false, fmt.Errorf("failed to copy version %s of %s. Reason: %w", v.ID, obj.Key, err)
+		}
+		if rec.Destination, err = destinationVersion(ctx, s.dst, dstKey); err != nil {
+			return false, withOp(opStatDestination, fmt.Errorf("failed to read version of %s at destination. Reason: %w", dstKey, err))
+		}
+		entry.Versions = append(entry.Versions, rec)
+	}
+	return skipped, nil
+}
+
+// copyVersion streams a version of an object to the destination.
+func (s *swapper) copyVersion(ctx context.Context, key string, v objectVersion) error {
+	if s.serverCopy != nil && !s.serverCopy.disabled.Load() {
+		meta := v.Meta
+		if meta == nil && s.metadata.needsAttributes() {
+			start := time.Now()
+			var err error
+			meta, err = s.serverCopy.versionMeta(ctx, key, v.ID)
+			s.metrics.observe(sideSource, "stat", start)
+			if err != nil {
+				return withOp(opStatSource, fmt.Errorf("failed to read attributes of version %s from source. Reason: %w", v.ID, err))
+			}
+		}
+		obj := objectInfo{Key: key, Size: v.Size, ModTime: v.ModTime, Meta: meta}
+		if copied, err := s.serverSideCopy(ctx, key, v.ID, v.Size, s.metadata.writerOptions(obj)); copied || err != nil {
+			return err
+		}
+	}
+
+	start := time.Now()
+	r, err := s.versionSrc.openVersion(ctx, key, v)
+	s.metrics.observe(sideSource, "open", start)
+	if err != nil {
+		return withOp(opReadSource, fmt.Errorf("failed to read version %s from source. Reason: %w", v.ID, err))
+	}
+	defer r.Close()
+
+	meta := v.Meta
+	if meta == nil {
+		meta = versionMeta(r)
+	}
+	obj := objectInfo{Key: key, Size: r.Size(), ModTime: v.ModTime, Meta: meta}
+	return s.writeObject(ctx, s.mapper.dstKey(key), r, obj.Size, s.metadata.writerOptions(obj))
+}
+
+// versionRecord maps a replayed source version to the version written at
+// the destination.
+type versionRecord struct {
+	Source       string    `json:"source"`
+	Destination  string    `json:"destination,omitempty"`
+	ModTime      time.Time `json:"modTime"`
+	Size         int64     `json:"size,omitempty"`
+	DeleteMarker bool      `json:"deleteMarker,omitempty"`
+	Latest       bool      `json:"latest,omitempty"`
+}
+
+// versionEntry lists the versions of an object replayed in a run.
+type versionEntry struct {
+	Key      string          `json:"key"`
+	Versions []versionRecord `json:"versions"`
+}
+
+// versionManifest is an append-only file with a line per object, listing its
+// source versions and the destination versions they were written as. An
+// object has a line for every run that replayed some of its versions.
+type versionManifest struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	replays  map[string]map[string]bool
+	recorded int
+}
+
+// openVersionManifest opens the manifest at path. The versions replayed by
+// the previous runs are loaded if load is true, otherwise the manifest is
+// started anew.
+func openVersionManifest(path string, load bool) (*versionManifest, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	m := &versionManifest{
+		path:    path,
+		replays: make(map[string]map[string]bool),
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if load {
+		if err := m.load(path); err != nil {
+			return nil, err
+		}
+	} else {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	m.file = f
+	return m, nil
+}
+
+func (m *versionManifest) load(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry versionEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// the last line may be partial if the process was killed
+			continue
+		}
+		m.add(entry)
+	}
+	return scanner.Err()
+}
+
+func (m *versionManifest) add(entry versionEntry) {
+	ids := m.replays[entry.Key]
+	if ids == nil {
+		ids = make(map[string]bool, len(entry.Versions))
+		m.replays[entry.Key] = ids
+	}
+	for _, v := range entry.Versions {
+		ids[v.Source] = true
+	}
+}
+
+func (m *versionManifest) replayed(key, id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.replays[key][id]
+}
+
+func (m *versionManifest) record(entry versionEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.add(entry)
+	m.recorded += len(entry.Versions)
+	_, err = m.file.Write(append(data, '\n'))
+	return err
+}
+
+func (m *versionManifest) close() error {
+	return m.file.Close()
+}
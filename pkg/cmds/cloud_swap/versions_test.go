@@ -0,0 +1,146 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_swap
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"gocloud.dev/blob"
+	"gocloud.dev/blob/gcsblob"
+	"gocloud.dev/blob/s3blob"
+	"gocloud.dev/gcp"
+)
+
+// s3VersionListing lists, like S3, the versions of every object newest first
+// and its delete markers apart. Versions a1 to a3 are written within the same
+// second.
+const s3VersionListing = `<?xml version="1.0" encoding="UTF-8"?>
+<ListVersionsResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Name>bucket</Name>
+  <IsTruncated>false</IsTruncated>
+  <Version><Key>a</Key><VersionId>a3</VersionId><IsLatest>true</IsLatest><LastModified>2024-01-01T00:00:01.000Z</LastModified><Size>3</Size></Version>
+  <Version><Key>a</Key><VersionId>a2</VersionId><IsLatest>false</IsLatest><LastModified>2024-01-01T00:00:01.000Z</LastModified><Size>2</Size></Version>
+  <Version><Key>a</Key><VersionId>a1</VersionId><IsLatest>false</IsLatest><LastModified>2024-01-01T00:00:01.000Z</LastModified><Size>1</Size></Version>
+  <Version><Key>a</Key><VersionId>a0</VersionId><IsLatest>false</IsLatest><LastModified>2024-01-01T00:00:00.000Z</LastModified><Size>1</Size></Version>
+  <Version><Key>b</Key><VersionId>b2</VersionId><IsLatest>false</IsLatest><LastModified>2024-01-01T00:00:02.000Z</LastModified><Size>2</Size></Version>
+  <Version><Key>b</Key><VersionId>b1</VersionId><IsLatest>false</IsLatest><LastModified>2024-01-01T00:00:01.000Z</LastModified><Size>1</Size></Version>
+  <DeleteMarker><Key>b</Key><VersionId>bm2</VersionId><IsLatest>true</IsLatest><LastModified>2024-01-01T00:00:03.000Z</LastModified></DeleteMarker>
+  <DeleteMarker><Key>b</Key><VersionId>bm1</VersionId><IsLatest>false</IsLatest><LastModified>2024-01-01T00:00:01.500Z</LastModified></DeleteMarker>
+</ListVersionsResult>`
+
+// gcsVersionListing lists, like GCS, the generations of every object oldest
+// first. Generation 2 of a is deleted, and generations 1 and 2 of b are
+// written within the same second.
+const gcsVersionListing = `{"kind": "storage#objects", "items": [
+  {"name": "a", "generation": "1", "size": "1", "timeCreated": "2024-01-01T00:00:00Z", "timeDeleted": "2024-01-01T00:00:01Z"},
+  {"name": "a", "generation": "2", "size": "2", "timeCreated": "2024-01-01T00:00:01Z", "timeDeleted": "2024-01-01T00:00:05Z"},
+  {"name": "b", "generation": "1", "size": "1", "timeCreated": "2024-01-01T00:00:01Z", "timeDeleted": "2024-01-01T00:00:01Z"},
+  {"name": "b", "generation": "2", "size": "2", "timeCreated": "2024-01-01T00:00:01Z", "timeDeleted": "2024-01-01T00:00:01Z"},
+  {"name": "b", "generation": "3", "size": "3", "timeCreated": "2024-01-01T00:00:01Z"}
+]}`
+
+func TestVersionSourceListOrder(t *testing.T) {
+	tests := []struct {
+		provider string
+		url      string
+		listing  string
+		open     func(t *testing.T, srv *httptest.Server) *blob.Bucket
+		want     map[string][]string
+	}{
+		{
+			provider: "s3",
+			url:      "s3://bucket",
+			listing:  s3VersionListing,
+			open: func(t *testing.T, srv *httptest.Server) *blob.Bucket {
+				sess, err := session.NewSession(&aws.Config{
+					Endpoint:         aws.String(srv.URL),
+					Region:           aws.String("us-east-1"),
+					S3ForcePathStyle: aws.Bool(true),
+					Credentials:      credentials.NewStaticCredentials("id", "secret", ""),
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				b, err := s3blob.OpenBucket(context.Background(), sess, "bucket", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return b
+			},
+			want: map[string][]string{
+				"a": {"a0", "a1", "a2", "a3"},
+				"b": {"b1", "bm1", "b2", "bm2"},
+			},
+		},
+		{
+			provider: "gs",
+			url:      "gs://bucket",
+			listing:  gcsVersionListing,
+			open: func(t *testing.T, srv *httptest.Server) *blob.Bucket {
+				t.Setenv("STORAGE_EMULATOR_HOST", srv.Listener.Addr().String())
+				b, err := gcsblob.OpenBucket(context.Background(), &gcp.HTTPClient{Client: *srv.Client()}, "bucket", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return b
+			},
+			want: map[string][]string{
+				"a": {"1", "2", "deleted-2"},
+				"b": {"1", "2", "3"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.WriteString(w, tt.listing)
+			}))
+			defer srv.Close()
+			bucket := tt.open(t, srv)
+			defer bucket.Close()
+
+			src, err := newVersionSource(bucketOptions{side: "src", url: tt.url}, bucket)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string][]string{}
+			err = src.list(context.Background(), "", func(obj objectInfo) error {
+				for _, v := range obj.Versions {
+					got[obj.Key] = append(got[obj.Key], v.ID)
+				}
+				if last := obj.Versions[len(obj.Versions)-1]; !last.Latest {
+					t.Errorf("%s: last version %s is not the current one", obj.Key, last.ID)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("versions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package cluster
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -44,7 +45,7 @@ func newCmdCheck(f *config.Factory) *cobra.Command {
 				}
 				opts.Provider.KubeConfig = string(data)
 			}
-			cluster, err := checkClusterExistence(f, opts)
+			cluster, err := checkClusterExistence(cmd.Context(), f, opts)
 			if err != nil {
 				return fmt.Errorf("failed to check cluster existence. Reason: %w", err)
 			}
@@ -65,8 +66,8 @@ func newCmdCheck(f *config.Factory) *cobra.Command {
 	return cmd
 }
 
-func checkClusterExistence(f *config.Factory, opts clustermodel.CheckOptions) (*v1alpha1.ClusterInfo, error) {
-	c, err := f.Client()
+func checkClusterExistence(ctx context.Context, f *config.Factory, opts clustermodel.CheckOptions) (*v1alpha1.ClusterInfo, error) {
+	c, err := f.Client(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -17,6 +17,7 @@ limitations under the License.
 package cluster
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -44,7 +45,7 @@ func newCmdConnect(f *config.Factory) *cobra.Command {
 				}
 				opts.KubeConfig = string(data)
 			}
-			_, err := connectCluster(f, opts)
+			_, err := connectCluster(cmd.Context(), f, opts)
 			if err != nil {
 				if errors.Is(err, ace.ErrNotFound) {
 					fmt.Println("Provided cluster does not exist. Please provide a valid cluster name.")
@@ -62,8 +63,8 @@ func newCmdConnect(f *config.Factory) *cobra.Command {
 	return cmd
 }
 
-func connectCluster(f *config.Factory, opts clustermodel.ConnectOptions) (*v1alpha1.ClusterInfo, error) {
-	c, err := f.Client()
+func connectCluster(ctx context.Context, f *config.Factory, opts clustermodel.ConnectOptions) (*v1alpha1.ClusterInfo, error) {
+	c, err := f.Client(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -17,6 +17,7 @@ limitations under the License.
 package cluster
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -36,7 +37,7 @@ func newCmdGet(f *config.Factory) *cobra.Command {
 		Short:             "Get a particular cluster information",
 		DisableAutoGenTag: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cluster, err := getCluster(f, opts)
+			cluster, err := getCluster(cmd.Context(), f, opts)
 			if err != nil {
 				if errors.Is(err, ace.ErrNotFound) {
 					fmt.Println("Cluster does not exist.")
@@ -51,8 +52,8 @@ func newCmdGet(f *config.Factory) *cobra.Command {
 	return cmd
 }
 
-func getCluster(f *config.Factory, opts clustermodel.GetOptions) (*v1alpha1.ClusterInfo, error) {
-	c, err := f.Client()
+func getCluster(ctx context.Context, f *config.Factory, opts clustermodel.GetOptions) (*v1alpha1.ClusterInfo, error) {
+	c, err := f.Client(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -17,16 +17,15 @@ limitations under the License.
 package cluster
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
-	"sync"
 
 	"go.bytebuilders.dev/ace/pkg/config"
-	"go.bytebuilders.dev/ace/pkg/printer"
+	ace "go.bytebuilders.dev/client"
 	clustermodel "go.bytebuilders.dev/resource-model/apis/cluster"
 
-	"github.com/rs/xid"
 	"github.com/spf13/cobra"
 )
 
@@ -49,7 +48,7 @@ func newCmdImport(f *config.Factory) *cobra.Command {
 
 			opts.Components.FeatureSets = getFeatureSetsInfo(featureSet)
 
-			err := importCluster(f, opts)
+			err := importCluster(cmd.Context(), f, opts)
 			if err != nil {
 				return fmt.Errorf("failed to import cluster. Reason: %w", err)
 			}
@@ -72,37 +71,26 @@ func newCmdImport(f *config.Factory) *cobra.Command {
 	return cmd
 }
 
-func importCluster(f *config.Factory, opts clustermodel.ImportOptions) error {
+func importCluster(ctx context.Context, f *config.Factory, opts clustermodel.ImportOptions) error {
 	fmt.Println("Importing cluster......")
-	c, err := f.Client()
-	if err != nil {
-		return err
-	}
-	nc, err := c.NewNatsConnection("ace-cli")
-	if err != nil {
-		return err
-	}
-	defer nc.Close()
-
-	responseID := xid.New().String()
-	wg := sync.WaitGroup{}
-	wg.Add(1)
-	done := f.Canceller()
-	go func() {
-		err := printer.PrintNATSJobSteps(&wg, nc, responseID, done)
-		if err != nil {
-			fmt.Println("Failed to log the import steps. Reason: ", err)
-		}
-	}()
-
-	_, err = c.ImportCluster(opts, responseID)
-	if err != nil {
-		close(done)
-		return err
+	job := clusterJob{
+		name:    "import",
+		command: "cluster import",
+		cluster: opts.BasicInfo.Name,
+		options: opts,
+		start: func(c *ace.Client, responseID string) (string, error) {
+			cluster, err := c.ImportCluster(opts, responseID)
+			if err != nil {
+				return "", err
+			}
+			if cluster != nil && cluster.Spec.Name != "" {
+				return cluster.Spec.Name, nil
+			}
+			return opts.BasicInfo.Name, nil
+		},
+		isFinished: isClusterActive,
 	}
-	wg.Wait()
-
-	return nil
+	return job.run(ctx, f)
 }
 
 func getFeatureSetsInfo(featureSets map[string]string) []clustermodel.FeatureSet {
@@ -0,0 +1,240 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.bytebuilders.dev/ace/pkg/config"
+	"go.bytebuilders.dev/ace/pkg/jobs"
+	"go.bytebuilders.dev/ace/pkg/printer"
+	ace "go.bytebuilders.dev/client"
+	clustermodel "go.bytebuilders.dev/resource-model/apis/cluster"
+
+	"github.com/rs/xid"
+	rsapi "kmodules.xyz/resource-metadata/apis/meta/v1alpha1"
+)
+
+// clusterJob describes a long-running cluster operation whose progress is
+// published over NATS.
+type clusterJob struct {
+	// name is used in the log messages (i.e. import, reconfigure, removal)
+	name string
+	// command is recorded in the job history (i.e. cluster import)
+	command string
+	// cluster is the name of the affected cluster, if known before starting
+	cluster string
+	// options are recorded in the job history with secrets redacted
+	options interface{}
+	// start triggers the job and returns the name of the affected cluster
+	start func(c *ace.Client, responseID string) (string, error)
+	// isFinished reports whether the job has finished by looking at the
+	// cluster status, once its phase has changed from the one before the
+	// start. It is used only when NATS is unreachable.
+	isFinished func(cluster *rsapi.ClusterStatusResponse, err error) (bool, error)
+}
+
+// run starts the job and follows its progress. If the NATS server can't be
+// reached, the job is still started and its progress is tracked by polling
+// the cluster status through the HTTP API. The job is recorded in the local
+// job history. When ctx is cancelled, the CLI detaches from the job and leaves
+// it running on the server.
+func (j clusterJob) run(ctx context.Context, f *config.Factory) error {
+	c, err := f.Client(ctx)
+	if err != nil {
+		return err
+	}
+
+	responseID := xid.New().String()
+	rec, err := jobs.Start(responseID, j.command, j.cluster, j.options)
+	if err != nil {
+		fmt.Println("Failed to record the job in history. Reason: ", err)
+		rec = nil
+	}
+
+	err = j.follow(ctx, c, responseID, rec)
+	if errors.Is(err, printer.ErrTerminatedByUser) {
+		if rec != nil {
+			recordHistoryError(rec.Interrupt())
+		}
+		fmt.Printf("\nDetached from the %s job. It keeps running on the server.\n", j.name)
+		fmt.Printf("To re-attach, run: ace job attach %s\n", responseID)
+		return nil
+	}
+	if rec != nil {
+		recordHistoryError(rec.Finish(err))
+	}
+	return err
+}
+
+func (j clusterJob) follow(ctx context.Context, c *ace.Client, responseID string, rec *jobs.Recorder) error {
+	nc, err := c.NewNatsConnection("ace-cli")
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("interrupted before the job was started. Reason: %w", err)
+		}
+		printer.PrintDegradedModeNotice(err)
+		if rec != nil {
+			rec.SetProgressMode(jobs.ProgressModePolling)
+		}
+		return j.runWithPolling(ctx, c, responseID, rec)
+	}
+	defer nc.Close()
+
+	var jr printer.JobRecorder
+	if rec != nil {
+		jr = rec
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	logErr := make(chan error, 1)
+	go func() {
+		err := printer.PrintNATSJobSteps(jobCtx, nc, responseID, jr)
+		if err != nil && !errors.Is(err, printer.ErrTerminatedByUser) && !errors.Is(err, printer.ErrJobFailed) {
+			fmt.Printf("Failed to log the %s steps. Reason: %v\n", j.name, err)
+		}
+		logErr <- err
+	}()
+
+	name, err := j.start(c, responseID)
+	if err != nil {
+		cancel()
+		if ctx.Err() != nil {
+			return fmt.Errorf("interrupted before the job was started. Reason: %w", err)
+		}
+		return err
+	}
+	if rec != nil {
+		rec.SetCluster(name)
+	}
+
+	// the job may still succeed if its steps can't be logged
+	err = <-logErr
+	switch {
+	case errors.Is(err, printer.ErrTerminatedByUser):
+		return err
+	case errors.Is(err, printer.ErrJobFailed):
+		return fmt.Errorf("%s %w", j.name, err)
+	}
+	return nil
+}
+
+func (j clusterJob) runWithPolling(ctx context.Context, c *ace.Client, responseID string, rec *jobs.Recorder) error {
+	// the job is followed through the phase of the cluster, which may already
+	// be the final one (i.e. when reconfiguring an active cluster)
+	initial := ""
+	if j.cluster != "" {
+		var err error
+		if initial, err = clusterPhase(c, j.cluster); err != nil {
+			return err
+		}
+	}
+
+	name, err := j.start(c, responseID)
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("interrupted before the job was started. Reason: %w", err)
+		}
+		return err
+	}
+	if rec != nil {
+		rec.SetCluster(name)
+	}
+
+	changed := false
+	status := func() (string, bool, error) {
+		cluster, err := c.GetCluster(clustermodel.GetOptions{Name: name})
+		if err != nil && !errors.Is(err, ace.ErrNotFound) {
+			return "", false, err
+		}
+		var st *rsapi.ClusterStatusResponse
+		phase := ""
+		state := "Cluster not found"
+		if err == nil {
+			st = &cluster.Status
+			phase = string(st.Phase)
+			state = fmt.Sprintf("Cluster phase: %s", st.Phase)
+			if st.Reason != "" {
+				state = fmt.Sprintf("%s (%s)", state, st.Reason)
+			}
+		}
+		// until the phase changes, the job has not made any visible progress
+		if phase != initial {
+			changed = true
+		}
+		if !changed {
+			return state, false, nil
+		}
+		finished, err := j.isFinished(st, err)
+		return state, finished, err
+	}
+	return printer.PollJobStatus(ctx, status, printer.DefaultPollInterval, printer.DefaultPollTimeout)
+}
+
+// clusterPhase returns the phase of a cluster, or an empty string if it
+// doesn't exist.
+func clusterPhase(c *ace.Client, name string) (string, error) {
+	cluster, err := c.GetCluster(clustermodel.GetOptions{Name: name})
+	if errors.Is(err, ace.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get the status of cluster %s. Reason: %w", name, err)
+	}
+	return string(cluster.Status.Phase), nil
+}
+
+func recordHistoryError(err error) {
+	if err != nil {
+		fmt.Println("Failed to record the job in history. Reason: ", err)
+	}
+}
+
+// isClusterActive marks a job finished once the cluster becomes active, and
+// failed if the cluster is lost or not ready.
+func isClusterActive(status *rsapi.ClusterStatusResponse, err error) (bool, error) {
+	if errors.Is(err, ace.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	switch status.Phase {
+	case rsapi.ClusterPhaseActive:
+		return true, nil
+	case rsapi.ClusterPhaseNotReady, rsapi.ClusterPhaseLost:
+		if status.Message != "" {
+			return false, fmt.Errorf("cluster phase is %s. Reason: %s", status.Phase, status.Message)
+		}
+		return false, fmt.Errorf("cluster phase is %s", status.Phase)
+	}
+	return false, nil
+}
+
+// isClusterGone marks a job finished once the cluster no longer exists.
+func isClusterGone(status *rsapi.ClusterStatusResponse, err error) (bool, error) {
+	if errors.Is(err, ace.ErrNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return status.Phase == rsapi.ClusterPhaseNotImported, nil
+}
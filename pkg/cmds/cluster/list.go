@@ -17,6 +17,7 @@ limitations under the License.
 package cluster
 
 import (
+	"context"
 	"fmt"
 
 	"go.bytebuilders.dev/ace/pkg/config"
@@ -34,7 +35,7 @@ func newCmdList(f *config.Factory) *cobra.Command {
 		Short:             "List cluster managed by ACE platform",
 		DisableAutoGenTag: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			clusters, err := listClusters(f, listOptions)
+			clusters, err := listClusters(cmd.Context(), f, listOptions)
 			if err != nil {
 				return fmt.Errorf("failed to list clusters. Reason: %w", err)
 			}
@@ -49,8 +50,8 @@ func newCmdList(f *config.Factory) *cobra.Command {
 	return cmd
 }
 
-func listClusters(f *config.Factory, opts clustermodel.ListOptions) (*v1alpha1.ClusterInfoList, error) {
-	c, err := f.Client()
+func listClusters(ctx context.Context, f *config.Factory, opts clustermodel.ListOptions) (*v1alpha1.ClusterInfoList, error) {
+	c, err := f.Client(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -17,16 +17,14 @@ limitations under the License.
 package cluster
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"sync"
 
 	"go.bytebuilders.dev/ace/pkg/config"
-	"go.bytebuilders.dev/ace/pkg/printer"
 	ace "go.bytebuilders.dev/client"
 	clustermodel "go.bytebuilders.dev/resource-model/apis/cluster"
 
-	"github.com/rs/xid"
 	"github.com/spf13/cobra"
 )
 
@@ -40,7 +38,7 @@ func newCmdReconfigure(f *config.Factory) *cobra.Command {
 			if !opts.Components.AllFeatures {
 				opts.Components.FeatureSets = defaultFeatureSet
 			}
-			err := reconfigureCluster(f, opts)
+			err := reconfigureCluster(cmd.Context(), f, opts)
 			if err != nil {
 				if errors.Is(err, ace.ErrNotFound) {
 					fmt.Println("Provided cluster does not exist. Please provide a valid cluster name.")
@@ -57,36 +55,18 @@ func newCmdReconfigure(f *config.Factory) *cobra.Command {
 	return cmd
 }
 
-func reconfigureCluster(f *config.Factory, opts clustermodel.ReconfigureOptions) error {
+func reconfigureCluster(ctx context.Context, f *config.Factory, opts clustermodel.ReconfigureOptions) error {
 	fmt.Println("Reconfiguring cluster......")
-	c, err := f.Client()
-	if err != nil {
-		return err
-	}
-
-	nc, err := c.NewNatsConnection("ace-cli")
-	if err != nil {
-		return err
-	}
-	defer nc.Close()
-
-	responseID := xid.New().String()
-	wg := sync.WaitGroup{}
-	wg.Add(1)
-	done := f.Canceller()
-	go func() {
-		err := printer.PrintNATSJobSteps(&wg, nc, responseID, done)
-		if err != nil {
-			fmt.Println("Failed to log reconfigure steps. Reason: ", err)
-		}
-	}()
-
-	_, err = c.ReconfigureCluster(opts, responseID)
-	if err != nil {
-		close(done)
-		return err
+	job := clusterJob{
+		name:    "reconfigure",
+		command: "cluster reconfigure",
+		cluster: opts.BasicInfo.Name,
+		options: opts,
+		start: func(c *ace.Client, responseID string) (string, error) {
+			_, err := c.ReconfigureCluster(opts, responseID)
+			return opts.BasicInfo.Name, err
+		},
+		isFinished: isClusterActive,
 	}
-	wg.Wait()
-
-	return nil
+	return job.run(ctx, f)
 }
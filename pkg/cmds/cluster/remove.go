@@ -17,16 +17,14 @@ limitations under the License.
 package cluster
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"sync"
 
 	"go.bytebuilders.dev/ace/pkg/config"
-	"go.bytebuilders.dev/ace/pkg/printer"
 	ace "go.bytebuilders.dev/client"
 	clustermodel "go.bytebuilders.dev/resource-model/apis/cluster"
 
-	"github.com/rs/xid"
 	"github.com/spf13/cobra"
 )
 
@@ -40,7 +38,7 @@ func newCmdRemove(f *config.Factory) *cobra.Command {
 			if !opts.Components.AllFeatures {
 				opts.Components.FeatureSets = defaultFeatureSet
 			}
-			err := removeCluster(f, opts)
+			err := removeCluster(cmd.Context(), f, opts)
 			if err != nil {
 				if errors.Is(err, ace.ErrNotFound) {
 					fmt.Println("Cluster has been removed already.")
@@ -57,36 +55,17 @@ func newCmdRemove(f *config.Factory) *cobra.Command {
 	return cmd
 }
 
-func removeCluster(f *config.Factory, opts clustermodel.RemovalOptions) error {
+func removeCluster(ctx context.Context, f *config.Factory, opts clustermodel.RemovalOptions) error {
 	fmt.Println("Removing cluster......")
-	c, err := f.Client()
-	if err != nil {
-		return err
-	}
-
-	nc, err := c.NewNatsConnection("ace-cli")
-	if err != nil {
-		return err
-	}
-	defer nc.Close()
-
-	responseID := xid.New().String()
-	wg := sync.WaitGroup{}
-	wg.Add(1)
-	done := f.Canceller()
-	go func() {
-		err := printer.PrintNATSJobSteps(&wg, nc, responseID, done)
-		if err != nil {
-			fmt.Println("Failed to log removal steps. Reason: ", err)
-		}
-	}()
-
-	err = c.RemoveCluster(opts, responseID)
-	if err != nil {
-		close(done)
-		return err
+	job := clusterJob{
+		name:    "removal",
+		command: "cluster remove",
+		cluster: opts.Name,
+		options: opts,
+		start: func(c *ace.Client, responseID string) (string, error) {
+			return opts.Name, c.RemoveCluster(opts, responseID)
+		},
+		isFinished: isClusterGone,
 	}
-	wg.Wait()
-
-	return nil
+	return job.run(ctx, f)
 }
@@ -0,0 +1,164 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.bytebuilders.dev/ace/pkg/installer"
+
+	"github.com/spf13/cobra"
+)
+
+func newCmdDiff() *cobra.Command {
+	var (
+		chart  string
+		output string
+	)
+	cmd := &cobra.Command{
+		Use:   "diff <old-values> <new-values>",
+		Short: "Compare two values files of an installer chart",
+		Long: `Compare two values files of an installer chart field by field.
+
+The values are compared once decoded into the values type of the chart. Fields
+moved to another key and fields not part of the values type are flagged, and
+changes to images, network policies, service accounts, TLS settings,
+security contexts and credentials are listed first. Passwords, tokens and
+private keys are redacted.`,
+		Example: `  ace installer diff old.yaml new.yaml --chart kube-ui-server
+  ace installer diff old.yaml new.yaml --chart panopticon -o json`,
+		Args:              cobra.ExactArgs(2),
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "text" && output != "json" {
+				return fmt.Errorf("invalid output format %q. Supported formats are text and json", output)
+			}
+			cmd.SilenceUsage = true
+			return diffValues(args[0], args[1], chart, output)
+		},
+	}
+	cmd.Flags().StringVar(&chart, "chart", "", fmt.Sprintf("Chart the values are meant for (any of %s)", strings.Join(installer.ComponentNames(), ",")))
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format (any of text,json)")
+	_ = cmd.MarkFlagRequired("chart")
+	return cmd
+}
+
+func diffValues(oldFile, newFile, chart, output string) error {
+	c, err := installer.FindComponent(chart)
+	if err != nil {
+		return err
+	}
+	oldData, err := os.ReadFile(oldFile)
+	if err != nil {
+		return err
+	}
+	newData, err := os.ReadFile(newFile)
+	if err != nil {
+		return err
+	}
+	changes, err := installer.Diff(c, oldData, newData)
+	if err != nil {
+		return err
+	}
+
+	if output == "json" {
+		if changes == nil {
+			changes = []installer.Change{}
+		}
+		data, err := json.MarshalIndent(struct {
+			Component string             `json:"component"`
+			Old       string             `json:"old"`
+			New       string             `json:"new"`
+			Changes   []installer.Change `json:"changes"`
+		}{c.Name, oldFile, newFile, changes}, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(changes) == 0 {
+		fmt.Printf("No changes between %s and %s\n", oldFile, newFile)
+		return nil
+	}
+	var security, other []installer.Change
+	for _, ch := range changes {
+		if ch.Category != "" {
+			security = append(security, ch)
+		} else {
+			other = append(other, ch)
+		}
+	}
+	if len(security) > 0 {
+		fmt.Println("Security relevant changes:")
+		printChanges(os.Stdout, security)
+	}
+	if len(other) > 0 {
+		if len(security) > 0 {
+			fmt.Println()
+		}
+		fmt.Println("Changes:")
+		printChanges(os.Stdout, other)
+	}
+	return nil
+}
+
+func printChanges(w io.Writer, changes []installer.Change) {
+	for _, ch := range changes {
+		var line string
+		switch ch.Kind {
+		case installer.ChangeAdded:
+			line = fmt.Sprintf("  + %s: %s", ch.Path, formatValue(ch.New, ch.Redacted))
+		case installer.ChangeRemoved:
+			line = fmt.Sprintf("  - %s: %s", ch.Path, formatValue(ch.Old, ch.Redacted))
+		case installer.ChangeRenamed:
+			line = fmt.Sprintf("  > %s renamed to %s", ch.From, ch.Path)
+		default:
+			line = fmt.Sprintf("  ~ %s: %s -> %s", ch.Path, formatValue(ch.Old, ch.Redacted), formatValue(ch.New, ch.Redacted))
+		}
+		var notes []string
+		if ch.Category != "" {
+			notes = append(notes, ch.Category)
+		}
+		if ch.Unknown {
+			notes = append(notes, "not a field of the chart")
+		}
+		if len(notes) > 0 {
+			line += fmt.Sprintf(" [%s]", strings.Join(notes, ", "))
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+func formatValue(v any, redacted bool) string {
+	if redacted {
+		return "<redacted>"
+	}
+	if v == nil {
+		return "<unset>"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
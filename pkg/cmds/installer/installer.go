@@ -27,6 +27,9 @@ func NewCmdInstaller() *cobra.Command {
 		DisableAutoGenTag: true,
 	}
 	cmd.AddCommand(newCmdValidate())
+	cmd.AddCommand(newCmdValues())
+	cmd.AddCommand(newCmdSchema())
+	cmd.AddCommand(newCmdDiff())
 
 	return cmd
 }
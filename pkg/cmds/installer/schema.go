@@ -0,0 +1,109 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package installer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.bytebuilders.dev/ace/pkg/installer"
+
+	"github.com/spf13/cobra"
+)
+
+func newCmdSchema() *cobra.Command {
+	var (
+		output    string
+		all       bool
+		outputDir string
+	)
+	cmd := &cobra.Command{
+		Use:   "schema [component]",
+		Short: "Print the JSON Schema of the values of an installer chart",
+		Long: fmt.Sprintf(`Print the JSON Schema (draft 2020-12) of the values of an installer chart.
+
+With --all, the schemas of every component are written to a directory. Editors
+using yaml-language-server pick up a schema referenced from the values file:
+
+  # yaml-language-server: $schema=./schemas/kube-ui-server.schema.json
+
+Supported components are %s.`, strings.Join(installer.ComponentNames(), ", ")),
+		Example: `  ace installer schema kube-ui-server
+  ace installer schema scanner -o yaml
+  ace installer schema --all --output-dir schemas`,
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgs:         installer.ComponentNames(),
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if all != (len(args) == 0) {
+				return errors.New("either a component or --all is required")
+			}
+			if output != "json" && output != "yaml" {
+				return fmt.Errorf("invalid output format %q. Supported formats are json and yaml", output)
+			}
+			cmd.SilenceUsage = true
+
+			if !all {
+				c, err := installer.FindComponent(args[0])
+				if err != nil {
+					return err
+				}
+				data, err := marshalSchema(installer.GenerateSchema(c), output)
+				if err != nil {
+					return err
+				}
+				_, err = os.Stdout.Write(data)
+				return err
+			}
+
+			if err := os.MkdirAll(outputDir, 0o755); err != nil {
+				return err
+			}
+			for _, c := range installer.Components() {
+				data, err := marshalSchema(installer.GenerateSchema(c), output)
+				if err != nil {
+					return err
+				}
+				filename := filepath.Join(outputDir, c.Name+".schema."+output)
+				if err := os.WriteFile(filename, data, 0o644); err != nil {
+					return err
+				}
+				fmt.Printf("Wrote %s\n", filename)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "json", "Output format (any of json,yaml)")
+	cmd.Flags().BoolVar(&all, "all", false, "Write the schemas of all the components to --output-dir")
+	cmd.Flags().StringVar(&outputDir, "output-dir", "schemas", "Directory the schemas are written to with --all")
+	return cmd
+}
+
+func marshalSchema(s *installer.JSONSchema, output string) ([]byte, error) {
+	if output == "yaml" {
+		return s.YAML()
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
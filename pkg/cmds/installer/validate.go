@@ -17,17 +17,64 @@ limitations under the License.
 package installer
 
 import (
+	"fmt"
+	"os"
+	"strings"
+
+	"go.bytebuilders.dev/ace/pkg/installer"
+
 	"github.com/spf13/cobra"
 )
 
 func newCmdValidate() *cobra.Command {
+	var (
+		file    string
+		chart   string
+		partial bool
+	)
 	cmd := &cobra.Command{
-		Use:               "validate",
-		Short:             "Validate installer options",
+		Use:   "validate",
+		Short: "Validate installer options",
+		Long: `Validate an installer values file against the values type of the chart.
+
+Unknown fields, values of the wrong type, values not allowed by an enum and
+missing required fields are reported along with their path and line number.
+The values file is merged over the values of the chart, so only the fields
+marked as required in the values type have to be set.`,
+		Example:           `  ace installer validate -f values.yaml --chart kube-ui-server`,
 		DisableAutoGenTag: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return nil
+			cmd.SilenceUsage = true
+			return validate(file, chart, partial)
 		},
 	}
+	cmd.Flags().StringVarP(&file, "file", "f", "", "Path to the values file")
+	cmd.Flags().StringVar(&chart, "chart", "", fmt.Sprintf("Chart the values are meant for (any of %s)", strings.Join(installer.ComponentNames(), ",")))
+	cmd.Flags().BoolVar(&partial, "partial", false, "Don't report missing required fields, i.e. for files overriding a few values only")
+	_ = cmd.MarkFlagRequired("file")
+	_ = cmd.MarkFlagRequired("chart")
 	return cmd
 }
+
+func validate(file, chart string, partial bool) error {
+	c, err := installer.FindComponent(chart)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	problems, err := installer.Validate(c, data, partial)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s. Reason: %w", file, err)
+	}
+	for _, p := range problems {
+		fmt.Printf("%s:%s\n", file, p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s is not a valid %s values file: %d problem(s) found", file, c.Name, len(problems))
+	}
+	fmt.Printf("%s is a valid %s values file\n", file, c.Name)
+	return nil
+}
@@ -0,0 +1,67 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package installer
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"go.bytebuilders.dev/ace/pkg/installer"
+
+	"github.com/spf13/cobra"
+)
+
+func newCmdValues() *cobra.Command {
+	var (
+		set     []string
+		minimal bool
+	)
+	cmd := &cobra.Command{
+		Use:   "values <component>",
+		Short: "Generate a values file for an installer chart",
+		Long: fmt.Sprintf(`Generate a values file for an installer chart, with every field documented.
+
+The fields with a default in the values type are set to it. The other fields
+are commented out with their zero value as a placeholder, so that the chart's
+own defaults apply to them until they are uncommented. Fields given with --set
+are always written.
+
+Supported components are %s.`, strings.Join(installer.ComponentNames(), ", ")),
+		Example: `  ace installer values kube-ui-server > values.yaml
+  ace installer values gatekeeper-library --set enforcementAction=deny --minimal`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgs:         installer.ComponentNames(),
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			c, err := installer.FindComponent(args[0])
+			if err != nil {
+				return err
+			}
+			data, err := installer.GenerateValues(c, set, minimal)
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(data)
+			return err
+		},
+	}
+	cmd.Flags().StringArrayVar(&set, "set", nil, "Set a value in '<path>=<value>' format (i.e. image.tag=v0.0.1 or tolerations[0].key=foo). Can be repeated.")
+	cmd.Flags().BoolVar(&minimal, "minimal", false, "Only write the fields differing from their default")
+	return cmd
+}
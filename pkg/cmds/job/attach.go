@@ -0,0 +1,87 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.bytebuilders.dev/ace/pkg/config"
+	"go.bytebuilders.dev/ace/pkg/jobs"
+	"go.bytebuilders.dev/ace/pkg/printer"
+
+	"github.com/spf13/cobra"
+)
+
+func newCmdAttach(f *config.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "attach <id>",
+		Short:             "Re-attach to a running job and follow its progress",
+		Args:              cobra.ExactArgs(1),
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return attachJob(cmd.Context(), f, args[0])
+		},
+	}
+	return cmd
+}
+
+func attachJob(ctx context.Context, f *config.Factory, id string) error {
+	c, err := f.Client(ctx)
+	if err != nil {
+		return err
+	}
+	nc, err := c.NewNatsConnection("ace-cli")
+	if err != nil {
+		return fmt.Errorf("failed to connect to NATS. Use `ace cluster get` to check the cluster status instead. Reason: %w", err)
+	}
+	defer nc.Close()
+
+	var rec printer.JobRecorder
+	r, err := jobs.Resume(id)
+	switch {
+	case err == nil:
+		rec = r
+	case errors.Is(err, jobs.ErrJobNotFound):
+		r = nil
+	default:
+		fmt.Println("Failed to record the job in history. Reason: ", err)
+		r = nil
+	}
+
+	fmt.Printf("Attached to job %s. Steps completed while detached are not shown.\n", id)
+	err = printer.PrintNATSJobSteps(ctx, nc, id, rec)
+	if errors.Is(err, printer.ErrTerminatedByUser) {
+		if r != nil {
+			recordHistoryError(r.Interrupt())
+		}
+		fmt.Println("\nDetached from the job. It keeps running on the server.")
+		fmt.Printf("To re-attach, run: ace job attach %s\n", id)
+		return nil
+	}
+	if r != nil {
+		recordHistoryError(r.Finish(err))
+	}
+	return err
+}
+
+func recordHistoryError(err error) {
+	if err != nil {
+		fmt.Println("Failed to record the job in history. Reason: ", err)
+	}
+}
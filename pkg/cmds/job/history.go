@@ -0,0 +1,54 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	"fmt"
+
+	"go.bytebuilders.dev/ace/pkg/jobs"
+	"go.bytebuilders.dev/ace/pkg/printer"
+
+	"github.com/spf13/cobra"
+)
+
+func newCmdHistory() *cobra.Command {
+	filter := jobs.Filter{}
+	cmd := &cobra.Command{
+		Use:               "history",
+		Short:             "List the jobs started from this machine",
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			items, err := jobs.List(filter)
+			if err != nil {
+				return fmt.Errorf("failed to list job history. Reason: %w", err)
+			}
+			if len(items) == 0 {
+				fmt.Println("No job found.")
+				return nil
+			}
+			return printer.PrintJobList(items)
+		},
+	}
+	cmd.Flags().StringVar(&filter.Command, "command", "", "List only the jobs of this command (i.e. import, cluster remove)")
+	cmd.Flags().StringVar(&filter.Cluster, "cluster", "", "List only the jobs of this cluster")
+	cmd.Flags().StringVar(&filter.Context, "context-name", "", "List only the jobs started in this context")
+	cmd.Flags().StringVar(&filter.Organization, "organization", "", "List only the jobs started for this organization")
+	cmd.Flags().StringVar(&filter.Result, "result", "", "List only the jobs with this result (any of Success,Failed,Interrupted,Running)")
+	cmd.Flags().DurationVar(&filter.Since, "since", 0, "List only the jobs started within this duration (i.e. 24h)")
+	cmd.Flags().IntVar(&filter.Limit, "limit", 0, "Maximum number of jobs to list. Default is all.")
+	return cmd
+}
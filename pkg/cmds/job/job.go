@@ -0,0 +1,38 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	"go.bytebuilders.dev/ace/pkg/config"
+	"go.bytebuilders.dev/ace/pkg/printer"
+
+	"github.com/spf13/cobra"
+)
+
+func NewCmdJob(f *config.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "job",
+		Short:             "Inspect and follow the jobs started from this machine",
+		DisableAutoGenTag: true,
+	}
+	cmd.AddCommand(newCmdHistory())
+	cmd.AddCommand(newCmdShow())
+	cmd.AddCommand(newCmdAttach(f))
+
+	cmd.PersistentFlags().StringVarP(&printer.OutputFormat, "output", "o", "", "Output format (any of json,yaml,table). Default is table.")
+	return cmd
+}
@@ -0,0 +1,52 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	"errors"
+	"fmt"
+
+	"go.bytebuilders.dev/ace/pkg/jobs"
+	"go.bytebuilders.dev/ace/pkg/printer"
+
+	"github.com/spf13/cobra"
+)
+
+func newCmdShow() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "show <id>",
+		Short:             "Show the details and the transcript of a job",
+		Args:              cobra.ExactArgs(1),
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			job, err := jobs.Get(args[0])
+			if err != nil {
+				if errors.Is(err, jobs.ErrJobNotFound) {
+					fmt.Println("Job does not exist.")
+					return nil
+				}
+				return fmt.Errorf("failed to get the job. Reason: %w", err)
+			}
+			transcript, err := jobs.ReadTranscript(job.ID)
+			if err != nil {
+				return fmt.Errorf("failed to read the job transcript. Reason: %w", err)
+			}
+			return printer.PrintJob(job, transcript)
+		},
+	}
+	return cmd
+}
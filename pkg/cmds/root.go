@@ -17,6 +17,8 @@ limitations under the License.
 package cmds
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
@@ -26,6 +28,7 @@ import (
 	"go.bytebuilders.dev/ace/pkg/cmds/cluster"
 	cmdconfig "go.bytebuilders.dev/ace/pkg/cmds/config"
 	"go.bytebuilders.dev/ace/pkg/cmds/installer"
+	"go.bytebuilders.dev/ace/pkg/cmds/job"
 	"go.bytebuilders.dev/ace/pkg/config"
 	ace "go.bytebuilders.dev/client"
 
@@ -44,12 +47,12 @@ func NewRootCmd() *cobra.Command {
 	rootCmd.PersistentFlags().StringVar(&config.Organization, "org", "", "Use this organization for instead of auto-detecting current one")
 
 	f := &config.Factory{
-		Client:    aceClient,
-		Canceller: canceller,
+		Client: aceClient,
 	}
 	rootCmd.AddCommand(cmdconfig.NewCmdConfig())
 	rootCmd.AddCommand(cluster.NewCmdCluster(f))
 	rootCmd.AddCommand(auth.NewCmdAuth())
+	rootCmd.AddCommand(job.NewCmdJob(f))
 
 	rootCmd.AddCommand(cloud_swap.NewCmdCloudSwap())
 
@@ -61,12 +64,12 @@ func NewRootCmd() *cobra.Command {
 	return rootCmd
 }
 
-func aceClient() (*ace.Client, error) {
+func aceClient(ctx context.Context) (*ace.Client, error) {
 	cfg, err := config.GetContext()
 	if err != nil {
 		return nil, err
 	}
-	client := ace.NewClient(cfg.Endpoint)
+	client := ace.NewClientWithHTTP(config.NewHTTPClient(ctx), cfg.Endpoint)
 	if config.Organization != "" {
 		client = client.WithOrganization(config.Organization)
 	}
@@ -89,8 +92,20 @@ func aceClient() (*ace.Client, error) {
 	return client, err
 }
 
-func canceller() chan os.Signal {
-	stopCh := make(chan os.Signal, 1)
-	signal.Notify(stopCh, syscall.SIGINT, syscall.SIGTERM)
-	return stopCh
+// SetupSignalContext returns a context that is cancelled on the first SIGINT
+// or SIGTERM, so that the running command can detach cleanly. A second signal
+// terminates the CLI immediately.
+func SetupSignalContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		fmt.Fprintln(os.Stderr, "\nInterrupted. Press Ctrl-C again to exit immediately.")
+		cancel()
+		<-sigCh
+		os.Exit(130)
+	}()
+	return ctx
 }
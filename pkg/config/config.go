@@ -29,6 +29,7 @@ import (
 const (
 	configVersion = "v1"
 	ACECONFIG     = "ACECONFIG"
+	ACESTATEDIR   = "ACESTATEDIR"
 )
 
 var (
@@ -191,6 +192,32 @@ func getConfigFilepath() (string, error) {
 	return filepath.Join(configDir, "ace", fmt.Sprintf("config_%s.yaml", configVersion)), nil
 }
 
+// GetStateDir returns the directory where the CLI keeps its local state
+// (i.e. job history). It can be overridden with the ACESTATEDIR env.
+func GetStateDir() (string, error) {
+	stateDir := os.Getenv(ACESTATEDIR)
+	if stateDir != "" {
+		return stateDir, nil
+	}
+	if xdgStateHome := os.Getenv("XDG_STATE_HOME"); xdgStateHome != "" {
+		return filepath.Join(xdgStateHome, "ace"), nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".local", "state", "ace"), nil
+}
+
+// GetCurrentContextName returns the name of the context in use.
+func GetCurrentContextName() (string, error) {
+	config, err := ReadConfig()
+	if err != nil {
+		return "", err
+	}
+	return config.getCurrentContext(), nil
+}
+
 func defaultConfig() Config {
 	return Config{
 		Version: configVersion,
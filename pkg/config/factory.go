@@ -17,12 +17,32 @@ limitations under the License.
 package config
 
 import (
-	"os"
+	"context"
+	"net/http"
 
 	ace "go.bytebuilders.dev/client"
 )
 
 type Factory struct {
-	Client    func() (*ace.Client, error)
-	Canceller func() chan os.Signal
+	Client func(ctx context.Context) (*ace.Client, error)
+}
+
+// NewHTTPClient returns a http client whose requests are cancelled as soon as
+// ctx is done.
+func NewHTTPClient(ctx context.Context) *http.Client {
+	return &http.Client{
+		Transport: &contextTransport{
+			ctx:  ctx,
+			base: http.DefaultTransport,
+		},
+	}
+}
+
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
 }
@@ -0,0 +1,73 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package installer
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"kubeops.dev/installer/apis/installer/v1alpha1"
+)
+
+// Component is an installer chart along with the type of its values.
+type Component struct {
+	// Name is the name of the chart
+	Name string
+	Kind string
+	Spec reflect.Type
+}
+
+var components = []Component{
+	{Name: "cluster-connector", Kind: v1alpha1.ResourceKindClusterConnector, Spec: reflect.TypeOf(v1alpha1.ClusterConnectorSpec{})},
+	{Name: "config-syncer", Kind: v1alpha1.ResourceKindConfigSyncer, Spec: reflect.TypeOf(v1alpha1.ConfigSyncerSpec{})},
+	{Name: "external-dns-operator", Kind: v1alpha1.ResourceKindExternalDnsOperator, Spec: reflect.TypeOf(v1alpha1.ExternalDnsOperatorSpec{})},
+	{Name: "falco-ui-server", Kind: v1alpha1.ResourceKindFalcoUiServer, Spec: reflect.TypeOf(v1alpha1.FalcoUiServerSpec{})},
+	{Name: "gatekeeper-grafana-dashboards", Kind: v1alpha1.ResourceKindGatekeeperGrafanaDashboards, Spec: reflect.TypeOf(v1alpha1.GatekeeperGrafanaDashboardsSpec{})},
+	{Name: "gatekeeper-library", Kind: v1alpha1.ResourceKindGatekeeperLibrary, Spec: reflect.TypeOf(v1alpha1.GatekeeperLibrarySpec{})},
+	{Name: "kube-ui-server", Kind: v1alpha1.ResourceKindKubeUiServer, Spec: reflect.TypeOf(v1alpha1.KubeUiServerSpec{})},
+	{Name: "opencost-grafana-dashboards", Kind: v1alpha1.ResourceKindOpencostGrafanaDashboards, Spec: reflect.TypeOf(v1alpha1.OpencostGrafanaDashboardsSpec{})},
+	{Name: "panopticon", Kind: v1alpha1.ResourceKindPanopticon, Spec: reflect.TypeOf(v1alpha1.PanopticonSpec{})},
+	{Name: "petset", Kind: v1alpha1.ResourceKindPetset, Spec: reflect.TypeOf(v1alpha1.PetsetSpec{})},
+	{Name: "scanner", Kind: v1alpha1.ResourceKindScanner, Spec: reflect.TypeOf(v1alpha1.ScannerSpec{})},
+	{Name: "sidekick", Kind: v1alpha1.ResourceKindSidekick, Spec: reflect.TypeOf(v1alpha1.SidekickSpec{})},
+	{Name: "supervisor", Kind: v1alpha1.ResourceKindSupervisor, Spec: reflect.TypeOf(v1alpha1.SupervisorSpec{})},
+}
+
+// Components returns the supported components sorted by name.
+func Components() []Component {
+	return append([]Component(nil), components...)
+}
+
+// ComponentNames returns the chart names of the supported components.
+func ComponentNames() []string {
+	names := make([]string, 0, len(components))
+	for _, c := range components {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// FindComponent looks up a component by its chart name or its kind.
+func FindComponent(name string) (Component, error) {
+	for _, c := range components {
+		if c.Name == name || strings.EqualFold(c.Kind, name) {
+			return c, nil
+		}
+	}
+	return Component{}, fmt.Errorf("unknown component %q. Supported components are %s", name, strings.Join(ComponentNames(), ", "))
+}
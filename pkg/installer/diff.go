@@ -0,0 +1,406 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	core "k8s.io/api/core/v1"
+	"kubeops.dev/installer/apis/installer/v1alpha1"
+)
+
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+	ChangeRenamed = "renamed"
+)
+
+// Change is a difference between two values files.
+type Change struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"`
+	// From is the path of a renamed field in the old values file
+	From    string `json:"from,omitempty"`
+	Old     any    `json:"old,omitempty"`
+	New     any    `json:"new,omitempty"`
+	OldLine int    `json:"oldLine,omitempty"`
+	NewLine int    `json:"newLine,omitempty"`
+	// Category is set for the security relevant changes (e.g. image or tls)
+	Category string `json:"category,omitempty"`
+	// Unknown is set when the field is not in the values type, so that it is
+	// ignored by the chart.
+	Unknown bool `json:"unknown,omitempty"`
+	// Redacted is set when the values are sensitive and left out.
+	Redacted bool `json:"redacted,omitempty"`
+}
+
+// securityCategories are the categories of the values whose type is security
+// relevant.
+var securityCategories = map[reflect.Type]string{
+	reflect.TypeOf(v1alpha1.ImageRef{}):           "image",
+	reflect.TypeOf(v1alpha1.NetworkPolicy{}):      "network-policy",
+	reflect.TypeOf(v1alpha1.ServiceAccountSpec{}): "service-account",
+	reflect.TypeOf(v1alpha1.TLSConfig{}):          "tls",
+	reflect.TypeOf(v1alpha1.ServingCerts{}):       "tls",
+	reflect.TypeOf(core.SecurityContext{}):        "security-context",
+	reflect.TypeOf(core.PodSecurityContext{}):     "security-context",
+}
+
+// imageFields are the fields selecting the images outside of ImageRef.
+var imageFields = []string{"RegistryFQDN", "ImagePullPolicy", "ImagePullSecrets"}
+
+// Diff compares two values files of the component field by field. The values
+// are compared once decoded into the values type, so that e.g. a field set
+// to its zero value is the same as a missing field. Fields moved to another
+// key with the same value are reported as renamed, and the fields not in the
+// values type are reported as unknown.
+func Diff(c Component, oldData, newData []byte) ([]Change, error) {
+	oldRoot, err := parseValues(c, oldData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid old values. Reason: %w", err)
+	}
+	newRoot, err := parseValues(c, newData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid new values. Reason: %w", err)
+	}
+
+	d := &differ{}
+	d.diff(oldRoot, newRoot, c.Spec, "", diffContext{})
+	return d.changes, nil
+}
+
+// parseValues parses a values file, making sure it decodes into the values
+// type. Unknown fields are allowed, as they are part of the diff.
+func parseValues(c Component, data []byte) (*yaml.Node, error) {
+	problems, err := Validate(c, data, true)
+	if err != nil {
+		return nil, err
+	}
+	var msgs []string
+	for _, p := range problems {
+		if !p.unknown {
+			msgs = append(msgs, "line "+p.String())
+		}
+	}
+	if len(msgs) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(msgs, "; "))
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	return valueNode(doc.Content[0]), nil
+}
+
+type differ struct {
+	changes []Change
+}
+
+// diffContext is inherited from the enclosing values.
+type diffContext struct {
+	category  string
+	sensitive bool
+	unknown   bool
+}
+
+// diff compares two values of the type. Either of the values is nil if
+// missing.
+func (d *differ) diff(a, b *yaml.Node, t reflect.Type, path string, ctx diffContext) {
+	a, b = valueNode(a), valueNode(b)
+	if a == nil && b == nil {
+		return
+	}
+	if t != nil {
+		t = indirect(t)
+		if cat, ok := securityCategories[t]; ok {
+			ctx.category = cat
+		}
+	}
+
+	switch {
+	case t == nil || t.Kind() == reflect.Interface:
+		d.compare(a, b, nil, path, ctx)
+	case customJSON(t):
+		d.compare(a, b, t, path, ctx)
+	case t.Kind() == reflect.Struct:
+		fields := map[string]field{}
+		for _, f := range structFields(t) {
+			fields[f.Name] = f
+		}
+		d.diffMappings(a, b, path, ctx, false, func(key string) (reflect.Type, diffContext) {
+			f, ok := fields[key]
+			if !ok {
+				return nil, diffContext{category: ctx.category, sensitive: ctx.sensitive, unknown: true}
+			}
+			fctx := ctx
+			if f.Owner == reflect.TypeOf(v1alpha1.ImageRef{}) || f.Owner.PkgPath() == apiPkgPath && slices.Contains(imageFields, f.GoName) {
+				fctx.category = "image"
+			}
+			fctx.sensitive = ctx.sensitive || sensitiveField(f)
+			if fctx.sensitive && fctx.category == "" {
+				fctx.category = "credentials"
+			}
+			return f.Type, fctx
+		})
+	case t.Kind() == reflect.Map:
+		d.diffMappings(a, b, path, ctx, true, func(string) (reflect.Type, diffContext) {
+			return t.Elem(), ctx
+		})
+	case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() != reflect.Uint8:
+		var as, bs []*yaml.Node
+		if a != nil {
+			as = a.Content
+		}
+		if b != nil {
+			bs = b.Content
+		}
+		for i := 0; i < max(len(as), len(bs)); i++ {
+			var x, y *yaml.Node
+			if i < len(as) {
+				x = as[i]
+			}
+			if i < len(bs) {
+				y = bs[i]
+			}
+			p := fmt.Sprintf("%s[%d]", path, i)
+			if x == nil || y == nil {
+				// list items are added or removed as a whole
+				d.compare(x, y, t.Elem(), p, ctx)
+			} else {
+				d.diff(x, y, t.Elem(), p, ctx)
+			}
+		}
+	default:
+		d.compare(a, b, t, path, ctx)
+	}
+}
+
+// diffMappings compares the keys of two mappings. fieldOf returns the type of
+// the value of a key, or nil for unknown fields. Any key can be renamed in
+// maps, while only the fields unknown to the values type can be renamed in
+// structs.
+func (d *differ) diffMappings(a, b *yaml.Node, path string, ctx diffContext, isMap bool, fieldOf func(key string) (reflect.Type, diffContext)) {
+	as, bs := mappingValues(a), mappingValues(b)
+
+	// a key missing from one side is matched to a key missing from the other
+	// side holding the same value
+	renamed := map[string]bool{}
+	for _, bk := range bs.keys {
+		if _, ok := as.values[bk]; ok {
+			continue
+		}
+		bv := plainValue(bs.values[bk])
+		if isEmpty(bv) {
+			continue
+		}
+		for _, ak := range as.keys {
+			if _, ok := bs.values[ak]; ok || renamed[ak] {
+				continue
+			}
+			if t, _ := fieldOf(ak); t != nil && !isMap {
+				continue
+			}
+			if reflect.DeepEqual(plainValue(as.values[ak]), bv) {
+				renamed[ak], renamed[bk] = true, true
+				_, actx := fieldOf(ak)
+				t, bctx := fieldOf(bk)
+				c := Change{
+					Path:     joinPath(path, bk),
+					Kind:     ChangeRenamed,
+					From:     joinPath(path, ak),
+					New:      bv,
+					OldLine:  as.values[ak].Line,
+					NewLine:  bs.values[bk].Line,
+					Category: firstNonEmpty(bctx.category, actx.category),
+					Unknown:  t == nil,
+				}
+				if bctx.sensitive || actx.sensitive {
+					c.New, c.Redacted = nil, true
+				}
+				d.changes = append(d.changes, c)
+				break
+			}
+		}
+	}
+
+	keys := append([]string(nil), as.keys...)
+	for _, k := range bs.keys {
+		if _, ok := as.values[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	for _, k := range keys {
+		if renamed[k] {
+			continue
+		}
+		t, fctx := fieldOf(k)
+		d.diff(as.values[k], bs.values[k], t, joinPath(path, k), fctx)
+	}
+}
+
+// compare compares two values as a whole.
+func (d *differ) compare(a, b *yaml.Node, t reflect.Type, path string, ctx diffContext) {
+	av, bv := typedValue(a, t), typedValue(b, t)
+	if reflect.DeepEqual(av, bv) {
+		return
+	}
+	c := Change{
+		Path:     path,
+		Kind:     ChangeChanged,
+		Old:      av,
+		New:      bv,
+		Category: ctx.category,
+		Unknown:  ctx.unknown,
+	}
+	if a != nil {
+		c.OldLine = a.Line
+	}
+	if b != nil {
+		c.NewLine = b.Line
+	}
+	switch {
+	case a == nil || isEmpty(av) && !isEmpty(bv):
+		c.Kind = ChangeAdded
+	case b == nil || isEmpty(bv) && !isEmpty(av):
+		c.Kind = ChangeRemoved
+	}
+	if ctx.sensitive {
+		c.Old, c.New, c.Redacted = nil, nil, true
+	}
+	d.changes = append(d.changes, c)
+}
+
+// typedValue returns a value as decoded into its type and encoded back, i.e.
+// with 1.0 and 1 being the same integer. A missing value is the zero value of
+// the type.
+func typedValue(n *yaml.Node, t reflect.Type) any {
+	v := plainValue(n)
+	if t == nil {
+		return v
+	}
+	t = indirect(t)
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	typed := reflect.New(t)
+	if v != nil {
+		if err := json.Unmarshal(data, typed.Interface()); err != nil {
+			return v
+		}
+	}
+	if data, err = json.Marshal(typed.Interface()); err != nil {
+		return v
+	}
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+// plainValue decodes a value, resolving the booleans as Helm does.
+func plainValue(n *yaml.Node) any {
+	if n == nil {
+		return nil
+	}
+	if n.Kind == yaml.ScalarNode && scalarTag(n) == "!!bool" && n.ShortTag() == "!!str" {
+		return slices.Contains([]string{"y", "Y", "yes", "Yes", "YES", "on", "On", "ON"}, n.Value)
+	}
+	var v any
+	if err := n.Decode(&v); err != nil {
+		return n.Value
+	}
+	return v
+}
+
+// valueNode resolves aliases, returning nil for null values.
+func valueNode(n *yaml.Node) *yaml.Node {
+	if n == nil {
+		return nil
+	}
+	n = resolveAlias(n)
+	if isNull(n) {
+		return nil
+	}
+	return n
+}
+
+type mapping struct {
+	keys   []string
+	values map[string]*yaml.Node
+}
+
+func mappingValues(n *yaml.Node) mapping {
+	m := mapping{values: map[string]*yaml.Node{}}
+	if n == nil || n.Kind != yaml.MappingNode {
+		return m
+	}
+	for _, kv := range mappingPairs(n) {
+		if _, ok := m.values[kv[0].Value]; ok {
+			continue
+		}
+		m.keys = append(m.keys, kv[0].Value)
+		m.values[kv[0].Value] = kv[1]
+	}
+	return m
+}
+
+// sensitiveField reports whether the field holds a secret, whose values are
+// left out of the diff.
+func sensitiveField(f field) bool {
+	switch f.GoName {
+	case "Password", "Token", "BearerToken", "ServerKey":
+		return true
+	case "Key":
+		return f.Owner == reflect.TypeOf(v1alpha1.TLSConfig{})
+	}
+	return false
+}
+
+func isEmpty(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case map[string]any:
+		return len(v) == 0
+	case []any:
+		return len(v) == 0
+	}
+	return false
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
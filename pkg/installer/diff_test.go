@@ -0,0 +1,82 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package installer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	c := mustFindComponent(t, "kube-ui-server")
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want []Change
+	}{
+		{
+			name: "unchanged",
+			old:  "replicaCount: 1\nnameOverride: \"\"\n",
+			new:  "replicaCount: 1.0\n",
+		},
+		{
+			name: "renamed",
+			old:  "podAnnotations:\n  old-key: v1\n",
+			new:  "podAnnotations:\n  new-key: v1\n",
+			want: []Change{{
+				Path:    "podAnnotations.new-key",
+				Kind:    ChangeRenamed,
+				From:    "podAnnotations.old-key",
+				New:     "v1",
+				OldLine: 2,
+				NewLine: 2,
+			}},
+		},
+		{
+			name: "redacted",
+			old:  "platform:\n  token: secret1\n",
+			new:  "platform:\n  token: secret2\n",
+			want: []Change{{
+				Path:     "platform.token",
+				Kind:     ChangeChanged,
+				OldLine:  2,
+				NewLine:  2,
+				Category: "credentials",
+				Redacted: true,
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Diff(c, []byte(tt.old), []byte(tt.new))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffInvalidValues(t *testing.T) {
+	c := mustFindComponent(t, "kube-ui-server")
+	if _, err := Diff(c, []byte("replicaCount: x\n"), nil); err == nil {
+		t.Error("Diff() returned no error for invalid values")
+	}
+}
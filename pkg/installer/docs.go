@@ -0,0 +1,36 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package installer
+
+//go:generate go run ../../hack/gendocs -dir ../../vendor/kubeops.dev/installer/apis/installer/v1alpha1 -o zz_generated.docs.go -header ../../hack/license/go.txt
+
+// typeDoc holds the documentation and the kubebuilder markers of an installer
+// API type, which are only available in its sources.
+type typeDoc struct {
+	Doc     string
+	Enum    []string
+	Default string
+	// Fields are keyed by the Go name of the fields
+	Fields map[string]fieldDoc
+}
+
+type fieldDoc struct {
+	Doc      string
+	Required bool
+	Default  string
+	Enum     []string
+}
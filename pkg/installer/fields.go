@@ -0,0 +1,157 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package installer
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"kubeops.dev/installer/apis/installer/v1alpha1"
+)
+
+var (
+	apiPkgPath          = reflect.TypeOf(v1alpha1.ImageRef{}).PkgPath()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// field is a field of a values struct as seen in the values file. The fields
+// of inlined structs are promoted to the struct embedding them.
+type field struct {
+	// Name is the key of the field in the values file
+	Name      string
+	GoName    string
+	Type      reflect.Type
+	Index     []int
+	OmitEmpty bool
+	// Owner is the struct declaring the field
+	Owner reflect.Type
+	Doc   fieldDoc
+}
+
+// structFields returns the fields of a struct in declaration order.
+func structFields(t reflect.Type) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		inline := strings.Contains(","+opts+",", ",inline,")
+		if sf.Anonymous && (name == "" || inline) {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for _, f := range structFields(ft) {
+					f.Index = append([]int{i}, f.Index...)
+					fields = append(fields, f)
+				}
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		f := field{
+			Name:      name,
+			GoName:    sf.Name,
+			Type:      sf.Type,
+			Index:     []int{i},
+			OmitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+			Owner:     t,
+		}
+		if td, ok := apiTypeDoc(t); ok {
+			f.Doc = td.Fields[sf.Name]
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// required reports whether the field has to be set in a complete values file.
+// Values files are merged over the values of the chart, so only the fields
+// marked as required have to be set, whatever their default.
+func (f field) required() bool {
+	return f.Owner.PkgPath() == apiPkgPath && f.Doc.Required
+}
+
+// defaultValue returns the default of the field given by the kubebuilder
+// markers, if any.
+func (f field) defaultValue() string {
+	if f.Doc.Default != "" {
+		return f.Doc.Default
+	}
+	if td, ok := apiTypeDoc(f.Type); ok {
+		return td.Default
+	}
+	return ""
+}
+
+// enum returns the values allowed for the field, if restricted.
+func (f field) enum() []string {
+	if f.Doc.Enum != nil {
+		return f.Doc.Enum
+	}
+	return typeEnum(f.Type)
+}
+
+// description returns the documentation of the field, falling back to the
+// documentation of its type.
+func (f field) description() string {
+	if f.Doc.Doc != "" {
+		return f.Doc.Doc
+	}
+	if td, ok := apiTypeDoc(indirect(f.Type)); ok {
+		return td.Doc
+	}
+	return ""
+}
+
+func typeEnum(t reflect.Type) []string {
+	if td, ok := apiTypeDoc(t); ok {
+		return td.Enum
+	}
+	return nil
+}
+
+func apiTypeDoc(t reflect.Type) (typeDoc, bool) {
+	if t.PkgPath() != apiPkgPath {
+		return typeDoc{}, false
+	}
+	td, ok := typeDocs[t.Name()]
+	return td, ok
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// customJSON reports whether values of the type are decoded by their own
+// json.Unmarshaler (i.e. resource.Quantity or intstr.IntOrString).
+func customJSON(t reflect.Type) bool {
+	return t.Implements(jsonUnmarshalerType) || reflect.PointerTo(t).Implements(jsonUnmarshalerType)
+}
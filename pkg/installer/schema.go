@@ -0,0 +1,285 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package installer
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const schemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema is a JSON Schema (draft 2020-12) of a values file.
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 any                    `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	ContentEncoding      string                 `json:"contentEncoding,omitempty"`
+	Enum                 []any                  `json:"enum,omitempty"`
+	Default              any                    `json:"default,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	Properties           *schemaProperties      `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties any                    `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	AnyOf                []*JSONSchema          `json:"anyOf,omitempty"`
+	Defs                 map[string]*JSONSchema `json:"$defs,omitempty"`
+}
+
+// schemaProperties are the properties of an object schema, kept in the order
+// of the fields.
+type schemaProperties struct {
+	names   []string
+	schemas map[string]*JSONSchema
+}
+
+func (p *schemaProperties) add(name string, s *JSONSchema) {
+	if p.schemas == nil {
+		p.schemas = map[string]*JSONSchema{}
+	}
+	p.names = append(p.names, name)
+	p.schemas[name] = s
+}
+
+func (p *schemaProperties) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, name := range p.names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		val, err := json.Marshal(p.schemas[name])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// knownSchemas are the schemas of the types decoding themselves from JSON.
+var knownSchemas = map[reflect.Type]func() *JSONSchema{
+	reflect.TypeOf(resource.Quantity{}): func() *JSONSchema {
+		return &JSONSchema{AnyOf: []*JSONSchema{
+			{Type: "integer"},
+			{Type: "string", Pattern: `^(\+|-)?(([0-9]+(\.[0-9]*)?)|(\.[0-9]+))(([KMGTPE]i)|[numkMGTPE]|([eE](\+|-)?(([0-9]+(\.[0-9]*)?)|(\.[0-9]+))))?$`},
+		}}
+	},
+	reflect.TypeOf(intstr.IntOrString{}): func() *JSONSchema {
+		return &JSONSchema{AnyOf: []*JSONSchema{{Type: "integer"}, {Type: "string"}}}
+	},
+	reflect.TypeOf(metav1.Duration{}): func() *JSONSchema {
+		return &JSONSchema{Type: "string"}
+	},
+	reflect.TypeOf(metav1.Time{}): func() *JSONSchema {
+		return &JSONSchema{Type: "string", Format: "date-time"}
+	},
+}
+
+// GenerateSchema returns the JSON Schema of the values of the component. The
+// structs other than the values struct itself are defined in $defs.
+func GenerateSchema(c Component) *JSONSchema {
+	g := &schemaGenerator{defs: map[string]*JSONSchema{}}
+	s := g.structSchema(c.Spec)
+	s.Schema = schemaDialect
+	s.Title = c.Name + " values"
+	if len(g.defs) > 0 {
+		s.Defs = g.defs
+	}
+	return s
+}
+
+// YAML returns the schema in YAML, keeping the order of the keys.
+func (s *JSONSchema) YAML() ([]byte, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	var n yaml.Node
+	if err := yaml.Unmarshal(data, &n); err != nil {
+		return nil, err
+	}
+	plainStyle(&n)
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&n); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// plainStyle drops the JSON quoting and flow style, leaving it to the encoder
+// to quote the strings that need it.
+func plainStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		plainStyle(c)
+	}
+}
+
+type schemaGenerator struct {
+	defs map[string]*JSONSchema
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) *JSONSchema {
+	if t.Kind() == reflect.Pointer {
+		return nullable(g.schema(indirect(t)))
+	}
+	if fn, ok := knownSchemas[t]; ok {
+		return fn()
+	}
+	if customJSON(t) {
+		return &JSONSchema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		name := defName(t)
+		if _, ok := g.defs[name]; !ok {
+			// reserve the name first, in case the type refers to itself
+			g.defs[name] = nil
+			g.defs[name] = g.structSchema(t)
+		}
+		return &JSONSchema{Ref: "#/$defs/" + name}
+	case reflect.Map:
+		return nullable(&JSONSchema{Type: "object", AdditionalProperties: g.schema(t.Elem())})
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &JSONSchema{Type: "string", ContentEncoding: "base64"}
+		}
+		return nullable(&JSONSchema{Type: "array", Items: g.schema(t.Elem())})
+	case reflect.String:
+		return &JSONSchema{Type: "string", Enum: enumValues(typeEnum(t))}
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint64, reflect.Uint:
+		s := &JSONSchema{Type: "integer"}
+		if t.Kind() == reflect.Uint || t.Kind() == reflect.Uint64 {
+			s.Minimum = ptr(0)
+		}
+		return s
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		bits := t.Bits()
+		return &JSONSchema{Type: "integer", Minimum: ptr(-math.Pow(2, float64(bits-1))), Maximum: ptr(math.Pow(2, float64(bits-1)) - 1)}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &JSONSchema{Type: "integer", Minimum: ptr(0), Maximum: ptr(math.Pow(2, float64(t.Bits())) - 1)}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	default:
+		return &JSONSchema{}
+	}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) *JSONSchema {
+	s := &JSONSchema{
+		Type:                 "object",
+		Properties:           &schemaProperties{},
+		AdditionalProperties: false,
+	}
+	if td, ok := apiTypeDoc(t); ok {
+		s.Description = td.Doc
+	}
+	for _, f := range structFields(t) {
+		fs := g.schema(f.Type)
+		// the fields share the schema of their type, so the field specific
+		// attributes are set on a copy
+		if fs.Ref == "" {
+			c := *fs
+			fs = &c
+		} else {
+			fs = &JSONSchema{Ref: fs.Ref}
+		}
+		fs.Description = f.description()
+		if enum := f.enum(); enum != nil {
+			fs.Enum = enumValues(enum)
+		}
+		if def := f.defaultValue(); def != "" {
+			if n, err := parseValue(f.Type, def); err == nil {
+				var v any
+				if n.Decode(&v) == nil {
+					fs.Default = v
+				}
+			}
+		}
+		s.Properties.add(f.Name, fs)
+		if f.required() {
+			s.Required = append(s.Required, f.Name)
+		}
+	}
+	return s
+}
+
+// enumValues returns the values of an enum as allowed in values files, where
+// an empty string leaves the enum unset.
+func enumValues(enum []string) []any {
+	if enum == nil {
+		return nil
+	}
+	values := []any{""}
+	for _, v := range enum {
+		values = append(values, v)
+	}
+	return values
+}
+
+// nullable allows null in place of the value, as for the pointers, maps and
+// slices left unset.
+func nullable(s *JSONSchema) *JSONSchema {
+	if typ, ok := s.Type.(string); ok {
+		s.Type = []string{typ, "null"}
+		return s
+	}
+	return &JSONSchema{AnyOf: []*JSONSchema{s, {Type: "null"}}}
+}
+
+// defName returns the name a struct is defined with in $defs. The types of
+// the installer API are referred to by their name, the others by their Go
+// package path.
+func defName(t reflect.Type) string {
+	if t.PkgPath() == apiPkgPath {
+		return t.Name()
+	}
+	return strings.ReplaceAll(t.PkgPath(), "/", ".") + "." + t.Name()
+}
+
+func ptr(f float64) *float64 {
+	return &f
+}
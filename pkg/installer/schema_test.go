@@ -0,0 +1,131 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package installer
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestGenerateSchema(t *testing.T) {
+	c := mustFindComponent(t, "kube-ui-server")
+	data, err := json.Marshal(GenerateSchema(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s map[string]any
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatal(err)
+	}
+	defs := s["$defs"].(map[string]any)
+	props := s["properties"].(map[string]any)
+
+	tests := []struct {
+		name string
+		got  any
+		want string
+	}{
+		{
+			name: "int32",
+			got:  props["replicaCount"],
+			want: `{"maximum":2147483647,"minimum":-2147483648,"type":"integer"}`,
+		},
+		{
+			name: "map",
+			got:  props["annotations"],
+			want: `{"additionalProperties":{"type":"string"},"type":["object","null"]}`,
+		},
+		{
+			name: "pointer to struct",
+			got:  props["affinity"],
+			want: `{"anyOf":[{"$ref":"#/$defs/k8s.io.api.core.v1.Affinity"},{"type":"null"}],"description":"If specified, the pod's scheduling constraints"}`,
+		},
+		{
+			name: "enum",
+			got:  defs["Monitoring"].(map[string]any)["properties"].(map[string]any)["agent"],
+			want: `{"enum":["","prometheus.io","prometheus.io/operator","prometheus.io/builtin"],"type":"string"}`,
+		},
+		{
+			name: "quantity",
+			got:  defs["k8s.io.api.core.v1.ResourceRequirements"].(map[string]any)["properties"].(map[string]any)["limits"].(map[string]any)["additionalProperties"].(map[string]any)["anyOf"].([]any)[0],
+			want: `{"type":"integer"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("schema = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, ok := s["required"]; ok {
+		t.Errorf("required = %v, want none", s["required"])
+	}
+
+	// every reference is defined
+	for _, ref := range strings.Split(string(data), `"$ref":"`)[1:] {
+		name := strings.TrimPrefix(ref[:strings.IndexByte(ref, '"')], "#/$defs/")
+		if _, ok := defs[name]; !ok {
+			t.Errorf("$ref to undefined %s", name)
+		}
+	}
+}
+
+func TestGenerateSchemaRequired(t *testing.T) {
+	c := mustFindComponent(t, "kube-ui-server")
+	markRequired(t, "KubeUiServerSpec.Image", "KubeUiServerSpec.ReplicaCount")
+	s := GenerateSchema(c)
+	if want := []string{"replicaCount", "image"}; !reflect.DeepEqual(s.Required, want) {
+		t.Errorf("required = %v, want %v", s.Required, want)
+	}
+}
+
+func TestSchemaYAML(t *testing.T) {
+	c := mustFindComponent(t, "kube-ui-server")
+	s := GenerateSchema(c)
+	data, err := s.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "$schema: "+schemaDialect+"\ntitle: kube-ui-server values\n") {
+		t.Errorf("YAML() starts with %q", strings.SplitN(string(data), "\n", 3)[:2])
+	}
+
+	var fromYAML, fromJSON any
+	if err := yaml.Unmarshal(data, &fromYAML); err != nil {
+		t.Fatal(err)
+	}
+	js, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal(js, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromYAML, fromJSON) {
+		t.Error("YAML() differs from the JSON schema")
+	}
+}
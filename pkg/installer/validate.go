@@ -0,0 +1,394 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is an issue found in a values file.
+type Problem struct {
+	Line int `json:"line"`
+	// Path is the path of the value in the values file (i.e. image.tag or
+	// tolerations[0].key)
+	Path    string `json:"path"`
+	Message string `json:"message"`
+	// unknown is set for the fields not in the values type
+	unknown bool
+}
+
+func (p Problem) String() string {
+	if p.Path == "" {
+		return fmt.Sprintf("%d: %s", p.Line, p.Message)
+	}
+	return fmt.Sprintf("%d: %s: %s", p.Line, p.Path, p.Message)
+}
+
+// Validate checks a values file against the values type of the component. It
+// reports unknown fields, values of the wrong type, values not allowed by an
+// enum and, unless partial is set, missing required fields. An error is
+// returned only if the file is not valid YAML.
+func Validate(c Component, data []byte, partial bool) ([]Problem, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: 1}
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		root = doc.Content[0]
+	}
+
+	v := &validator{partial: partial}
+	v.check(root, c.Spec, "", nil)
+	slices.SortStableFunc(v.problems, func(a, b Problem) int {
+		return a.Line - b.Line
+	})
+	return v.problems, nil
+}
+
+type validator struct {
+	partial  bool
+	problems []Problem
+}
+
+func (v *validator) report(n *yaml.Node, path, format string, args ...any) {
+	v.problems = append(v.problems, Problem{
+		Line:    n.Line,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// check validates the node against the type. enum restricts the allowed
+// values of string fields.
+func (v *validator) check(n *yaml.Node, t reflect.Type, path string, enum []string) {
+	n = resolveAlias(n)
+	if isNull(n) {
+		return
+	}
+	t = indirect(t)
+
+	if customJSON(t) {
+		v.checkCustom(n, t, path)
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if v.expectKind(n, yaml.MappingNode, t, path) {
+			v.checkStruct(n, t, path)
+		}
+	case reflect.Map:
+		if !v.expectKind(n, yaml.MappingNode, t, path) {
+			return
+		}
+		seen := map[string]bool{}
+		for _, kv := range mappingPairs(n) {
+			key, val := kv[0], kv[1]
+			if seen[key.Value] {
+				v.report(key, joinPath(path, key.Value), "duplicate key")
+				continue
+			}
+			seen[key.Value] = true
+			v.check(val, t.Elem(), joinPath(path, key.Value), typeEnum(t.Elem()))
+		}
+	case reflect.Slice, reflect.Array:
+		// byte slices are base64 encoded strings
+		if t.Elem().Kind() == reflect.Uint8 {
+			v.checkScalar(n, t, path, "!!str")
+			return
+		}
+		if !v.expectKind(n, yaml.SequenceNode, t, path) {
+			return
+		}
+		for i, item := range n.Content {
+			v.check(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), typeEnum(t.Elem()))
+		}
+	case reflect.String:
+		// an empty string leaves the enum unset, as in monitoring.agent
+		if v.checkScalar(n, t, path, "!!str", "!!timestamp", "!!binary") && enum != nil && n.Value != "" && !slices.Contains(enum, n.Value) {
+			v.report(n, path, "invalid value %q, must be one of %s", n.Value, strings.Join(enum, ", "))
+		}
+	case reflect.Bool:
+		v.checkScalar(n, t, path, "!!bool")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.checkScalar(n, t, path, "!!int", "!!float") {
+			v.checkInteger(n, t, path)
+		}
+	case reflect.Float32, reflect.Float64:
+		if v.checkScalar(n, t, path, "!!int", "!!float") && t.Kind() == reflect.Float32 {
+			var f float64
+			if err := n.Decode(&f); err == nil && math.Abs(f) > math.MaxFloat32 {
+				v.report(n, path, "%s overflows %s", n.Value, t.Kind())
+			}
+		}
+	case reflect.Interface:
+		// any value is accepted
+	}
+}
+
+// checkInteger checks the range of an integer. Numbers without a fractional
+// part are accepted too, as Helm decodes the values from JSON.
+func (v *validator) checkInteger(n *yaml.Node, t reflect.Type, path string) {
+	i := new(big.Int)
+	if scalarTag(n) == "!!float" {
+		var f float64
+		if err := n.Decode(&f); err != nil || math.IsInf(f, 0) || f != math.Trunc(f) {
+			v.report(n, path, "expected %s, got number", typeName(t))
+			return
+		}
+		big.NewFloat(f).Int(i)
+	} else {
+		var i64 int64
+		var u64 uint64
+		switch {
+		case n.Decode(&i64) == nil:
+			i.SetInt64(i64)
+		case n.Decode(&u64) == nil:
+			i.SetUint64(u64)
+		default:
+			v.report(n, path, "%s overflows %s", n.Value, t.Kind())
+			return
+		}
+	}
+
+	bits := uint(t.Bits())
+	lo, hi := new(big.Int), new(big.Int).Lsh(big.NewInt(1), bits)
+	if t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64 {
+		hi.Rsh(hi, 1)
+		lo.Neg(hi)
+	}
+	hi.Sub(hi, big.NewInt(1))
+	if i.Cmp(lo) < 0 || i.Cmp(hi) > 0 {
+		v.report(n, path, "%s is out of range for %s", n.Value, t.Kind())
+	}
+}
+
+func (v *validator) checkStruct(n *yaml.Node, t reflect.Type, path string) {
+	fields := structFields(t)
+	byName := make(map[string]field, len(fields))
+	for _, f := range fields {
+		byName[f.Name] = f
+	}
+
+	seen := map[string]bool{}
+	for _, kv := range mappingPairs(n) {
+		key, val := kv[0], kv[1]
+		p := joinPath(path, key.Value)
+		if seen[key.Value] {
+			v.report(key, p, "duplicate key")
+			continue
+		}
+		seen[key.Value] = true
+
+		f, ok := byName[key.Value]
+		if !ok {
+			if hint := closestField(fields, key.Value); hint != "" {
+				v.report(key, p, "unknown field, did you mean %q?", hint)
+			} else {
+				v.report(key, p, "unknown field")
+			}
+			v.problems[len(v.problems)-1].unknown = true
+			continue
+		}
+		v.check(val, f.Type, p, f.enum())
+	}
+
+	if v.partial {
+		return
+	}
+	for _, f := range fields {
+		if !seen[f.Name] && f.required() {
+			v.report(n, joinPath(path, f.Name), "missing required field")
+		}
+	}
+}
+
+// checkCustom validates values of the types decoding themselves from JSON by
+// decoding them the same way.
+func (v *validator) checkCustom(n *yaml.Node, t reflect.Type, path string) {
+	var val any
+	if err := n.Decode(&val); err != nil {
+		v.report(n, path, "%v", err)
+		return
+	}
+	data, err := json.Marshal(val)
+	if err != nil {
+		v.report(n, path, "%v", err)
+		return
+	}
+	if err := json.Unmarshal(data, reflect.New(t).Interface()); err != nil {
+		v.report(n, path, "invalid %s: %v", t.Name(), err)
+	}
+}
+
+func (v *validator) expectKind(n *yaml.Node, kind yaml.Kind, t reflect.Type, path string) bool {
+	if n.Kind == kind {
+		return true
+	}
+	v.report(n, path, "expected %s, got %s", typeName(t), nodeTypeName(n))
+	return false
+}
+
+func (v *validator) checkScalar(n *yaml.Node, t reflect.Type, path string, tags ...string) bool {
+	if n.Kind == yaml.ScalarNode && slices.Contains(tags, scalarTag(n)) {
+		return true
+	}
+	v.report(n, path, "expected %s, got %s", typeName(t), nodeTypeName(n))
+	return false
+}
+
+// mappingPairs returns the key and value nodes of a mapping, with the merge
+// keys expanded. Keys set in the mapping take precedence over merged keys.
+func mappingPairs(n *yaml.Node) [][2]*yaml.Node {
+	var pairs, merged [][2]*yaml.Node
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, val := n.Content[i], n.Content[i+1]
+		if key.ShortTag() != "!!merge" {
+			pairs = append(pairs, [2]*yaml.Node{key, val})
+			continue
+		}
+		val = resolveAlias(val)
+		sources := []*yaml.Node{val}
+		if val.Kind == yaml.SequenceNode {
+			sources = val.Content
+		}
+		for _, src := range sources {
+			if src = resolveAlias(src); src.Kind == yaml.MappingNode {
+				merged = append(merged, mappingPairs(src)...)
+			}
+		}
+	}
+
+	keys := map[string]bool{}
+	for _, kv := range pairs {
+		keys[kv[0].Value] = true
+	}
+	for _, kv := range merged {
+		if !keys[kv[0].Value] {
+			keys[kv[0].Value] = true
+			pairs = append(pairs, kv)
+		}
+	}
+	return pairs
+}
+
+func resolveAlias(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	return n
+}
+
+// yaml11Bools are the booleans of YAML 1.1 that are strings in YAML 1.2. Helm
+// parses values files as YAML 1.1.
+var yaml11Bools = []string{"y", "Y", "yes", "Yes", "YES", "n", "N", "no", "No", "NO", "on", "On", "ON", "off", "Off", "OFF"}
+
+// scalarTag returns the tag of a scalar as resolved by Helm.
+func scalarTag(n *yaml.Node) string {
+	tag := n.ShortTag()
+	if tag == "!!str" && n.Style&(yaml.TaggedStyle|yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 &&
+		slices.Contains(yaml11Bools, n.Value) {
+		return "!!bool"
+	}
+	return tag
+}
+
+func isNull(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && n.ShortTag() == "!!null"
+}
+
+// closestField returns the field matching the key but for its case, as the
+// keys of values files are case sensitive.
+func closestField(fields []field, key string) string {
+	for _, f := range fields {
+		if strings.EqualFold(f.Name, key) {
+			return f.Name
+		}
+	}
+	return ""
+}
+
+var identPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// joinPath appends a key to a path. Keys that aren't plain identifiers are
+// quoted, as in the keys of annotations.
+func joinPath(path, key string) string {
+	if !identPattern.MatchString(key) {
+		return fmt.Sprintf("%s[%q]", path, key)
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func typeName(t reflect.Type) string {
+	switch indirect(t).Kind() {
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		if indirect(t).Elem().Kind() == reflect.Uint8 {
+			return "base64 encoded string"
+		}
+		return "list"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	default:
+		return t.String()
+	}
+}
+
+func nodeTypeName(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "list"
+	}
+	switch scalarTag(n) {
+	case "!!str":
+		return "string"
+	case "!!bool":
+		return "boolean"
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	case "!!timestamp":
+		return "timestamp"
+	default:
+		return scalarTag(n)
+	}
+}
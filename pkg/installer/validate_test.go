@@ -0,0 +1,177 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package installer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mustFindComponent(t *testing.T, name string) Component {
+	t.Helper()
+	c, err := FindComponent(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// markRequired marks fields, given as <type>.<field>, as required until the
+// end of the test.
+func markRequired(t *testing.T, fields ...string) {
+	t.Helper()
+	for _, name := range fields {
+		typ, fieldName, _ := strings.Cut(name, ".")
+		td, ok := typeDocs[typ]
+		if !ok {
+			t.Fatalf("unknown type %s", typ)
+		}
+		fd := td.Fields[fieldName]
+		fd.Required = true
+		td.Fields[fieldName] = fd
+		t.Cleanup(func() {
+			fd.Required = false
+			td.Fields[fieldName] = fd
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	c := mustFindComponent(t, "kube-ui-server")
+	tests := []struct {
+		name     string
+		data     string
+		partial  bool
+		required []string
+		want     []string
+	}{
+		{
+			name:    "yaml 1.1 bool",
+			data:    "serviceAccount:\n  create: yes\ncriticalAddon: off\n",
+			partial: true,
+		},
+		{
+			name:    "unquoted bool for a string",
+			data:    "nameOverride: on\n",
+			partial: true,
+			want:    []string{"1: nameOverride: expected string, got boolean"},
+		},
+		{
+			name:    "aliases",
+			data:    "annotations: &a\n  x: \"1\"\npodAnnotations: *a\nnodeSelector: *a\n",
+			partial: true,
+		},
+		{
+			name: "merge keys",
+			data: "tolerations:\n- &t\n  key: a\n  operator: Exists\n- <<: *t\n  effect: NoSchedule\n" +
+				"- <<: *t\n  effect: 5\n- <<: *t\n  bogus: x\n",
+			partial: true,
+			want: []string{
+				"8: tolerations[2].effect: expected string, got integer",
+				"10: tolerations[3].bogus: unknown field",
+			},
+		},
+		{
+			name: "no required fields",
+			data: "replicaCount: 1\n",
+		},
+		{
+			name:     "required fields",
+			data:     "replicaCount: 1\nmonitoring: {}\n",
+			required: []string{"KubeUiServerSpec.Image", "Monitoring.Agent"},
+			want: []string{
+				"1: image: missing required field",
+				"2: monitoring.agent: missing required field",
+			},
+		},
+		{
+			name:     "partial",
+			data:     "replicaCount: 1\nmonitoring: {}\n",
+			partial:  true,
+			required: []string{"KubeUiServerSpec.Image", "Monitoring.Agent"},
+		},
+		{
+			name:    "enum",
+			data:    "monitoring:\n  agent: prometheus.io/x\n",
+			partial: true,
+			want:    []string{`2: monitoring.agent: invalid value "prometheus.io/x", must be one of prometheus.io, prometheus.io/operator, prometheus.io/builtin`},
+		},
+		{
+			name:    "empty enum",
+			data:    "monitoring:\n  agent: \"\"\n",
+			partial: true,
+		},
+		{
+			name:    "duplicate keys",
+			data:    "replicaCount: 2\nannotations:\n  a: x\n  a: y\nreplicaCount: 3\n",
+			partial: true,
+			want: []string{
+				"4: annotations.a: duplicate key",
+				"5: replicaCount: duplicate key",
+			},
+		},
+		{
+			name:    "unknown field",
+			data:    "Tolerations: []\nfoo: bar\n",
+			partial: true,
+			want: []string{
+				`1: Tolerations: unknown field, did you mean "tolerations"?`,
+				"2: foo: unknown field",
+			},
+		},
+		{
+			name:    "wrong types",
+			data:    "replicaCount: \"2\"\nimage:\n  tag: 1.5\nannotations:\n  app.kubernetes.io/name: 5\n",
+			partial: true,
+			want: []string{
+				"1: replicaCount: expected integer, got string",
+				"3: image.tag: expected string, got number",
+				`5: annotations["app.kubernetes.io/name"]: expected string, got integer`,
+			},
+		},
+		{
+			name:    "integers",
+			data:    "replicaCount: 1.0\nlogLevel: 1.5\n",
+			partial: true,
+			want:    []string{"2: logLevel: expected integer, got number"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			markRequired(t, tt.required...)
+			problems, err := Validate(c, []byte(tt.data), tt.partial)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, p := range problems {
+				got = append(got, p.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateInvalidYAML(t *testing.T) {
+	c := mustFindComponent(t, "kube-ui-server")
+	if _, err := Validate(c, []byte("a: [b\n"), false); err == nil {
+		t.Error("Validate() returned no error for invalid YAML")
+	}
+}
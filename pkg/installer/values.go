@@ -0,0 +1,477 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package installer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GenerateValues returns a values file of the component with every field
+// documented. The fields with a default are set to it, while the other fields
+// are left commented out with their zero value as a placeholder, so that the
+// chart's own defaults apply to them. Each of set is a '<path>=<value>'
+// override (i.e. image.tag=v0.0.1 or tolerations[0].key=foo), the value being
+// parsed according to the type of the field. When minimal is set, only the
+// fields differing from their default are kept.
+func GenerateValues(c Component, set []string, minimal bool) ([]byte, error) {
+	b := &valuesBuilder{comments: true, placeholders: map[*yaml.Node]bool{}}
+	root := b.defaultNode(c.Spec, nil)
+	for _, expr := range set {
+		if err := b.setValue(root, c.Spec, expr); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	if minimal {
+		def := &valuesBuilder{placeholders: map[*yaml.Node]bool{}}
+		pruneDefaults(root, def.defaultNode(c.Spec, nil))
+		if len(root.Content) == 0 {
+			return []byte("{}\n"), nil
+		}
+	} else {
+		fmt.Fprintf(&buf, "# Values of the %s chart (%s)\n", c.Name, c.Kind)
+		fmt.Fprintf(&buf, "# The fields without a default are commented out, so that the chart's own\n# defaults apply to them.\n\n")
+	}
+	if err := b.render(&buf, root, ""); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// valuesBuilder builds the values of a component. The zero values of the
+// fields without a default are placeholders.
+type valuesBuilder struct {
+	comments     bool
+	placeholders map[*yaml.Node]bool
+}
+
+func (b *valuesBuilder) placeholder(n *yaml.Node) *yaml.Node {
+	b.placeholders[n] = true
+	return n
+}
+
+// isPlaceholder reports whether a value is a placeholder. Objects are when all
+// their fields are.
+func (b *valuesBuilder) isPlaceholder(n *yaml.Node) bool {
+	if n.Kind == yaml.MappingNode && len(n.Content) > 0 {
+		for i := 1; i < len(n.Content); i += 2 {
+			if !b.isPlaceholder(n.Content[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return b.placeholders[n]
+}
+
+// defaultNode returns the default value of a type. The fields of the installer
+// API structs are expanded, while the other structs are left empty. f is the
+// field holding the value, if any.
+func (b *valuesBuilder) defaultNode(t reflect.Type, f *field) *yaml.Node {
+	if f != nil {
+		if def := f.defaultValue(); def != "" {
+			if n, err := parseValue(t, def); err == nil {
+				return n
+			}
+		}
+	}
+
+	if t.Kind() == reflect.Pointer {
+		if elem := indirect(t); elem.Kind() == reflect.Struct && elem.PkgPath() == apiPkgPath {
+			return b.defaultNode(elem, nil)
+		}
+		return b.placeholder(nullNode())
+	}
+	if customJSON(t) {
+		if data, err := json.Marshal(reflect.Zero(t).Interface()); err == nil {
+			if n, err := parseYAML(string(data)); err == nil {
+				return b.placeholder(n)
+			}
+		}
+		return b.placeholder(nullNode())
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		if t.PkgPath() != apiPkgPath {
+			return b.placeholder(n)
+		}
+		for _, sf := range structFields(t) {
+			key := scalarNode("!!str", sf.Name)
+			if b.comments {
+				key.HeadComment = fieldComment(sf)
+			}
+			n.Content = append(n.Content, key, b.defaultNode(sf.Type, &sf))
+		}
+		return n
+	case reflect.Map:
+		return b.placeholder(&yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"})
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return b.placeholder(scalarNode("!!str", ""))
+		}
+		return b.placeholder(&yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"})
+	case reflect.String:
+		return b.placeholder(scalarNode("!!str", ""))
+	case reflect.Bool:
+		return b.placeholder(scalarNode("!!bool", "false"))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return b.placeholder(scalarNode("!!int", "0"))
+	case reflect.Float32, reflect.Float64:
+		return b.placeholder(scalarNode("!!float", "0"))
+	default:
+		return b.placeholder(nullNode())
+	}
+}
+
+// render writes the fields of a mapping, each after its documentation. The
+// placeholders are commented out, along with the objects holding only
+// placeholders. indent prefixes every line, including the comment markers of
+// the enclosing objects.
+func (b *valuesBuilder) render(buf *bytes.Buffer, n *yaml.Node, indent string) error {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, val := n.Content[i], n.Content[i+1]
+		if key.HeadComment != "" {
+			for _, line := range strings.Split(key.HeadComment, "\n") {
+				buf.WriteString(strings.TrimRight(indent+line, " ") + "\n")
+			}
+		}
+		prefix := indent
+		if b.isPlaceholder(val) && !strings.Contains(indent, "#") {
+			prefix += "# "
+		}
+
+		if val.Kind == yaml.MappingNode && len(val.Content) > 0 {
+			k, err := encodeNode(&yaml.Node{Kind: key.Kind, Tag: key.Tag, Value: key.Value, Style: key.Style})
+			if err != nil {
+				return err
+			}
+			buf.WriteString(prefix + strings.TrimSpace(k) + ":\n")
+			if err := b.render(buf, val, prefix+"  "); err != nil {
+				return err
+			}
+			continue
+		}
+
+		pair := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+			{Kind: key.Kind, Tag: key.Tag, Value: key.Value, Style: key.Style},
+			b.withoutPlaceholders(val),
+		}}
+		data, err := encodeNode(pair)
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(data, "\n"), "\n") {
+			buf.WriteString(prefix + line + "\n")
+		}
+	}
+	return nil
+}
+
+// withoutPlaceholders returns a copy of a value without the placeholder
+// fields, as for the items of lists set on the command line.
+func (b *valuesBuilder) withoutPlaceholders(n *yaml.Node) *yaml.Node {
+	if len(n.Content) == 0 {
+		return n
+	}
+	c := *n
+	c.Content = nil
+	if n.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if !b.isPlaceholder(n.Content[i+1]) {
+				c.Content = append(c.Content, n.Content[i], b.withoutPlaceholders(n.Content[i+1]))
+			}
+		}
+		return &c
+	}
+	for _, item := range n.Content {
+		c.Content = append(c.Content, b.withoutPlaceholders(item))
+	}
+	return &c
+}
+
+func encodeNode(n *yaml.Node) (string, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(n); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// fieldComment documents a field along with its allowed values.
+func fieldComment(f field) string {
+	var lines []string
+	if doc := f.description(); doc != "" {
+		lines = append(lines, strings.Split(doc, "\n")...)
+	}
+	if enum := f.enum(); enum != nil {
+		lines = append(lines, "Allowed values: "+strings.Join(enum, ", "))
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("# "+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// setValue applies a '<path>=<value>' override to the values.
+func (b *valuesBuilder) setValue(root *yaml.Node, t reflect.Type, expr string) error {
+	path, value, ok := strings.Cut(expr, "=")
+	if !ok || path == "" {
+		return fmt.Errorf("invalid value %q. Expected format is <path>=<value>", expr)
+	}
+	segments, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+
+	n := root
+	var f *field
+	p := ""
+	for _, seg := range segments {
+		delete(b.placeholders, n)
+		t = indirect(t)
+		if seg.index >= 0 {
+			if t.Kind() != reflect.Slice || t.Elem().Kind() == reflect.Uint8 {
+				return fmt.Errorf("failed to set %s: %s is not a list", path, p)
+			}
+			if n.Kind != yaml.SequenceNode {
+				*n = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			}
+			switch {
+			case seg.index < len(n.Content):
+			case seg.index == len(n.Content):
+				n.Content = append(n.Content, b.defaultNode(t.Elem(), nil))
+			default:
+				return fmt.Errorf("failed to set %s: index %d of %s is out of range, the list has %d item(s)", path, seg.index, p, len(n.Content))
+			}
+			n, t, f = n.Content[seg.index], t.Elem(), nil
+			p = fmt.Sprintf("%s[%d]", p, seg.index)
+			continue
+		}
+
+		var comment string
+		switch {
+		case customJSON(t):
+			return fmt.Errorf("failed to set %s: %s is not an object", path, p)
+		case t.Kind() == reflect.Struct:
+			sf, ok := findField(t, seg.key)
+			if !ok {
+				return fmt.Errorf("failed to set %s: unknown field %s", path, joinPath(p, seg.key))
+			}
+			t, f, comment = sf.Type, &sf, fieldComment(sf)
+		case t.Kind() == reflect.Map:
+			t, f = t.Elem(), nil
+		default:
+			return fmt.Errorf("failed to set %s: %s is not an object", path, p)
+		}
+		if n.Kind != yaml.MappingNode {
+			*n = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		n = mappingValue(n, seg.key, comment)
+		p = joinPath(p, seg.key)
+	}
+
+	val, err := parseValue(t, value)
+	if err != nil {
+		return fmt.Errorf("failed to set %s. Reason: %w", path, err)
+	}
+	var enum []string
+	if f != nil {
+		enum = f.enum()
+	} else {
+		enum = typeEnum(t)
+	}
+	v := &validator{partial: true}
+	v.check(val, t, p, enum)
+	if len(v.problems) > 0 {
+		return fmt.Errorf("failed to set %s: %s", path, v.problems[0].Message)
+	}
+	*n = *val
+	delete(b.placeholders, n)
+	return nil
+}
+
+type pathSegment struct {
+	key string
+	// index is the index of a list item, or -1 for the keys of objects
+	index int
+}
+
+// parsePath splits a path like a.b[0].c into its segments. Dots within keys
+// are escaped with a backslash, as in Helm.
+func parsePath(path string) ([]pathSegment, error) {
+	var segments []pathSegment
+	var key strings.Builder
+	flush := func() {
+		if key.Len() > 0 {
+			segments = append(segments, pathSegment{key: key.String(), index: -1})
+			key.Reset()
+		}
+	}
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; c {
+		case '\\':
+			if i+1 < len(path) {
+				i++
+			}
+			key.WriteByte(path[i])
+		case '.':
+			if key.Len() == 0 && (i == 0 || path[i-1] != ']') {
+				return nil, fmt.Errorf("invalid path %q", path)
+			}
+			flush()
+		case '[':
+			flush()
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 || len(segments) == 0 {
+				return nil, fmt.Errorf("invalid path %q", path)
+			}
+			idx, err := strconv.Atoi(path[i+1 : i+end])
+			if err != nil || idx < 0 {
+				return nil, fmt.Errorf("invalid index %q in path %q", path[i+1:i+end], path)
+			}
+			segments = append(segments, pathSegment{index: idx})
+			i += end
+		default:
+			key.WriteByte(c)
+		}
+	}
+	if key.Len() == 0 && (len(path) == 0 || path[len(path)-1] != ']') {
+		return nil, fmt.Errorf("invalid path %q", path)
+	}
+	flush()
+	return segments, nil
+}
+
+// parseValue parses a value given on the command line. Values of string
+// fields are taken as is, while the others are parsed as YAML.
+func parseValue(t reflect.Type, value string) (*yaml.Node, error) {
+	if elem := indirect(t); elem.Kind() == reflect.String && !customJSON(elem) {
+		return scalarNode("!!str", value), nil
+	}
+	return parseYAML(value)
+}
+
+func parseYAML(value string) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nullNode(), nil
+	}
+	n := doc.Content[0]
+	blockStyle(n)
+	return n, nil
+}
+
+// blockStyle formats the values set on the command line like the rest of the
+// values file.
+func blockStyle(n *yaml.Node) {
+	n.Style &^= yaml.FlowStyle
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
+
+// mappingValue returns the value of a key of a mapping, adding the key if
+// missing.
+func mappingValue(n *yaml.Node, key, comment string) *yaml.Node {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	k := scalarNode("!!str", key)
+	k.HeadComment = comment
+	val := nullNode()
+	n.Content = append(n.Content, k, val)
+	return val
+}
+
+func findField(t reflect.Type, name string) (field, bool) {
+	for _, f := range structFields(t) {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// pruneDefaults removes the values equal to their default from a mapping.
+func pruneDefaults(n, def *yaml.Node) {
+	var content []*yaml.Node
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, val := n.Content[i], n.Content[i+1]
+		defVal := lookupKey(def, key.Value)
+		if defVal != nil {
+			if val.Kind == yaml.MappingNode && defVal.Kind == yaml.MappingNode {
+				pruneDefaults(val, defVal)
+				if len(val.Content) == 0 {
+					continue
+				}
+			} else if sameValue(val, defVal) {
+				continue
+			}
+		}
+		content = append(content, key, val)
+	}
+	n.Content = content
+}
+
+func lookupKey(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func sameValue(a, b *yaml.Node) bool {
+	var va, vb any
+	if a.Decode(&va) != nil || b.Decode(&vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func scalarNode(tag, value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+}
+
+func nullNode() *yaml.Node {
+	return scalarNode("!!null", "null")
+}
@@ -0,0 +1,166 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package installer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGenerateValues(t *testing.T) {
+	c := mustFindComponent(t, "kube-ui-server")
+	tests := []struct {
+		name     string
+		set      []string
+		minimal  bool
+		want     string
+		contains []string
+		wantErr  string
+	}{
+		{
+			name: "placeholders",
+			contains: []string{
+				"# Values of the kube-ui-server chart (KubeUiServer)\n",
+				"\n# replicaCount: 0\n",
+				"\n# image:\n#   registry: \"\"\n",
+				"\n# If specified, the pod's tolerations.\n# tolerations: []\n",
+			},
+		},
+		{
+			name: "set",
+			set:  []string{"image.tag=v1"},
+			contains: []string{
+				"\nimage:\n  # registry: \"\"\n  # repository: \"\"\n  tag: v1\n",
+			},
+		},
+		{
+			name:    "minimal",
+			minimal: true,
+			want:    "{}\n",
+		},
+		{
+			name:    "minimal with set",
+			minimal: true,
+			set: []string{
+				"image.tag=v1",
+				"tolerations[0].key=a",
+				`annotations.app\.io/x=5`,
+				"replicaCount=3",
+			},
+			want: "replicaCount: 3\n" +
+				"image:\n  tag: v1\n" +
+				"annotations:\n  app.io/x: \"5\"\n" +
+				"# If specified, the pod's tolerations.\ntolerations:\n  - key: a\n",
+		},
+		{
+			name:    "wrong type",
+			set:     []string{"replicaCount=x"},
+			wantErr: "failed to set replicaCount: expected integer, got string",
+		},
+		{
+			name:    "enum",
+			set:     []string{"monitoring.agent=x"},
+			wantErr: `failed to set monitoring.agent: invalid value "x", must be one of prometheus.io, prometheus.io/operator, prometheus.io/builtin`,
+		},
+		{
+			name:    "unknown field",
+			set:     []string{"image.foo=x"},
+			wantErr: "failed to set image.foo: unknown field image.foo",
+		},
+		{
+			name:    "index out of range",
+			set:     []string{"tolerations[1].key=a"},
+			wantErr: "failed to set tolerations[1].key: index 1 of tolerations is out of range, the list has 0 item(s)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := GenerateValues(c, tt.set, tt.minimal)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("GenerateValues() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := string(data)
+			if tt.want != "" && got != tt.want {
+				t.Errorf("GenerateValues() = %q, want %q", got, tt.want)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(got, s) {
+					t.Errorf("GenerateValues() = %q, missing %q", got, s)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateValuesValidate(t *testing.T) {
+	for _, c := range Components() {
+		for _, minimal := range []bool{false, true} {
+			data, err := GenerateValues(c, nil, minimal)
+			if err != nil {
+				t.Fatalf("%s: %v", c.Name, err)
+			}
+			problems, err := Validate(c, data, false)
+			if err != nil {
+				t.Fatalf("%s: %v", c.Name, err)
+			}
+			for _, p := range problems {
+				t.Errorf("%s (minimal: %v): %s", c.Name, minimal, p)
+			}
+		}
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    []pathSegment
+		wantErr bool
+	}{
+		{path: "a", want: []pathSegment{{key: "a", index: -1}}},
+		{path: "a.b", want: []pathSegment{{key: "a", index: -1}, {key: "b", index: -1}}},
+		{path: `a\.b`, want: []pathSegment{{key: "a.b", index: -1}}},
+		{path: `annotations.app\.kubernetes\.io/name`, want: []pathSegment{{key: "annotations", index: -1}, {key: "app.kubernetes.io/name", index: -1}}},
+		{path: "x[0].y", want: []pathSegment{{key: "x", index: -1}, {index: 0}, {key: "y", index: -1}}},
+		{path: "x[1][2]", want: []pathSegment{{key: "x", index: -1}, {index: 1}, {index: 2}}},
+		{path: "[0]", wantErr: true},
+		{path: "a..b", wantErr: true},
+		{path: ".a", wantErr: true},
+		{path: "a.", wantErr: true},
+		{path: "", wantErr: true},
+		{path: "x[a]", wantErr: true},
+		{path: "x[-1]", wantErr: true},
+		{path: "x[0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := parsePath(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePath(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePath(%q) = %+v, want %+v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,700 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by hack/gendocs. DO NOT EDIT.
+
+package installer
+
+var typeDocs = map[string]typeDoc{
+	"AConfigSyncerpiserverSpec": {
+		Fields: map[string]fieldDoc{
+			"SecurePort":                 {},
+			"UseKubeapiserverFqdnForAks": {},
+			"Healthcheck":                {},
+			"ServingCerts":               {},
+		},
+	},
+	"AcePlatformSpec": {
+		Fields: map[string]fieldDoc{
+			"BaseURL":  {},
+			"Token":    {},
+			"CABundle": {},
+		},
+	},
+	"AceUserRolesValues": {
+		Fields: map[string]fieldDoc{
+			"Enabled": {},
+		},
+	},
+	"ApiserverDB": {
+		Enum: []string{"etcd", "kine"},
+	},
+	"ApiserverSpec": {
+		Fields: map[string]fieldDoc{
+			"GroupPriorityMinimum":       {},
+			"VersionPriority":            {},
+			"UseKubeapiserverFqdnForAks": {},
+			"Healthcheck":                {},
+			"ServingCerts":               {},
+		},
+	},
+	"BasicAuth": {
+		Fields: map[string]fieldDoc{
+			"Username": {},
+			"Password": {},
+		},
+	},
+	"CacherContainer": {
+		Fields: map[string]fieldDoc{
+			"Enable":   {},
+			"Schedule": {},
+		},
+	},
+	"CleanerRef": {
+		Fields: map[string]fieldDoc{
+			"Skip":            {},
+			"SecurityContext": {Doc: "Security options the pod should run with."},
+		},
+	},
+	"ClusterConnector": {
+		Fields: map[string]fieldDoc{
+			"Spec": {},
+		},
+	},
+	"ClusterConnectorList": {
+		Doc: "ClusterConnectorList is a list of ClusterConnectors",
+		Fields: map[string]fieldDoc{
+			"Items": {Doc: "Items is a list of ClusterConnector CRD objects"},
+		},
+	},
+	"ClusterConnectorNats": {
+		Fields: map[string]fieldDoc{
+			"Address":      {},
+			"EncodedCreds": {},
+		},
+	},
+	"ClusterConnectorSpec": {
+		Doc: "ClusterConnectorSpec is the schema for Identity Server values file",
+		Fields: map[string]fieldDoc{
+			"NameOverride":       {},
+			"FullnameOverride":   {},
+			"ReplicaCount":       {},
+			"RegistryFQDN":       {},
+			"Image":              {},
+			"ImagePullSecrets":   {},
+			"ImagePullPolicy":    {},
+			"ServiceAccount":     {},
+			"PodAnnotations":     {},
+			"PodSecurityContext": {Doc: "PodSecurityContext holds pod-level security attributes and common container settings.\nOptional: Defaults to empty.  See type description for default values of each field."},
+			"NodeSelector":       {},
+			"Tolerations":        {Doc: "If specified, the pod's tolerations."},
+			"Affinity":           {Doc: "If specified, the pod's scheduling constraints"},
+			"Platform":           {},
+			"LinkID":             {},
+			"Nats":               {},
+		},
+	},
+	"ConfigSyncer": {
+		Fields: map[string]fieldDoc{
+			"Spec": {},
+		},
+	},
+	"ConfigSyncerConfig": {
+		Fields: map[string]fieldDoc{
+			"ClusterName":           {},
+			"ConfigSourceNamespace": {},
+			"KubeconfigContent":     {},
+			"AdditionalOptions":     {},
+		},
+	},
+	"ConfigSyncerList": {
+		Doc: "ConfigSyncerList is a list of ConfigSyncers",
+		Fields: map[string]fieldDoc{
+			"Items": {Doc: "Items is a list of ConfigSyncer CRD objects"},
+		},
+	},
+	"ConfigSyncerSpec": {
+		Doc: "ConfigSyncerSpec is the schema for ConfigSyncer Operator values file",
+		Fields: map[string]fieldDoc{
+			"NameOverride":       {},
+			"FullnameOverride":   {},
+			"ReplicaCount":       {},
+			"RegistryFQDN":       {},
+			"License":            {},
+			"Mode":               {},
+			"Image":              {},
+			"ImagePullPolicy":    {},
+			"ImagePullSecrets":   {},
+			"CriticalAddon":      {},
+			"LogLevel":           {},
+			"Annotations":        {},
+			"PodAnnotations":     {},
+			"NodeSelector":       {},
+			"Tolerations":        {Doc: "If specified, the pod's tolerations."},
+			"Affinity":           {Doc: "If specified, the pod's scheduling constraints"},
+			"PodSecurityContext": {Doc: "PodSecurityContext holds pod-level security attributes and common container settings.\nOptional: Defaults to empty.  See type description for default values of each field."},
+			"ServiceAccount":     {},
+			"Apiserver":          {},
+			"Config":             {},
+		},
+	},
+	"Container": {
+		Fields: map[string]fieldDoc{
+			"Resources":       {Doc: "Compute Resources required by the sidecar container."},
+			"SecurityContext": {Doc: "Security options the pod should run with."},
+		},
+	},
+	"DashboardTemplatize": {
+		Fields: map[string]fieldDoc{
+			"Title":      {},
+			"Datasource": {},
+		},
+	},
+	"EnforcementAction": {
+		Enum: []string{"warn", "deny", "dryrun"},
+	},
+	"EtcdContainer": {
+		Fields: map[string]fieldDoc{},
+	},
+	"ExternalDnsOperator": {
+		Fields: map[string]fieldDoc{
+			"Spec": {},
+		},
+	},
+	"ExternalDnsOperatorList": {
+		Doc: "ExternalDnsOperatorList is a list of ExternalDnsOperators",
+		Fields: map[string]fieldDoc{
+			"Items": {Doc: "Items is a list of ExternalDnsOperator CRD objects"},
+		},
+	},
+	"ExternalDnsOperatorSpec": {
+		Doc: "ExternalDnsOperatorSpec is the schema for Identity Server values file",
+		Fields: map[string]fieldDoc{
+			"NameOverride":       {},
+			"FullnameOverride":   {},
+			"ReplicaCount":       {},
+			"RegistryFQDN":       {},
+			"Image":              {},
+			"ImagePullSecrets":   {},
+			"ImagePullPolicy":    {},
+			"ServiceAccount":     {},
+			"PodAnnotations":     {},
+			"PodSecurityContext": {Doc: "PodSecurityContext holds pod-level security attributes and common container settings.\nOptional: Defaults to empty.  See type description for default values of each field."},
+			"NodeSelector":       {},
+			"Tolerations":        {Doc: "If specified, the pod's tolerations."},
+			"Affinity":           {Doc: "If specified, the pod's scheduling constraints"},
+			"Monitoring":         {},
+		},
+	},
+	"FalcoUiServer": {
+		Fields: map[string]fieldDoc{
+			"Spec": {},
+		},
+	},
+	"FalcoUiServerList": {
+		Doc: "FalcoUiServerList is a list of FalcoUiServers",
+		Fields: map[string]fieldDoc{
+			"Items": {Doc: "Items is a list of FalcoUiServer CRD objects"},
+		},
+	},
+	"FalcoUiServerSpec": {
+		Doc: "FalcoUiServerSpec is the schema for FalcoUiServer Operator values file",
+		Fields: map[string]fieldDoc{
+			"NameOverride":       {},
+			"FullnameOverride":   {},
+			"ReplicaCount":       {},
+			"RegistryFQDN":       {},
+			"App":                {},
+			"Etcd":               {},
+			"Kine":               {},
+			"ImagePullPolicy":    {},
+			"ImagePullSecrets":   {},
+			"CriticalAddon":      {},
+			"LogLevel":           {},
+			"Annotations":        {},
+			"PodAnnotations":     {},
+			"NodeSelector":       {},
+			"Tolerations":        {Doc: "If specified, the pod's tolerations."},
+			"Affinity":           {Doc: "If specified, the pod's scheduling constraints"},
+			"PodSecurityContext": {Doc: "PodSecurityContext holds pod-level security attributes and common container settings.\nOptional: Defaults to empty.  See type description for default values of each field."},
+			"StorageClass":       {},
+			"Persistence":        {},
+			"ServiceAccount":     {},
+			"Apiserver":          {},
+			"Monitoring":         {},
+			"Dashboard":          {},
+			"Grafana":            {},
+			"EventTTL":           {},
+		},
+	},
+	"FalcoUiserverSpec": {
+		Fields: map[string]fieldDoc{
+			"DB": {},
+		},
+	},
+	"GatekeeperGrafanaDashboards": {
+		Fields: map[string]fieldDoc{
+			"Spec": {},
+		},
+	},
+	"GatekeeperGrafanaDashboardsList": {
+		Doc: "GatekeeperGrafanaDashboardsList is a list of GatekeeperGrafanaDashboardss",
+		Fields: map[string]fieldDoc{
+			"Items": {Doc: "Items is a list of GatekeeperGrafanaDashboards CRD objects"},
+		},
+	},
+	"GatekeeperGrafanaDashboardsSpec": {
+		Doc: "GatekeeperGrafanaDashboardsSpec is the schema for Identity Server values file",
+		Fields: map[string]fieldDoc{
+			"NameOverride":     {},
+			"FullnameOverride": {},
+			"Dashboard":        {},
+			"Grafana":          {},
+		},
+	},
+	"GatekeeperLibrary": {
+		Fields: map[string]fieldDoc{
+			"Spec": {},
+		},
+	},
+	"GatekeeperLibraryList": {
+		Doc: "GatekeeperLibraryList is a list of GatekeeperLibrarys",
+		Fields: map[string]fieldDoc{
+			"Items": {Doc: "Items is a list of GatekeeperLibrary CRD objects"},
+		},
+	},
+	"GatekeeperLibrarySpec": {
+		Doc: "GatekeeperLibrarySpec is the schema for GatekeeperLibrary Operator values file",
+		Fields: map[string]fieldDoc{
+			"NameOverride":      {},
+			"FullnameOverride":  {},
+			"Enable":            {Default: "templates"},
+			"EnableConstraints": {},
+			"EnforcementAction": {Default: "warn"},
+		},
+	},
+	"GatekeeperResource": {
+		Enum: []string{"templates", "constraints"},
+	},
+	"GrafanaDashboard": {
+		Fields: map[string]fieldDoc{
+			"Enabled":    {},
+			"FolderID":   {},
+			"Overwrite":  {},
+			"Templatize": {},
+		},
+	},
+	"HealthcheckSpec": {
+		Fields: map[string]fieldDoc{
+			"Enabled": {},
+		},
+	},
+	"HelmRepositories": {
+		Fields: map[string]fieldDoc{
+			"Create": {},
+		},
+	},
+	"ImageRef": {
+		Fields: map[string]fieldDoc{
+			"Registry":   {},
+			"Repository": {},
+			"Tag":        {},
+		},
+	},
+	"KubeUiServer": {
+		Fields: map[string]fieldDoc{
+			"Spec": {},
+		},
+	},
+	"KubeUiServerList": {
+		Doc: "KubeUiServerList is a list of KubeUiServers",
+		Fields: map[string]fieldDoc{
+			"Items": {Doc: "Items is a list of KubeUiServer CRD objects"},
+		},
+	},
+	"KubeUiServerSpec": {
+		Doc: "KubeUiServerSpec is the schema for Identity Server values file",
+		Fields: map[string]fieldDoc{
+			"NameOverride":         {},
+			"FullnameOverride":     {},
+			"ReplicaCount":         {},
+			"RegistryFQDN":         {},
+			"Image":                {},
+			"ImagePullPolicy":      {},
+			"ImagePullSecrets":     {},
+			"CriticalAddon":        {},
+			"LogLevel":             {},
+			"Annotations":          {},
+			"PodAnnotations":       {},
+			"NodeSelector":         {},
+			"Tolerations":          {Doc: "If specified, the pod's tolerations."},
+			"Affinity":             {Doc: "If specified, the pod's scheduling constraints"},
+			"PodSecurityContext":   {Doc: "PodSecurityContext holds pod-level security attributes and common container settings.\nOptional: Defaults to empty.  See type description for default values of each field."},
+			"ServiceAccount":       {},
+			"Apiserver":            {},
+			"Monitoring":           {},
+			"Prometheus":           {},
+			"HelmRepositories":     {},
+			"KubeconfigSecretName": {},
+			"Platform":             {},
+			"AceUserRoles":         {},
+		},
+	},
+	"LicenseMode": {
+		Enum:    []string{"oss", "enterprise"},
+		Default: "oss",
+	},
+	"LocalObjectReference": {
+		Fields: map[string]fieldDoc{
+			"Name": {},
+		},
+	},
+	"Monitoring": {
+		Fields: map[string]fieldDoc{
+			"Agent":          {},
+			"ServiceMonitor": {},
+		},
+	},
+	"MonitoringAgent": {
+		Enum: []string{"prometheus.io", "prometheus.io/operator", "prometheus.io/builtin"},
+	},
+	"NatsAuth": {
+		Fields: map[string]fieldDoc{
+			"Username": {},
+			"Password": {},
+		},
+	},
+	"NetworkPolicy": {
+		Fields: map[string]fieldDoc{
+			"Enabled": {},
+		},
+	},
+	"ObjectReference": {
+		Fields: map[string]fieldDoc{
+			"Name":      {},
+			"Namespace": {},
+		},
+	},
+	"OpencostGrafanaDashboards": {
+		Fields: map[string]fieldDoc{
+			"Spec": {},
+		},
+	},
+	"OpencostGrafanaDashboardsList": {
+		Doc: "OpencostGrafanaDashboardsList is a list of OpencostGrafanaDashboardss",
+		Fields: map[string]fieldDoc{
+			"Items": {Doc: "Items is a list of OpencostGrafanaDashboards CRD objects"},
+		},
+	},
+	"OpencostGrafanaDashboardsSpec": {
+		Doc: "OpencostGrafanaDashboardsSpec is the schema for Identity Server values file",
+		Fields: map[string]fieldDoc{
+			"NameOverride":     {},
+			"FullnameOverride": {},
+			"Dashboard":        {},
+			"Grafana":          {},
+		},
+	},
+	"Panopticon": {
+		Fields: map[string]fieldDoc{
+			"Spec": {},
+		},
+	},
+	"PanopticonList": {
+		Doc: "PanopticonList is a list of Panopticons",
+		Fields: map[string]fieldDoc{
+			"Items": {Doc: "Items is a list of Panopticon CRD objects"},
+		},
+	},
+	"PanopticonSpec": {
+		Doc: "PanopticonSpec is the schema for Panopticon Operator values file",
+		Fields: map[string]fieldDoc{
+			"NameOverride":       {},
+			"FullnameOverride":   {},
+			"ReplicaCount":       {},
+			"RegistryFQDN":       {},
+			"NamespaceSelector":  {},
+			"Image":              {},
+			"Cleaner":            {},
+			"ImagePullPolicy":    {},
+			"ImagePullSecrets":   {},
+			"CriticalAddon":      {},
+			"LogLevel":           {},
+			"Annotations":        {},
+			"PodAnnotations":     {},
+			"NodeSelector":       {},
+			"Tolerations":        {Doc: "If specified, the pod's tolerations."},
+			"Affinity":           {Doc: "If specified, the pod's scheduling constraints"},
+			"PodSecurityContext": {Doc: "PodSecurityContext holds pod-level security attributes and common container settings.\nOptional: Defaults to empty.  See type description for default values of each field."},
+			"ServiceAccount":     {},
+			"Apiserver":          {},
+			"Monitoring":         {},
+			"License":            {},
+			"LicenseSecretName":  {},
+			"NetworkPolicy":      {},
+		},
+	},
+	"Persistence": {
+		Fields: map[string]fieldDoc{
+			"Size": {},
+		},
+	},
+	"Petset": {
+		Fields: map[string]fieldDoc{
+			"Spec": {},
+		},
+	},
+	"PetsetApiserver": {
+		Fields: map[string]fieldDoc{
+			"GroupPriorityMinimum":        {},
+			"VersionPriority":             {},
+			"EnableMutatingWebhook":       {},
+			"EnableValidatingWebhook":     {},
+			"BypassValidatingWebhookXray": {},
+			"UseKubeapiserverFqdnForAks":  {},
+			"Healthcheck":                 {},
+			"ServingCerts":                {},
+		},
+	},
+	"PetsetList": {
+		Doc: "PetsetList is a list of Petsets",
+		Fields: map[string]fieldDoc{
+			"Items": {Doc: "Items is a list of Petset CRD objects"},
+		},
+	},
+	"PetsetSpec": {
+		Doc: "PetsetSpec is the schema for Operator Operator values file",
+		Fields: map[string]fieldDoc{
+			"NameOverride":       {},
+			"FullnameOverride":   {},
+			"RegistryFQDN":       {},
+			"ReplicaCount":       {},
+			"Operator":           {},
+			"RbacProxy":          {},
+			"ImagePullPolicy":    {},
+			"ImagePullSecrets":   {},
+			"CriticalAddon":      {},
+			"LogLevel":           {},
+			"Annotations":        {},
+			"PodAnnotations":     {},
+			"PodLabels":          {},
+			"NodeSelector":       {},
+			"Tolerations":        {Doc: "If specified, the pod's tolerations."},
+			"Affinity":           {Doc: "If specified, the pod's scheduling constraints"},
+			"PodSecurityContext": {Doc: "PodSecurityContext holds pod-level security attributes and common container settings.\nOptional: Defaults to empty.  See type description for default values of each field."},
+			"ServiceAccount":     {},
+			"Apiserver":          {},
+			"Monitoring":         {},
+			"NetworkPolicy":      {},
+		},
+	},
+	"PlatformSpec": {
+		Fields: map[string]fieldDoc{
+			"BaseURL": {},
+		},
+	},
+	"PrometheusConfig": {
+		Fields: map[string]fieldDoc{
+			"Address":     {},
+			"BasicAuth":   {},
+			"BearerToken": {},
+			"ProxyURL":    {},
+			"TLS":         {},
+		},
+	},
+	"Scanner": {
+		Fields: map[string]fieldDoc{
+			"Spec": {},
+		},
+	},
+	"ScannerList": {
+		Doc: "ScannerList is a list of Scanners",
+		Fields: map[string]fieldDoc{
+			"Items": {Doc: "Items is a list of Scanner CRD objects"},
+		},
+	},
+	"ScannerNATS": {
+		Fields: map[string]fieldDoc{
+			"Addr": {},
+			"Auth": {},
+		},
+	},
+	"ScannerSpec": {
+		Doc: "ScannerSpec is the schema for Scanner Operator values file",
+		Fields: map[string]fieldDoc{
+			"NameOverride":                {},
+			"FullnameOverride":            {},
+			"ReplicaCount":                {},
+			"RegistryFQDN":                {},
+			"App":                         {},
+			"Etcd":                        {},
+			"Kine":                        {},
+			"Cacher":                      {},
+			"ImagePullPolicy":             {},
+			"ImagePullSecrets":            {},
+			"CriticalAddon":               {},
+			"LogLevel":                    {},
+			"Annotations":                 {},
+			"PodAnnotations":              {},
+			"NodeSelector":                {},
+			"Tolerations":                 {Doc: "If specified, the pod's tolerations."},
+			"Affinity":                    {Doc: "If specified, the pod's scheduling constraints"},
+			"PodSecurityContext":          {Doc: "PodSecurityContext holds pod-level security attributes and common container settings.\nOptional: Defaults to empty.  See type description for default values of each field."},
+			"StorageClass":                {},
+			"Persistence":                 {},
+			"ServiceAccount":              {},
+			"Apiserver":                   {},
+			"Monitoring":                  {},
+			"Dashboard":                   {},
+			"Grafana":                     {},
+			"Nats":                        {},
+			"License":                     {},
+			"ScanRequestTTLAfterFinished": {},
+			"ScanReportTTLAfterOutdated":  {},
+			"Workspace":                   {},
+		},
+	},
+	"ScannerWorkspace": {
+		Fields: map[string]fieldDoc{
+			"Namespace": {},
+		},
+	},
+	"ScannerserverSpec": {
+		Fields: map[string]fieldDoc{
+			"DB": {},
+		},
+	},
+	"ServiceAccountSpec": {
+		Fields: map[string]fieldDoc{
+			"Create":      {},
+			"Name":        {},
+			"Annotations": {},
+		},
+	},
+	"ServiceMonitorLabels": {
+		Fields: map[string]fieldDoc{
+			"Labels": {},
+		},
+	},
+	"ServingCerts": {
+		Fields: map[string]fieldDoc{
+			"Generate":  {},
+			"CaCrt":     {},
+			"ServerCrt": {},
+			"ServerKey": {},
+		},
+	},
+	"Sidekick": {
+		Fields: map[string]fieldDoc{
+			"Spec": {},
+		},
+	},
+	"SidekickList": {
+		Doc: "SidekickList is a list of Sidekicks",
+		Fields: map[string]fieldDoc{
+			"Items": {Doc: "Items is a list of Sidekick CRD objects"},
+		},
+	},
+	"SidekickSpec": {
+		Doc: "SidekickSpec is the schema for Identity Server values file",
+		Fields: map[string]fieldDoc{
+			"NameOverride":       {},
+			"FullnameOverride":   {},
+			"ReplicaCount":       {},
+			"RegistryFQDN":       {},
+			"Image":              {},
+			"ImagePullSecrets":   {},
+			"ImagePullPolicy":    {},
+			"ServiceAccount":     {},
+			"PodAnnotations":     {},
+			"PodSecurityContext": {Doc: "PodSecurityContext holds pod-level security attributes and common container settings.\nOptional: Defaults to empty.  See type description for default values of each field."},
+			"NodeSelector":       {},
+			"Tolerations":        {Doc: "If specified, the pod's tolerations."},
+			"Affinity":           {Doc: "If specified, the pod's scheduling constraints"},
+			"Monitoring":         {},
+			"NetworkPolicy":      {},
+		},
+	},
+	"Supervisor": {
+		Fields: map[string]fieldDoc{
+			"Spec": {},
+		},
+	},
+	"SupervisorApiserver": {
+		Fields: map[string]fieldDoc{
+			"GroupPriorityMinimum":       {},
+			"VersionPriority":            {},
+			"EnableMutatingWebhook":      {},
+			"EnableValidatingWebhook":    {},
+			"UseKubeapiserverFqdnForAks": {},
+			"Healthcheck":                {},
+			"ServingCerts":               {},
+		},
+	},
+	"SupervisorList": {
+		Doc: "SupervisorList is a list of Supervisors",
+		Fields: map[string]fieldDoc{
+			"Items": {Doc: "Items is a list of Supervisor CRD objects"},
+		},
+	},
+	"SupervisorSpec": {
+		Doc: "SupervisorSpec is the schema for Identity Server values file",
+		Fields: map[string]fieldDoc{
+			"NameOverride":           {},
+			"FullnameOverride":       {},
+			"ReplicaCount":           {},
+			"RegistryFQDN":           {},
+			"MaxConcurrentReconcile": {},
+			"RequeueAfterDuration":   {},
+			"RetryAfterDuration":     {},
+			"BeforeDeadlineDuration": {},
+			"Image":                  {},
+			"ImagePullPolicy":        {},
+			"ImagePullSecrets":       {},
+			"CriticalAddon":          {},
+			"LogLevel":               {},
+			"Annotations":            {},
+			"PodAnnotations":         {},
+			"NodeSelector":           {},
+			"Tolerations":            {Doc: "If specified, the pod's tolerations."},
+			"Affinity":               {Doc: "If specified, the pod's scheduling constraints"},
+			"PodSecurityContext":     {Doc: "PodSecurityContext holds pod-level security attributes and common container settings.\nOptional: Defaults to empty.  See type description for default values of each field."},
+			"ServiceAccount":         {},
+			"Apiserver":              {},
+			"Monitoring":             {},
+			"NetworkPolicy":          {},
+		},
+	},
+	"TLSConfig": {
+		Fields: map[string]fieldDoc{
+			"Ca":                    {},
+			"Cert":                  {},
+			"Key":                   {},
+			"ServerName":            {},
+			"InsecureSkipTLSVerify": {},
+		},
+	},
+	"WebHookSpec": {
+		Fields: map[string]fieldDoc{
+			"GroupPriorityMinimum":       {},
+			"VersionPriority":            {},
+			"EnableValidatingWebhook":    {},
+			"UseKubeapiserverFqdnForAks": {},
+			"Healthcheck":                {},
+			"ServingCerts":               {},
+		},
+	},
+}
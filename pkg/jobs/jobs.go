@@ -0,0 +1,278 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.bytebuilders.dev/ace/pkg/config"
+)
+
+const (
+	metadataFile   = "job.json"
+	transcriptFile = "transcript.jsonl"
+
+	ResultSucceeded   = "Success"
+	ResultFailed      = "Failed"
+	ResultInterrupted = "Interrupted"
+	ResultRunning     = "Running"
+
+	ProgressModeNATS    = "nats"
+	ProgressModePolling = "polling"
+)
+
+// sensitiveKeys are redacted from the recorded options. Keys are matched
+// case-insensitively as substrings of the option names.
+var sensitiveKeys = []string{"kubeconfig", "password", "token", "secret", "cookie"}
+
+// Job holds the metadata of a job started from this machine.
+type Job struct {
+	ID           string                 `json:"id"`
+	Command      string                 `json:"command"`
+	Context      string                 `json:"context,omitempty"`
+	Organization string                 `json:"organization,omitempty"`
+	Cluster      string                 `json:"cluster,omitempty"`
+	Options      map[string]interface{} `json:"options,omitempty"`
+	ProgressMode string                 `json:"progressMode,omitempty"`
+	StartTime    time.Time              `json:"startTime"`
+	EndTime      *time.Time             `json:"endTime,omitempty"`
+	Result       string                 `json:"result"`
+	Error        string                 `json:"error,omitempty"`
+}
+
+// Duration returns how long the job ran. For unfinished jobs it is zero.
+func (j *Job) Duration() time.Duration {
+	if j.EndTime == nil {
+		return 0
+	}
+	return j.EndTime.Sub(j.StartTime)
+}
+
+// TranscriptEntry is a single NATS message received for a job.
+type TranscriptEntry struct {
+	Time    time.Time       `json:"time"`
+	Message json.RawMessage `json:"message"`
+}
+
+// Recorder writes the metadata and transcript of a running job into the
+// state directory.
+type Recorder struct {
+	mu  sync.Mutex
+	dir string
+	job Job
+}
+
+// Start creates the record of a new job. The options are stored with the
+// sensitive fields redacted.
+func Start(id, command, cluster string, opts interface{}) (*Recorder, error) {
+	dir, err := jobDir(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	contextName, err := config.GetCurrentContextName()
+	if err != nil {
+		return nil, err
+	}
+	options, err := redactOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recorder{
+		dir: dir,
+		job: Job{
+			ID:           id,
+			Command:      command,
+			Context:      contextName,
+			Organization: config.Organization,
+			Cluster:      cluster,
+			Options:      options,
+			ProgressMode: ProgressModeNATS,
+			StartTime:    time.Now(),
+			Result:       ResultRunning,
+		},
+	}
+	return r, r.save()
+}
+
+// Resume reopens the record of a job that was detached earlier, so that
+// further messages are appended to its transcript.
+func Resume(id string) (*Recorder, error) {
+	job, err := Get(id)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := jobDir(id)
+	if err != nil {
+		return nil, err
+	}
+
+	job.EndTime = nil
+	job.Error = ""
+	job.Result = ResultRunning
+	r := &Recorder{
+		dir: dir,
+		job: *job,
+	}
+	return r, r.save()
+}
+
+// ID returns the id of the recorded job.
+func (r *Recorder) ID() string {
+	return r.job.ID
+}
+
+// SetCluster updates the name of the cluster the job is running for.
+func (r *Recorder) SetCluster(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if name != "" {
+		r.job.Cluster = name
+	}
+}
+
+// SetProgressMode records how the progress of the job was followed.
+func (r *Recorder) SetProgressMode(mode string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.job.ProgressMode = mode
+}
+
+// RecordMessage appends a NATS message to the transcript of the job.
+func (r *Recorder) RecordMessage(data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, err := json.Marshal(TranscriptEntry{
+		Time:    time.Now(),
+		Message: data,
+	})
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(filepath.Join(r.dir, transcriptFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(entry, '\n'))
+	return err
+}
+
+// RecordResult stores the final status reported by the job itself.
+func (r *Recorder) RecordResult(status string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.job.Result = status
+}
+
+// Finish marks the job as completed. An error returned by the command takes
+// precedence over the result reported by the job.
+func (r *Recorder) Finish(err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.job.EndTime = &now
+	if err != nil {
+		r.job.Error = err.Error()
+		if r.job.Result == ResultRunning || r.job.Result == ResultSucceeded {
+			r.job.Result = ResultFailed
+		}
+	} else if r.job.Result == ResultRunning {
+		r.job.Result = ResultSucceeded
+	}
+	return r.save()
+}
+
+// Interrupt marks the job as detached by the user. The job may still be
+// running on the server.
+func (r *Recorder) Interrupt() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.job.EndTime = &now
+	r.job.Result = ResultInterrupted
+	return r.save()
+}
+
+func (r *Recorder) save() error {
+	data, err := json.MarshalIndent(r.job, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(r.dir, metadataFile), data, 0o600)
+}
+
+func redactOptions(opts interface{}) (map[string]interface{}, error) {
+	if opts == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job options. Reason: %w", err)
+	}
+	var options map[string]interface{}
+	if err := json.Unmarshal(data, &options); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal job options. Reason: %w", err)
+	}
+	redact(options)
+	return options, nil
+}
+
+func redact(obj interface{}) {
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			if isSensitive(key) {
+				if s, ok := val.(string); ok && s == "" {
+					continue
+				}
+				v[key] = "<REDACTED>"
+				continue
+			}
+			redact(val)
+		}
+	case []interface{}:
+		for i := range v {
+			redact(v[i])
+		}
+	}
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobs
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.bytebuilders.dev/ace/pkg/config"
+)
+
+var ErrJobNotFound = errors.New("job does not exist")
+
+// Filter selects jobs from the history. Empty fields match everything.
+type Filter struct {
+	Command      string
+	Context      string
+	Organization string
+	Cluster      string
+	Result       string
+	Since        time.Duration
+	Limit        int
+}
+
+func (f Filter) matches(job *Job, now time.Time) bool {
+	if f.Command != "" && !strings.Contains(job.Command, f.Command) {
+		return false
+	}
+	if f.Context != "" && job.Context != f.Context {
+		return false
+	}
+	if f.Organization != "" && job.Organization != f.Organization {
+		return false
+	}
+	if f.Cluster != "" && job.Cluster != f.Cluster {
+		return false
+	}
+	if f.Result != "" && !strings.EqualFold(job.Result, f.Result) {
+		return false
+	}
+	if f.Since > 0 && job.StartTime.Before(now.Add(-f.Since)) {
+		return false
+	}
+	return true
+}
+
+// List returns the recorded jobs matching the filter, newest first.
+func List(filter Filter) ([]Job, error) {
+	dir, err := historyDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	jobs := make([]Job, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		job, err := Get(entry.Name())
+		if err != nil {
+			if errors.Is(err, ErrJobNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		if filter.matches(job, now) {
+			jobs = append(jobs, *job)
+		}
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].StartTime.After(jobs[j].StartTime)
+	})
+	if filter.Limit > 0 && len(jobs) > filter.Limit {
+		jobs = jobs[:filter.Limit]
+	}
+	return jobs, nil
+}
+
+// Get returns the metadata of a recorded job.
+func Get(id string) (*Job, error) {
+	dir, err := jobDir(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, metadataFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("failed to parse job %s. Reason: %w", id, err)
+	}
+	return &job, nil
+}
+
+// ReadTranscript returns the NATS messages recorded for a job in the order
+// they were received.
+func ReadTranscript(id string) ([]TranscriptEntry, error) {
+	dir, err := jobDir(id)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filepath.Join(dir, transcriptFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []TranscriptEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var entry TranscriptEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse transcript of job %s. Reason: %w", id, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+func historyDir() (string, error) {
+	stateDir, err := config.GetStateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, "jobs"), nil
+}
+
+func jobDir(id string) (string, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return "", fmt.Errorf("invalid job id %q", id)
+	}
+	dir, err := historyDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, id), nil
+}
@@ -0,0 +1,132 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.bytebuilders.dev/ace/pkg/jobs"
+
+	"sigs.k8s.io/yaml"
+)
+
+func PrintJobList(items []jobs.Job) error {
+	switch OutputFormat {
+	case "json":
+		data, err := json.MarshalIndent(items, "", " ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	case "yaml":
+		data, err := yaml.Marshal(items)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', 0)
+	fmt.Fprintln(w, "ID\tCOMMAND\tCLUSTER\tCONTEXT\tSTARTED\tDURATION\tRESULT")
+	for i := range items {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", items[i].ID, items[i].Command, items[i].Cluster, items[i].Context,
+			items[i].StartTime.Local().Format(time.DateTime), formatDuration(items[i].Duration()), items[i].Result)
+	}
+	return w.Flush()
+}
+
+// PrintJob prints the metadata of a job followed by its re-rendered transcript.
+func PrintJob(job *jobs.Job, transcript []jobs.TranscriptEntry) error {
+	switch OutputFormat {
+	case "json", "yaml":
+		out := struct {
+			*jobs.Job
+			Transcript []jobs.TranscriptEntry `json:"transcript,omitempty"`
+		}{job, transcript}
+		var data []byte
+		var err error
+		if OutputFormat == "json" {
+			data, err = json.MarshalIndent(out, "", " ")
+		} else {
+			data, err = yaml.Marshal(out)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID:\t%s\n", job.ID)
+	fmt.Fprintf(w, "Command:\t%s\n", job.Command)
+	fmt.Fprintf(w, "Context:\t%s\n", job.Context)
+	if job.Organization != "" {
+		fmt.Fprintf(w, "Organization:\t%s\n", job.Organization)
+	}
+	fmt.Fprintf(w, "Cluster:\t%s\n", job.Cluster)
+	fmt.Fprintf(w, "Progress Mode:\t%s\n", job.ProgressMode)
+	fmt.Fprintf(w, "Started:\t%s\n", job.StartTime.Local().Format(time.DateTime))
+	if job.EndTime != nil {
+		fmt.Fprintf(w, "Finished:\t%s\n", job.EndTime.Local().Format(time.DateTime))
+	}
+	fmt.Fprintf(w, "Result:\t%s\n", job.Result)
+	if job.Error != "" {
+		fmt.Fprintf(w, "Error:\t%s\n", job.Error)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(job.Options) > 0 {
+		data, err := yaml.Marshal(job.Options)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Options:\n%s", indent(string(data), "  "))
+	}
+
+	fmt.Println("\nTranscript:")
+	if len(transcript) == 0 {
+		fmt.Println("  No step has been recorded for this job.")
+		return nil
+	}
+	messages := make([][]byte, 0, len(transcript))
+	for i := range transcript {
+		messages = append(messages, transcript[i].Message)
+	}
+	return PrintJobTranscript(messages)
+}
+
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return d.Round(time.Second).String()
+}
+
+func indent(s, prefix string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	return prefix + strings.Join(lines, "\n"+prefix) + "\n"
+}
@@ -17,11 +17,11 @@ limitations under the License.
 package printer
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
 	"strings"
-	"sync"
 
 	"github.com/fatih/color"
 	"github.com/nats-io/nats.go"
@@ -33,18 +33,31 @@ type natsMessage struct {
 	Status string `json:"status"`
 }
 
+var ErrTerminatedByUser = errors.New("command terminated by user")
+
+// ErrJobFailed is returned when the job reports that it has failed.
+var ErrJobFailed = errors.New("job failed")
+
 const (
 	stepStarted   = "Started"
 	stepSucceeded = "Success"
 	stepFailed    = "Failed"
 )
 
-func PrintNATSJobSteps(wg *sync.WaitGroup, nc *nats.Conn, responseID string, done <-chan os.Signal) error {
-	defer wg.Done()
+// JobRecorder receives the messages of a job as they are printed.
+type JobRecorder interface {
+	// RecordMessage is called with every valid message of the job
+	RecordMessage(data []byte) error
+	// RecordResult is called with the final status of the job
+	RecordResult(status string)
+}
 
+// PrintNATSJobSteps prints the steps of a job as they are published over
+// NATS. It returns once the job has finished or ctx is cancelled, with
+// ErrJobFailed if the job has failed.
+func PrintNATSJobSteps(ctx context.Context, nc *nats.Conn, responseID string, rec JobRecorder) error {
 	subject := fmt.Sprintf("natjobs.resp.%s", responseID)
-	steps := make(map[string]string)
-	parentID := ""
+	p := newJobStepPrinter()
 
 	msgStream := make(chan *nats.Msg, 100)
 	sub, err := nc.ChanSubscribe(subject, msgStream)
@@ -62,38 +75,89 @@ func PrintNATSJobSteps(wg *sync.WaitGroup, nc *nats.Conn, responseID string, don
 	}
 	for {
 		select {
-		case <-done:
-			return stopListening(fmt.Errorf("command terminated by user"))
+		case <-ctx.Done():
+			return stopListening(ErrTerminatedByUser)
 		case msg := <-msgStream:
-			resp := natsMessage{}
-			err := json.Unmarshal(msg.Data, &resp)
+			result, err := p.print(msg.Data)
 			if err != nil {
-				return stopListening(fmt.Errorf("failed to parse message. Reason: %w", err))
+				return stopListening(err)
 			}
-			if resp.Step != "" {
-				if parentID == "" {
-					parentID = resp.ID
+			if rec != nil {
+				if err := rec.RecordMessage(msg.Data); err != nil {
+					fmt.Println("Failed to record the job step. Reason: ", err)
 				}
-				steps[resp.ID] = resp.Step
 			}
-			if isStepStartedOrCompleted(resp.Status) {
-				switch resp.Status {
-				case stepSucceeded:
-					color.Green(fmt.Sprintf("%s %s", strings.ToUpper(resp.Status), steps[resp.ID]))
-				case stepFailed:
-					color.Red(fmt.Sprintf("%s %s", strings.ToUpper(resp.Status), steps[resp.ID]))
-				default:
-					color.Blue(fmt.Sprintf("%s %s", strings.ToUpper(resp.Status), steps[resp.ID]))
-
+			if result != "" {
+				if rec != nil {
+					rec.RecordResult(result)
 				}
-				if resp.ID == parentID && (resp.Status == "Success" || resp.Status == "Failed") {
-					return stopListening(nil)
+				if result == stepFailed {
+					return stopListening(ErrJobFailed)
 				}
+				return stopListening(nil)
 			}
 		}
 	}
 }
 
+// PrintJobTranscript re-renders the recorded messages of a job the same way
+// they were printed while the job was running.
+func PrintJobTranscript(messages [][]byte) error {
+	p := newJobStepPrinter()
+	for _, data := range messages {
+		result, err := p.print(data)
+		if err != nil {
+			return err
+		}
+		if result != "" {
+			return nil
+		}
+	}
+	return nil
+}
+
+type jobStepPrinter struct {
+	steps    map[string]string
+	parentID string
+}
+
+func newJobStepPrinter() *jobStepPrinter {
+	return &jobStepPrinter{
+		steps: make(map[string]string),
+	}
+}
+
+// print renders a single job message. It returns the final status of the job
+// once the parent step has completed, and an empty string otherwise.
+func (p *jobStepPrinter) print(data []byte) (string, error) {
+	resp := natsMessage{}
+	err := json.Unmarshal(data, &resp)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse message. Reason: %w", err)
+	}
+	if resp.Step != "" {
+		if p.parentID == "" {
+			p.parentID = resp.ID
+		}
+		p.steps[resp.ID] = resp.Step
+	}
+	if isStepStartedOrCompleted(resp.Status) {
+		switch resp.Status {
+		case stepSucceeded:
+			color.Green(fmt.Sprintf("%s %s", strings.ToUpper(resp.Status), p.steps[resp.ID]))
+		case stepFailed:
+			color.Red(fmt.Sprintf("%s %s", strings.ToUpper(resp.Status), p.steps[resp.ID]))
+		default:
+			color.Blue(fmt.Sprintf("%s %s", strings.ToUpper(resp.Status), p.steps[resp.ID]))
+
+		}
+		if resp.ID == p.parentID && (resp.Status == stepSucceeded || resp.Status == stepFailed) {
+			return resp.Status, nil
+		}
+	}
+	return "", nil
+}
+
 func isStepStartedOrCompleted(status string) bool {
 	return status == stepStarted || status == stepSucceeded || status == stepFailed
 }
@@ -0,0 +1,78 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the AppsCode Community License 1.0.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://github.com/appscode/licenses/raw/1.0.0/AppsCode-Community-1.0.0.md
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package printer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// JobStatusFunc reports the current state of a job. It returns a short
+// description of the state and whether the job has finished.
+type JobStatusFunc func() (state string, finished bool, err error)
+
+const (
+	DefaultPollInterval = 5 * time.Second
+	DefaultPollTimeout  = 30 * time.Minute
+)
+
+// PrintDegradedModeNotice tells the user that the job progress will be
+// tracked by polling since the NATS server could not be reached.
+func PrintDegradedModeNotice(reason error) {
+	color.Yellow("WARNING: Unable to connect to NATS. Reason: %v", reason)
+	color.Yellow("Running in degraded progress mode. Progress will be tracked by polling the job status every %s.", DefaultPollInterval)
+}
+
+// PollJobStatus follows a job by periodically calling status until it reports
+// that the job has finished. Every state change is printed.
+func PollJobStatus(ctx context.Context, status JobStatusFunc, interval, timeout time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	deadline := time.After(timeout)
+
+	lastState := ""
+	for {
+		state, finished, err := status()
+		if err != nil {
+			color.Red(fmt.Sprintf("%s %s", strings.ToUpper(stepFailed), err.Error()))
+			return err
+		}
+		if state != lastState {
+			if finished {
+				color.Green(fmt.Sprintf("%s %s", strings.ToUpper(stepSucceeded), state))
+			} else {
+				color.Blue(fmt.Sprintf("%s %s", "POLLING", state))
+			}
+			lastState = state
+		}
+		if finished {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ErrTerminatedByUser
+		case <-deadline:
+			return fmt.Errorf("timed out after %s waiting for the job to finish. Last known state: %s", timeout, lastState)
+		case <-ticker.C:
+		}
+	}
+}